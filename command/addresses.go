@@ -0,0 +1,165 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/parser"
+
+	"github.com/urfave/cli"
+)
+
+// Addresses cli command
+func Addresses(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {pbf}")
+		os.Exit(1)
+	}
+
+	// optionally load the admin areas from a leveldb database
+	var admin *lib.AdminLookup
+	if "" != c.String("admin") {
+		lib.EnsureDirectoryExists(c.String("admin"), "admin")
+		conn := openConnection(c, c.String("admin"), true)
+		admin = lib.NewAdminLookup(loadAdminAreas(conn, nil))
+		conn.Close()
+		log.Printf("loaded %d admin areas\n", admin.Len())
+	}
+
+	// output
+	var writer handler.AddressWriter
+	switch strings.ToLower(c.String("format")) {
+	case "", "json":
+		writer = &addressJSON{}
+	case "csv":
+		var sink = &addressCSV{Writer: csv.NewWriter(os.Stdout), Admin: nil != admin}
+		defer sink.Writer.Flush()
+		printAddressCSVHeader(sink.Writer, sink.Admin)
+		writer = sink
+	default:
+		log.Println("invalid format, expected one of json/csv")
+		os.Exit(1)
+	}
+
+	// open location store
+	locations := openLocations(c, nil, "", false)
+	defer locations.Close()
+
+	// create parser
+	parser := parser.NewParser(argv[0])
+
+	// one pass per stage, see handler.Addresses
+	var handle = handler.NewAddresses(writer, locations)
+	handle.StreetDistance = c.Float64("street-distance")
+	handle.Admin = admin
+	for handle.Pass = 0; handle.Pass < 4; handle.Pass++ {
+		if handle.Pass > 0 {
+			parser.Reset()
+		}
+		parser.Parse(handle)
+	}
+
+	return nil
+}
+
+// addressJSON - one line of JSON per address
+type addressJSON struct {
+	mutex sync.Mutex
+}
+
+func (w *addressJSON) WriteAddress(address *handler.Address) {
+	bytes, err := json.Marshal(address)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	w.mutex.Lock()
+	fmt.Println(string(bytes))
+	w.mutex.Unlock()
+}
+
+// addressAdminLevels - the admin levels output as csv columns
+var addressAdminLevels = []int{2, 4, 6, 8, 10}
+
+// print the CSV header
+func printAddressCSVHeader(csvWriter *csv.Writer, admin bool) {
+	var header = []string{
+		"type",
+		"id",
+		"lat",
+		"lon",
+		"housenumber",
+		"unit",
+		"street",
+		"street_source",
+		"place",
+		"suburb",
+		"city",
+		"postcode",
+		"state",
+		"country",
+	}
+	if admin {
+		for _, level := range addressAdminLevels {
+			header = append(header, "admin_"+lib.AdminPlacetypes[level])
+		}
+	}
+	err := csvWriter.Write(header)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// addressCSV - one row per address, the names of the admin areas are
+// added when Admin is set
+type addressCSV struct {
+	Writer *csv.Writer
+	Admin  bool
+	mutex  sync.Mutex
+}
+
+func (w *addressCSV) WriteAddress(address *handler.Address) {
+	var row = []string{
+		address.Type,
+		strconv.FormatInt(address.ID, 10),
+		fmt.Sprintf("%f", address.Lat),
+		fmt.Sprintf("%f", address.Lon),
+		address.HouseNumber,
+		address.Unit,
+		address.Street,
+		address.StreetSource,
+		address.Place,
+		address.Suburb,
+		address.City,
+		address.Postcode,
+		address.State,
+		address.Country,
+	}
+	if w.Admin {
+		for _, level := range addressAdminLevels {
+			var name string
+			if area, ok := address.Admin[lib.AdminPlacetypes[level]]; ok {
+				name = area.Name
+			}
+			row = append(row, name)
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	err := w.Writer.Write(row)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,132 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+	"github.com/stretchr/testify/assert"
+)
+
+// addressCollector - collect the written addresses by element
+type addressCollector struct {
+	mutex     sync.Mutex
+	addresses map[string]*handler.Address
+}
+
+func (w *addressCollector) WriteAddress(address *handler.Address) {
+	w.mutex.Lock()
+	w.addresses[fmt.Sprintf("%s/%d", address.Type, address.ID)] = address
+	w.mutex.Unlock()
+}
+
+func TestAddresses(t *testing.T) {
+	var writer = &addressCollector{addresses: make(map[string]*handler.Address)}
+	var x = handler.NewAddresses(writer, location.NewMemory())
+	x.StreetDistance = 100
+
+	// an admin area containing node 14
+	area, _ := lib.NewAdminArea(100, map[string]string{"admin_level": "8", "name": "Town"}, lib.MultiPolygon{{{{0.0045, 0}, {0.0055, 0}, {0.0055, 0.001}, {0.0045, 0.001}, {0.0045, 0}}}})
+	x.Admin = lib.NewAdminLookup([]*lib.AdminArea{area})
+
+	var nodes = []gosmparse.Node{
+		{ID: 1, Lat: 0, Lon: 0},
+		{ID: 2, Lat: 0, Lon: 0.01},
+		{ID: 3, Lat: 0.0005, Lon: 0.002, Tags: map[string]string{"addr:housenumber": " 12 ", "addr:street": "Main  Street", "addr:postcode": "ab1 2cd"}},
+		{ID: 4, Lat: 0.0005, Lon: 0.003, Tags: map[string]string{"addr:housenumber": "14", "addr:place": "Village"}},
+		{ID: 5, Lat: 0.0005, Lon: 0.004, Tags: map[string]string{"addr:housenumber": "16"}},
+		{ID: 6, Lat: 0.01, Lon: 0.004, Tags: map[string]string{"addr:housenumber": "18"}},
+		{ID: 7, Lat: 0.001, Lon: 0.001},
+		{ID: 8, Lat: 0.001, Lon: 0.002},
+		{ID: 9, Lat: 0.002, Lon: 0.002},
+		{ID: 10, Lat: 0.003, Lon: 0.003},
+		{ID: 11, Lat: 0.003, Lon: 0.004},
+		{ID: 12, Lat: 0.004, Lon: 0.004},
+		{ID: 13, Lat: 0.004, Lon: 0.003},
+		{ID: 14, Lat: 0.0005, Lon: 0.005, Tags: map[string]string{"addr:housenumber": "26"}},
+	}
+	var ways = []gosmparse.Way{
+		{ID: 1, NodeIDs: []int64{1, 2}, Tags: map[string]string{"highway": "residential", "name": "Main Street"}},
+		{ID: 2, NodeIDs: []int64{7, 8, 9, 7}, Tags: map[string]string{"building": "yes", "addr:housenumber": "20"}},
+		{ID: 3, NodeIDs: []int64{7, 8, 9, 7}, Tags: map[string]string{"building": "yes"}},
+		{ID: 4, NodeIDs: []int64{8, 9}, Tags: map[string]string{"addr:interpolation": "even"}},
+		{ID: 5, NodeIDs: []int64{10, 11}},
+		{ID: 6, NodeIDs: []int64{10, 13, 12, 11}},
+	}
+	var relations = []gosmparse.Relation{
+		{ID: 1, Tags: map[string]string{"type": "associatedStreet"}, Members: []gosmparse.RelationMember{
+			{ID: 5, Type: gosmparse.NodeType, Role: "house"},
+			{ID: 1, Type: gosmparse.WayType, Role: "street"},
+		}},
+		{ID: 2, Tags: map[string]string{"type": "multipolygon", "addr:housenumber": "22"}, Members: []gosmparse.RelationMember{
+			{ID: 3, Type: gosmparse.WayType, Role: "outer"},
+		}},
+		{ID: 3, Tags: map[string]string{"type": "multipolygon", "addr:housenumber": "24"}, Members: []gosmparse.RelationMember{
+			{ID: 5, Type: gosmparse.WayType, Role: "outer"},
+			{ID: 6, Type: gosmparse.WayType, Role: "outer"},
+		}},
+	}
+
+	for x.Pass = 0; x.Pass < 4; x.Pass++ {
+		for _, node := range nodes {
+			x.ReadNode(node)
+		}
+		for _, way := range ways {
+			x.ReadWay(way)
+		}
+		for _, relation := range relations {
+			x.ReadRelation(relation)
+		}
+	}
+	assert.Len(t, writer.addresses, 8)
+
+	// normalized tags
+	var address = writer.addresses["node/3"]
+	assert.Equal(t, "12", address.HouseNumber)
+	assert.Equal(t, "Main Street", address.Street)
+	assert.Equal(t, "addr:street", address.StreetSource)
+	assert.Equal(t, "AB1 2CD", address.Postcode)
+
+	// the nearest highway is not used for a place
+	address = writer.addresses["node/4"]
+	assert.Equal(t, "", address.Street)
+	assert.Equal(t, "", address.StreetSource)
+	assert.Equal(t, "Village", address.Place)
+
+	// nearest named highway
+	address = writer.addresses["node/14"]
+	assert.Equal(t, "Main Street", address.Street)
+	assert.Equal(t, "nearest", address.StreetSource)
+
+	// admin areas
+	assert.Equal(t, "Town", address.Admin["city"].Name)
+	assert.Empty(t, writer.addresses["node/3"].Admin)
+
+	// associatedStreet named by its street member
+	address = writer.addresses["node/5"]
+	assert.Equal(t, "Main Street", address.Street)
+	assert.Equal(t, "associatedStreet", address.StreetSource)
+
+	// too far from a highway
+	address = writer.addresses["node/6"]
+	assert.Equal(t, "", address.Street)
+	assert.Equal(t, "", address.StreetSource)
+
+	// way and relation centroids
+	address = writer.addresses["way/2"]
+	assert.Equal(t, "20", address.HouseNumber)
+	assert.InDelta(t, 0.0015, address.Lat, 0.0005)
+	assert.InDelta(t, 0.0015, address.Lon, 0.0005)
+	assert.Equal(t, address.Lat, writer.addresses["relation/2"].Lat)
+	assert.Equal(t, address.Lon, writer.addresses["relation/2"].Lon)
+
+	// the outer ways of a relation are joined in to a ring
+	address = writer.addresses["relation/3"]
+	assert.Equal(t, "24", address.HouseNumber)
+	assert.InDelta(t, 0.0035, address.Lat, 0.0002)
+	assert.InDelta(t, 0.0035, address.Lon, 0.0002)
+}
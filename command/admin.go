@@ -0,0 +1,65 @@
+package command
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+)
+
+// loadAdminAreas - assemble every boundary=administrative relation stored in
+// the database, relations which cannot be assembled are logged and skipped
+func loadAdminAreas(conn *leveldb.Connection, locations location.Store) []*lib.AdminArea {
+	var areas []*lib.AdminArea
+	var mutex sync.Mutex
+
+	// create a channel for relations
+	var queue = make(chan *gosmparse.Relation, 256)
+
+	// assemble relations using all available cpus
+	var wg = &sync.WaitGroup{}
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range queue {
+				var assembler = &lib.RelationAssembler{
+					Relation:  rel,
+					Conn:      conn,
+					Locations: locations,
+				}
+				geometry, err := assembler.MultiPolygon()
+				if nil != err {
+					log.Printf("skipping relation %d. %s\n", rel.ID, err)
+					continue
+				}
+				if area, ok := lib.NewAdminArea(rel.ID, rel.Tags, geometry); ok {
+					area.Subareas = assembler.Subareas()
+					mutex.Lock()
+					areas = append(areas, area)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	// iterate over relations, add administrative boundaries to the queue
+	conn.IterateRelations(nil, func(rel *gosmparse.Relation, err error) bool {
+		if nil != err {
+			log.Println(err)
+			return false
+		}
+		if "administrative" == rel.Tags["boundary"] {
+			queue <- rel
+		}
+		return true
+	})
+	close(queue)
+	wg.Wait()
+
+	return areas
+}
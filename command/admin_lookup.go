@@ -0,0 +1,164 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+
+	pbfjson "github.com/missinglink/pbf/json"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+
+	"github.com/urfave/cli"
+)
+
+// maximum length of an input line, json-flat lines including vertices can
+// be very long
+const maxLineLength = 64 * 1024 * 1024
+
+// AdminLookup cli command
+func AdminLookup(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) < 1 || len(argv) > 2 {
+		log.Println("invalid arguments, expected: {leveldb} [input]")
+		os.Exit(1)
+	}
+
+	// stat leveldb destination
+	lib.EnsureDirectoryExists(argv[0], "leveldb")
+
+	// open input, defaulting to stdin
+	var input io.Reader = os.Stdin
+	if len(argv) == 2 && "-" != argv[1] {
+		file, err := os.Open(argv[1])
+		if nil != err {
+			log.Println(err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	// open database connection
+	conn := openConnection(c, argv[0], true)
+	defer conn.Close()
+
+	// optionally read way refs from a separate location store
+	var locations location.Store
+	if "" != c.String("locations") && "leveldb" != c.String("locations") {
+		locations = openLocations(c, conn, argv[0], true)
+		defer locations.Close()
+	}
+
+	// build lookup
+	var lookup = lib.NewAdminLookup(loadAdminAreas(conn, locations))
+	log.Printf("loaded %d admin areas\n", lookup.Len())
+
+	// annotate lines
+	var writer = lib.NewBufferedWriter()
+	defer writer.Close()
+
+	var scanner = bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		writer.Queue <- annotateAdmin(lookup, scanner.Bytes())
+	}
+	if err := scanner.Err(); nil != err {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+// adminLocation - the position of a json line, either the lat/lon of a
+// node or the centroid computed by json-flat
+type adminLocation struct {
+	Lat      *float64        `json:"lat"`
+	Lon      *float64        `json:"lon"`
+	Centroid *pbfjson.LatLon `json:"centroid"`
+	Admin    json.RawMessage `json:"admin"`
+}
+
+// annotateAdmin - add an 'admin' property to a json object, an existing
+// 'admin' property is replaced. lines which aren't objects or have no
+// location are returned unchanged
+func annotateAdmin(lookup *lib.AdminLookup, line []byte) []byte {
+	var out = append([]byte{}, line...)
+
+	var loc adminLocation
+	if err := json.Unmarshal(line, &loc); nil != err {
+		return out
+	}
+
+	var lon, lat float64
+	switch {
+	case nil != loc.Lat && nil != loc.Lon:
+		lon, lat = *loc.Lon, *loc.Lat
+	case nil != loc.Centroid:
+		lon, lat = loc.Centroid.Lon, loc.Centroid.Lat
+	default:
+		return out
+	}
+
+	admin, err := json.Marshal(lookup.Hierarchy(lon, lat))
+	if nil != err {
+		return out
+	}
+
+	// replace the existing property in place
+	if nil != loc.Admin {
+		if replaced, err := replaceProperty(line, "admin", admin); nil == err {
+			return replaced
+		}
+		return out
+	}
+
+	// insert the property before the closing brace to preserve the
+	// original key order
+	var trimmed = bytes.TrimRight(out, " \t\r")
+	var end = len(trimmed) - 1
+	var property = append([]byte(`"admin":`), admin...)
+	if !bytes.HasSuffix(bytes.TrimRight(trimmed[:end], " \t"), []byte("{")) {
+		property = append([]byte(","), property...)
+	}
+	return append(trimmed[:end], append(property, '}')...)
+}
+
+// replaceProperty - rewrite a json object with the value of every key
+// property replaced, the other properties are copied in their original order
+func replaceProperty(line []byte, key string, value []byte) ([]byte, error) {
+	var decoder = json.NewDecoder(bytes.NewReader(line))
+	if _, err := decoder.Token(); nil != err {
+		return nil, err
+	}
+
+	var buf = bytes.NewBufferString("{")
+	for decoder.More() {
+		token, err := decoder.Token()
+		if nil != err {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); nil != err {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(token)
+		buf.Write(name)
+		buf.WriteByte(':')
+		if key == token {
+			buf.Write(value)
+		} else {
+			buf.Write(raw)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
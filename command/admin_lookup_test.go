@@ -0,0 +1,36 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateAdmin(t *testing.T) {
+	area, _ := lib.NewAdminArea(1, map[string]string{"admin_level": "8", "name": "City"}, lib.MultiPolygon{{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}}})
+	var lookup = lib.NewAdminLookup([]*lib.AdminArea{area})
+	var admin = `"admin":{"city":{"id":1,"name":"City","admin_level":8}}`
+
+	for _, test := range []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{"lat/lon", `{"id":1,"lat":1,"lon":1}`, `{"id":1,"lat":1,"lon":1,` + admin + `}`},
+		{"centroid", `{"id":2,"centroid":{"lat":1,"lon":1}}`, `{"id":2,"centroid":{"lat":1,"lon":1},` + admin + `}`},
+		{"outside", `{"id":3,"lat":5,"lon":5}`, `{"id":3,"lat":5,"lon":5,"admin":{}}`},
+		{"empty object", `{}`, `{}`},
+		{"no location", `{"id":4}`, `{"id":4}`},
+		{"array", `[1,2]`, `[1,2]`},
+		{"string", `"text"`, `"text"`},
+		{"invalid", `{"id":`, `{"id":`},
+		{"trailing whitespace", "{\"lat\":1,\"lon\":1} \t\r", `{"lat":1,"lon":1,` + admin + `}`},
+		{"existing admin", `{"id":5,"admin":{"city":{"id":9}},"lat":1,"lon":1}`, `{"id":5,` + admin + `,"lat":1,"lon":1}`},
+		{"existing null admin", `{"admin":null,"lat":1,"lon":1}`, `{` + admin + `,"lat":1,"lon":1}`},
+	} {
+		var line = []byte(test.line)
+		assert.Equal(t, test.expected, string(annotateAdmin(lookup, line)), test.name)
+		assert.Equal(t, test.line, string(line), test.name)
+	}
+}
@@ -0,0 +1,88 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+
+	"github.com/urfave/cli"
+)
+
+// AdminTree cli command
+func AdminTree(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {leveldb}")
+		os.Exit(1)
+	}
+
+	// select output format
+	var format = c.String("format")
+	if "" == format {
+		format = "json"
+	}
+	if "json" != format && "text" != format {
+		log.Println("invalid format, expected one of json/text")
+		os.Exit(1)
+	}
+
+	// stat leveldb destination
+	lib.EnsureDirectoryExists(argv[0], "leveldb")
+
+	// open database connection
+	conn := openConnection(c, argv[0], true)
+	defer conn.Close()
+
+	// optionally read way refs from a separate location store
+	var locations location.Store
+	if "" != c.String("locations") && "leveldb" != c.String("locations") {
+		locations = openLocations(c, conn, argv[0], true)
+		defer locations.Close()
+	}
+
+	// build hierarchy
+	var roots = lib.BuildAdminTree(loadAdminAreas(conn, locations))
+
+	// write nodes, parents before their children
+	var writer = lib.NewBufferedWriter()
+	defer writer.Close()
+
+	var flagged = make(map[string]int)
+	lib.WalkAdminTree(roots, func(node *lib.AdminNode) {
+		for _, f := range node.Flags {
+			flagged[f]++
+		}
+
+		if "text" == format {
+			var line = fmt.Sprintf("%s%d %s (admin_level %d)", strings.Repeat("  ", node.Depth), node.ID, node.Name, node.Level)
+			if len(node.Flags) > 0 {
+				line += " [" + strings.Join(node.Flags, ", ") + "]"
+			}
+			writer.Queue <- []byte(line)
+			return
+		}
+
+		bytes, err := json.Marshal(node)
+		if nil != err {
+			log.Println(err)
+			return
+		}
+		writer.Queue <- bytes
+	})
+
+	// summary
+	for _, f := range []string{lib.AdminFlagOrphan, lib.AdminFlagOverlap, lib.AdminFlagHint, lib.AdminFlagMismatch} {
+		if flagged[f] > 0 {
+			log.Printf("%d areas flagged %s\n", flagged[f], f)
+		}
+	}
+
+	return nil
+}
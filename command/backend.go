@@ -0,0 +1,59 @@
+package command
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/missinglink/pbf/kv"
+	"github.com/missinglink/pbf/leveldb"
+
+	"github.com/urfave/cli"
+)
+
+// name of the database file used by the bolt backend, inside the db directory
+const boltFileName = "elements.bolt"
+
+// openConnection - open the element store using the backend selected by the --backend flag
+// note: when persistent is true the store must survive after the process exits
+func openConnection(c *cli.Context, path string, persistent bool) *leveldb.Connection {
+
+	var kind = c.String("backend")
+	var boltPath = filepath.Join(path, boltFileName)
+
+	// detect existing bolt databases when no backend was specified
+	if "" == kind {
+		kind = "leveldb"
+		if _, err := os.Stat(boltPath); err == nil {
+			kind = "bolt"
+		}
+	}
+
+	var backend kv.Backend
+	var err error
+
+	switch kind {
+	case "leveldb":
+		backend, err = kv.OpenLevelDB(path, nil)
+	case "bolt":
+		backend, err = kv.OpenBolt(boltPath)
+	case "memory":
+		if persistent {
+			log.Println("memory backend is not persisted, please use --backend=leveldb or --backend=bolt")
+			os.Exit(1)
+		}
+		backend = kv.NewMemory()
+	default:
+		log.Println("invalid backend, expected one of: leveldb, bolt, memory")
+		os.Exit(1)
+	}
+
+	if nil != err {
+		log.Printf("failed to open %s backend: %s\n", kind, err)
+		os.Exit(1)
+	}
+
+	conn := &leveldb.Connection{}
+	conn.OpenBackend(backend)
+	return conn
+}
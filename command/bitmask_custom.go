@@ -8,6 +8,7 @@ import (
 	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/parser"
 
+	"github.com/missinglink/gosmparse"
 	"github.com/urfave/cli"
 )
 
@@ -30,19 +31,32 @@ func BitmaskCustom(c *cli.Context) error {
 		os.Exit(1)
 	}
 
-	// check config file path
+	// at least one selection criteria is required
 	var configPath = c.String("config")
-	if "" == configPath {
-		log.Println("config file required, please specify one")
+	var expandPath = c.String("expand")
+	var region = regionFromFlags(c)
+	if "" == configPath && nil == region && "" == expandPath {
+		log.Println("selection required, please specify one of --config, --bbox, --polygon or --expand")
 		os.Exit(1)
 	}
 
-	var config, configError = lib.NewFeatureSetFromJSON(configPath)
-	if nil != configError {
-		log.Println("config error")
+	// an existing mask cannot also be filtered
+	if "" != expandPath && ("" != configPath || nil != region) {
+		log.Println("--expand cannot be combined with --config, --bbox or --polygon")
 		os.Exit(1)
 	}
 
+	// load feature config
+	var config *lib.FeatureSet
+	if "" != configPath {
+		var configError error
+		config, configError = lib.NewFeatureSetFromJSON(configPath)
+		if nil != configError {
+			log.Println("config error")
+			os.Exit(1)
+		}
+	}
+
 	// also perform pbf indexing
 	if c.Bool("indexing") {
 
@@ -50,17 +64,97 @@ func BitmaskCustom(c *cli.Context) error {
 		os.Setenv("INDEXING", "ON")
 	}
 
-	// open database for writing
-	handle := &handler.BitmaskCustom{
-		Masks:    lib.NewBitmaskMap(),
-		Features: config,
+	// the parser must be reset between passes
+	var passes = 0
+	var parse = func(handle gosmparse.OSMReader) {
+		if passes > 0 {
+			parser.Reset()
+		}
+		passes++
+
+		// Parse will block until it is done or an error occurs.
+		parser.Parse(handle)
 	}
 
+	masks := lib.NewBitmaskMap()
+
 	// write to disk
-	defer handle.Masks.WriteToFile(c.Args()[1])
+	defer masks.WriteToFile(c.Args()[1])
+
+	switch {
+	case "" != expandPath:
+
+		// start from an existing mask
+		masks.ReadFromFile(expandPath)
+
+	case nil != region:
 
-	// Parse will block until it is done or an error occurs.
-	parser.Parse(handle)
+		// select by coordinate, one pass per element type
+		handle := &handler.BitmaskSpatial{
+			Masks:      masks,
+			Features:   config,
+			Region:     region,
+			InsideNode: lib.NewBitMask(),
+			InsideWay:  lib.NewBitMask(),
+		}
+		for handle.Pass = 0; handle.Pass < 3; handle.Pass++ {
+			parse(handle)
+		}
+
+	default:
+
+		// select by tags
+		parse(&handler.BitmaskCustom{
+			Masks:    masks,
+			Features: config,
+		})
+	}
+
+	// expand the mask to include connected elements
+	var hops = c.Int("hops")
+	if "" != expandPath && hops < 1 {
+		hops = 1
+	}
+	for i := 0; i < hops; i++ {
+		var prev = masks.Clone()
+		parse(&handler.BitmaskExpand{
+			Prev:  prev,
+			Masks: masks,
+		})
+		log.Printf("expanded mask (hop %d): %d ways, %d relations\n", i+1, masks.Ways.Len(), masks.Relations.Len())
+	}
+
+	return nil
+}
+
+// regionFromFlags - load the --bbox or --polygon region (if any)
+func regionFromFlags(c *cli.Context) lib.Region {
+
+	var bbox = c.String("bbox")
+	var polygon = c.String("polygon")
+
+	if "" != bbox && "" != polygon {
+		log.Println("--bbox and --polygon are mutually exclusive")
+		os.Exit(1)
+	}
+
+	if "" != bbox {
+		region, err := lib.ParseBBox(bbox)
+		if nil != err {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return region
+	}
+
+	if "" != polygon {
+		region, err := lib.NewPolygonRegionFromGeoJSON(polygon)
+		if nil != err {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return region
+	}
 
 	return nil
 }
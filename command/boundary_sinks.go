@@ -0,0 +1,297 @@
+package command
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/gpkg"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/sqlite"
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// boundarySink - destination for assembled boundaries, implementations
+// must be safe for concurrent use
+type boundarySink interface {
+	Write(rel *gosmparse.Relation, geometry lib.MultiPolygon)
+	Fail(rel *gosmparse.Relation, failure *boundaryFailure, assembler *lib.RelationAssembler)
+	Close()
+}
+
+// openBoundarySink - create the sink for format, writing to path
+func openBoundarySink(format string, path string) boundarySink {
+	switch format {
+	case "", "dir":
+		lib.EnsureDirectoryExists(path, "geojson")
+		return &boundaryDir{Root: path}
+	}
+
+	// don't clobber existing files
+	if _, err := os.Stat(path); err == nil {
+		log.Println("output file already exists; don't want to override it")
+		os.Exit(1)
+	}
+
+	switch format {
+	case "geojsonseq":
+		var out, errs = os.Stdout, os.Stderr
+		if "-" != path {
+			var err error
+			if out, err = os.Create(path); nil != err {
+				log.Println(err)
+				os.Exit(1)
+			}
+			if errs, err = os.Create(path + ".err"); nil != err {
+				log.Println(err)
+				os.Exit(1)
+			}
+		}
+		return &boundarySeq{File: out, Errors: errs, Writer: lib.NewGeoJSONWriterTo(out, true)}
+	case "sqlite":
+		return &boundarySqlite{Writer: sqlite.NewBoundaryWriter(path)}
+	case "gpkg":
+		var conn = &gpkg.Connection{}
+		conn.Open(path)
+		var errors = newBoundaryGpkgErrors(conn)
+		var layers = []gpkg.Layer{{Name: "boundaries", Geometry: "MULTIPOLYGON"}}
+		return &boundaryGpkg{Conn: conn, Errors: errors, Writer: gpkg.NewLayerWriter(conn, layers, []string{"admin_level", "name"})}
+	}
+
+	log.Println("invalid format, expected one of dir/geojsonseq/sqlite/gpkg")
+	os.Exit(1)
+	return nil
+}
+
+// boundaryFeature - the feature written for a boundary relation
+func boundaryFeature(rel *gosmparse.Relation, geometry lib.MultiPolygon) *lib.Feature {
+	return &lib.Feature{Type: "relation", ID: rel.ID, Tags: rel.Tags, Geometry: geometry}
+}
+
+// boundaryDir - one geojson file per relation in a three level directory
+// tree, failures are written alongside as .in (overpass json) and .err files
+type boundaryDir struct {
+	Root string
+}
+
+// path - pad id with leading zeros and create the directory
+func (s *boundaryDir) path(rel *gosmparse.Relation) string {
+	var id = fmt.Sprintf("%09d", rel.ID)
+	var dir = fmt.Sprintf("%s/%s/%s/%s/", s.Root, id[0:3], id[3:6], id[6:9])
+	os.MkdirAll(dir, 0777)
+	return dir + id
+}
+
+func (s *boundaryDir) Write(rel *gosmparse.Relation, geometry lib.MultiPolygon) {
+	var fc = geojson.NewFeatureCollection()
+	fc.AddFeature(lib.ToGeoJSON(boundaryFeature(rel, geometry)))
+	bytes, _ := fc.MarshalJSON()
+	ioutil.WriteFile(s.path(rel)+".geojson", bytes, 0644)
+}
+
+func (s *boundaryDir) Fail(rel *gosmparse.Relation, failure *boundaryFailure, assembler *lib.RelationAssembler) {
+	var path = s.path(rel)
+	var json = assembler.GenerateJSON()
+	ioutil.WriteFile(path+".in", json.Bytes(), 0644)
+	ioutil.WriteFile(path+".err", []byte(failure.String()+"\n"), 0644)
+}
+
+func (s *boundaryDir) Close() {}
+
+// boundarySeq - a single GeoJSONSeq stream, failures are written as json
+// lines to {path}.err, or stderr when streaming to stdout
+type boundarySeq struct {
+	File   *os.File
+	Errors *os.File
+	Writer *lib.GeoJSONWriter
+
+	mutex sync.Mutex
+}
+
+func (s *boundarySeq) Write(rel *gosmparse.Relation, geometry lib.MultiPolygon) {
+	s.Writer.WriteFeature(boundaryFeature(rel, geometry))
+}
+
+func (s *boundarySeq) Fail(rel *gosmparse.Relation, failure *boundaryFailure, assembler *lib.RelationAssembler) {
+	bytes, err := json.Marshal(failure)
+	if nil != err {
+		log.Println(err)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.Errors.Write(append(bytes, '\n')); nil != err {
+		log.Println(err)
+	}
+}
+
+func (s *boundarySeq) Close() {
+	s.Writer.Close()
+	if os.Stdout != s.File {
+		s.File.Close()
+	}
+	if os.Stderr != s.Errors {
+		s.Errors.Close()
+	}
+}
+
+// boundarySqlite - a sqlite table keyed by relation id, with failures
+// recorded in a second table
+type boundarySqlite struct {
+	Writer *sqlite.BoundaryWriter
+}
+
+func (s *boundarySqlite) Write(rel *gosmparse.Relation, geometry lib.MultiPolygon) {
+	s.Writer.WriteBoundary(rel.ID, rel.Tags, geometry)
+}
+
+func (s *boundarySqlite) Fail(rel *gosmparse.Relation, failure *boundaryFailure, assembler *lib.RelationAssembler) {
+	s.Writer.WriteError(rel.ID, rel.Tags, failure.Reason, failure.IDs)
+}
+
+func (s *boundarySqlite) Close() {
+	s.Writer.Close()
+}
+
+// boundaryGpkg - a geopackage feature table with admin_level and name
+// columns, failures are recorded in a 'boundary_errors' attributes table
+type boundaryGpkg struct {
+	Conn   *gpkg.Connection
+	Errors *sql.Stmt
+	Writer *gpkg.Writer
+}
+
+// newBoundaryGpkgErrors - create the errors table, the same columns as
+// the sqlite format, and prepare its insert statement
+func newBoundaryGpkgErrors(conn *gpkg.Connection) *sql.Stmt {
+	_, err := conn.DB.Exec(`
+		CREATE TABLE boundary_errors (
+		    id INTEGER NOT NULL PRIMARY KEY,
+		    admin_level INTEGER,
+		    name TEXT,
+		    reason TEXT NOT NULL,
+		    ids TEXT
+		);
+		INSERT INTO gpkg_contents (table_name, data_type, identifier) VALUES ('boundary_errors', 'attributes', 'boundary_errors');`)
+	if err != nil {
+		panic(err)
+	}
+	stmt, err := conn.DB.Prepare("INSERT OR REPLACE INTO boundary_errors VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		panic(err)
+	}
+	return stmt
+}
+
+func (s *boundaryGpkg) Write(rel *gosmparse.Relation, geometry lib.MultiPolygon) {
+	s.Writer.WriteFeature(boundaryFeature(rel, geometry))
+}
+
+func (s *boundaryGpkg) Fail(rel *gosmparse.Relation, failure *boundaryFailure, assembler *lib.RelationAssembler) {
+	var ids interface{}
+	if len(failure.IDs) > 0 {
+		bytes, _ := json.Marshal(failure.IDs)
+		ids = string(bytes)
+	}
+	_, err := s.Errors.Exec(rel.ID, sqlite.AdminLevel(rel.Tags), sqlite.Nullable(failure.Name), failure.Reason, ids)
+	if nil != err {
+		log.Printf("failed to write relation %d: %s\n", rel.ID, err)
+	}
+}
+
+func (s *boundaryGpkg) Close() {
+	s.Errors.Close()
+	s.Writer.Close()
+	s.Conn.Close()
+}
+
+// boundaryFailure - a relation which could not be assembled
+type boundaryFailure struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name,omitempty"`
+	AdminLevel string  `json:"admin_level,omitempty"`
+	Reason     string  `json:"reason"`
+	IDs        []int64 `json:"ids,omitempty"`
+}
+
+// newBoundaryFailure - describe why rel could not be assembled
+func newBoundaryFailure(rel *gosmparse.Relation, err error) *boundaryFailure {
+	var failure = &boundaryFailure{
+		ID:         rel.ID,
+		Name:       rel.Tags["name"],
+		AdminLevel: rel.Tags["admin_level"],
+		Reason:     err.Error(),
+	}
+	if assembly, ok := err.(*lib.AssemblyError); ok {
+		failure.Reason = assembly.Reason
+		failure.IDs = assembly.IDs
+	}
+	return failure
+}
+
+// String - reason and ids
+func (f *boundaryFailure) String() string {
+	return (&lib.AssemblyError{Reason: f.Reason, IDs: f.IDs}).Error()
+}
+
+// boundaryReport - summary of successes and failures
+type boundaryReport struct {
+	Total    int                `json:"total"`
+	Written  int                `json:"written"`
+	Failed   int                `json:"failed"`
+	Reasons  map[string]int     `json:"reasons"`
+	Failures []*boundaryFailure `json:"failures"`
+
+	mutex sync.Mutex
+}
+
+// Success - count a written boundary
+func (r *boundaryReport) Success() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Total++
+	r.Written++
+}
+
+// Failure - count and remember a failed boundary
+func (r *boundaryReport) Failure(failure *boundaryFailure) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Total++
+	r.Failed++
+	if nil == r.Reasons {
+		r.Reasons = make(map[string]int)
+	}
+	r.Reasons[failure.Reason]++
+	r.Failures = append(r.Failures, failure)
+}
+
+// Log - write the summary to the log
+func (r *boundaryReport) Log() {
+	log.Printf("%d boundaries, %d written, %d failed\n", r.Total, r.Written, r.Failed)
+
+	var reasons = make([]string, 0, len(r.Reasons))
+	for reason := range r.Reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Printf("  %d %s\n", r.Reasons[reason], reason)
+	}
+}
+
+// WriteFile - write the full report as json
+func (r *boundaryReport) WriteFile(path string) error {
+	sort.Slice(r.Failures, func(i, j int) bool { return r.Failures[i].ID < r.Failures[j].ID })
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if nil != err {
+		return err
+	}
+	return ioutil.WriteFile(path, append(bytes, '\n'), 0644)
+}
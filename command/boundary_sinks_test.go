@@ -0,0 +1,103 @@
+package command
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundarySinkFailures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boundaries")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var rel = &gosmparse.Relation{ID: 5, Tags: map[string]string{"name": "Town", "admin_level": "8"}}
+	var failure = newBoundaryFailure(rel, &lib.AssemblyError{Reason: lib.ReasonMissingWay, IDs: []int64{7, 9}})
+
+	// geojsonseq, one json line per failure alongside the output
+	var path = filepath.Join(dir, "out.geojsonl")
+	var sink = openBoundarySink("geojsonseq", path)
+	sink.Fail(rel, failure, nil)
+	sink.Close()
+
+	bytes, err := ioutil.ReadFile(path + ".err")
+	assert.Nil(t, err)
+	var decoded boundaryFailure
+	assert.Nil(t, json.Unmarshal(bytes, &decoded))
+	assert.Equal(t, *failure, decoded)
+
+	// gpkg, a row per failure
+	path = filepath.Join(dir, "out.gpkg")
+	sink = openBoundarySink("gpkg", path)
+	sink.Fail(rel, failure, nil)
+	sink.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	var id, level int64
+	var name, reason, ids string
+	err = db.QueryRow("SELECT id, admin_level, name, reason, ids FROM boundary_errors WHERE typeof(admin_level) = 'integer'").Scan(&id, &level, &name, &reason, &ids)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int64(5), int64(8), "Town", lib.ReasonMissingWay, "[7,9]"}, []interface{}{id, level, name, reason, ids})
+}
+
+func TestBoundarySinkWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boundaries")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var rel = &gosmparse.Relation{ID: 5, Tags: map[string]string{"name": "Town", "admin_level": "8", "boundary": "administrative"}}
+	var geometry = lib.MultiPolygon{{{{1, 2}, {3, 2}, {3, 4}, {1, 2}}}}
+
+	// sqlite, a row per boundary with the bbox and wkt geometry
+	var path = filepath.Join(dir, "out.db")
+	var sink = openBoundarySink("sqlite", path)
+	sink.Write(rel, geometry)
+	sink.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	var id, level int64
+	var name, tags, geom string
+	var minLon, minLat, maxLon, maxLat float64
+	err = db.QueryRow("SELECT id, admin_level, name, minlon, minlat, maxlon, maxlat, tags, geom FROM boundaries").
+		Scan(&id, &level, &name, &minLon, &minLat, &maxLon, &maxLat, &tags, &geom)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int64(5), int64(8), "Town"}, []interface{}{id, level, name})
+	assert.Equal(t, []float64{1, 2, 3, 4}, []float64{minLon, minLat, maxLon, maxLat})
+	assert.JSONEq(t, `{"name": "Town", "admin_level": "8", "boundary": "administrative"}`, tags)
+	assert.Equal(t, geometry.WKT(), geom)
+
+	// gpkg, a feature per boundary indexed by the r-tree
+	path = filepath.Join(dir, "out.gpkg")
+	sink = openBoundarySink("gpkg", path)
+	sink.Write(rel, geometry)
+	sink.Close()
+
+	gpkgDB, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer gpkgDB.Close()
+
+	var osmType, levelTag string
+	var blob []byte
+	err = gpkgDB.QueryRow("SELECT osm_type, osm_id, admin_level, name, geom FROM boundaries").Scan(&osmType, &id, &levelTag, &name, &blob)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"relation", int64(5), "8", "Town"}, []interface{}{osmType, id, levelTag, name})
+	assert.Equal(t, []byte{'G', 'P'}, blob[0:2])
+	assert.Equal(t, geometry.WKB(), blob[len(blob)-len(geometry.WKB()):])
+
+	err = gpkgDB.QueryRow("SELECT minx, miny, maxx, maxy FROM rtree_boundaries_geom").Scan(&minLon, &minLat, &maxLon, &maxLat)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 2, 3, 4}, []float64{minLon, minLat, maxLon, maxLat})
+}
@@ -2,71 +2,204 @@ package command
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/missinglink/gosmparse"
 	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/kv"
 	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/parser"
 	"github.com/missinglink/pbf/tags"
 
+	geo "github.com/paulmach/go.geo"
+	geojson "github.com/paulmach/go.geojson"
 	"github.com/urfave/cli"
 )
 
+// crossroad - the intersection of two streets
+type crossroad struct {
+	Source           string            `json:"source"`
+	ID               string            `json:"id"`
+	Layer            string            `json:"layer"`
+	Lat              float64           `json:"lat"`
+	Lon              float64           `json:"lon"`
+	Street           string            `json:"street"`
+	CrossStreet      string            `json:"cross_street"`
+	StreetNames      map[string]string `json:"street_names"`
+	CrossStreetNames map[string]string `json:"cross_street_names"`
+	NodeID           int64             `json:"node_id"`
+	WayIDs           []int64           `json:"way_ids"`  // all the ways at the node
+	Highways         []string          `json:"highways"` // the highway class of each way
+	Legs             int               `json:"legs"`
+	LegWays          []int64           `json:"leg_ways"`           // the way of each leg, clockwise from north
+	Bearings         []float64         `json:"bearings"`           // the bearing of each leg in degrees
+	NodeIDs          []int64           `json:"node_ids,omitempty"` // the member nodes of a clustered junction
+
+	pair        string  // the normalized street names
+	roundabouts []int64 // the roundabout ways at the node
+	legWay      int64   // the way of a street meeting an unnamed roundabout
+}
+
+// crossroadHighways - highway classes intersected by default in addition to tags.Highway()
+var crossroadHighways = []string{
+	"motorway_link",
+	"trunk_link",
+	"primary_link",
+	"secondary_link",
+	"tertiary_link",
+	"unclassified",
+	"living_street",
+}
+
+// crossroadSink - destination for crossroads
+type crossroadSink interface {
+	Write(xroad crossroad)
+	Close()
+}
+
 // Crossroads cli command
 func Crossroads(c *cli.Context) error {
 
-	// create parser
-	parser := parser.NewParser(c.Args()[0])
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {pbf}")
+		os.Exit(1)
+	}
+
+	// select roads by highway class or feature config
+	var whitelist = tags.Highway()
+	for _, highway := range crossroadHighways {
+		whitelist[highway] = false
+	}
+	if "" != c.String("highway") {
+		whitelist = make(map[string]bool)
+		for _, highway := range strings.Split(c.String("highway"), ",") {
+			if highway = strings.TrimSpace(highway); "" != highway {
+				whitelist[highway] = false
+			}
+		}
+	}
+	var features = configFeatures(c)
+	if nil != features && "" != c.String("highway") {
+		log.Println("--highway cannot be combined with --config")
+		os.Exit(1)
+	}
 
-	// stats handler
-	handler := &handler.Xroads{
-		TagWhiteList:         tags.Highway(),
-		IntersectionWaysMask: lib.NewBitMask(),
-		WayNames:             make(map[int64]string),
-		NodeMap:              make(map[int64][]int64),
-		Coords:               make(map[int64]*gosmparse.Node),
-		Mutex:                &sync.Mutex{},
+	// output
+	var sink = openCrossroadSink(strings.ToLower(c.String("format")), c.Bool("extended"))
+	defer sink.Close()
+	var langs = tags.ParseLanguages(c.String("lang"))
+
+	// spill the intersections to a temporary leveldb store so that
+	// memory use is bounded by the store buffers and the node bitmasks
+	var dir = lib.TempFileName("pbf_", ".xroads.db")
+	defer os.RemoveAll(dir)
+	store, err := kv.OpenLevelDB(dir, nil)
+	if nil != err {
+		log.Println("failed to open temporary store", err)
+		os.Exit(1)
 	}
+	defer store.Close()
 
-	// parse file and compute all intersections
-	parser.Parse(handler)
+	// create parser
+	parser := parser.NewParser(argv[0])
 
-	// remove any nodes which are members of less than two ways
-	handler.TrimNonIntersections()
+	// xroads handler
+	var handle = handler.NewXroads(store)
+	handle.TagWhiteList = whitelist
+	handle.Features = features
 
-	// reset parser and make a second pass over the file
-	// to collect the node coordinates
-	parser.Reset()
-	handler.Pass++
-	parser.Parse(handler)
+	// the first pass counts the references to each node, the second
+	// spills the legs, names and highways of the intersections and the
+	// final pass spills the node coordinates
+	for handle.Pass = 0; handle.Pass < 3; handle.Pass++ {
+		if handle.Pass > 0 {
+			parser.Reset()
+		}
+		parser.Parse(handle)
 
-	// create a new CSV writer
-	csvWriter := csv.NewWriter(os.Stdout)
-	defer csvWriter.Flush()
+		// the store error has already been logged
+		if nil != handle.Err() {
+			os.Exit(1)
+		}
+	}
 
-	printCSVHeader(csvWriter)
+	// optionally cluster the intersections which form a single junction,
+	// the crossroads are spilled to a second store as the first is being read
+	var cluster *crossroadCluster
+	if c.Float64("cluster") > 0 {
+		var clusterDir = lib.TempFileName("pbf_", ".xroads-cluster.db")
+		defer os.RemoveAll(clusterDir)
+		clusterStore, err := kv.OpenLevelDB(clusterDir, nil)
+		if nil != err {
+			log.Println("failed to open temporary store", err)
+			os.Exit(1)
+		}
+		defer clusterStore.Close()
+		cluster = &crossroadCluster{Distance: c.Float64("cluster"), Store: clusterStore}
+	}
 
 	// iterate over the nodes which represent an intersection
-	for nodeid, wayids := range handler.NodeMap {
-		if len(wayids) > 1 {
+	err = handle.Junctions(func(junction *handler.Junction) {
+		var xroads = crossroadsAt(junction, langs)
+		if nil != cluster {
+			xroads = append(xroads, roundaboutLegs(junction, langs)...)
+		}
+		for _, xroad := range xroads {
+			xroad.Source = c.String("source")
+			xroad.Layer = c.String("layer")
+			if nil == cluster {
+				sink.Write(xroad)
+			} else if err := cluster.Write(xroad); nil != err {
+				log.Println("failed to write intersections", err)
+				os.Exit(1)
+			}
+		}
+	})
+	if nil != err {
+		log.Println("failed to read intersections", err)
+		os.Exit(1)
+	}
 
-			// write csv
-			printCSVLines(csvWriter, handler, nodeid, wayids)
+	if nil != cluster {
+		if err := cluster.Flush(sink.Write); nil != err {
+			log.Println("failed to cluster intersections", err)
+			os.Exit(1)
 		}
 	}
 
 	return nil
 }
 
+// openCrossroadSink - create the sink for format, writing to stdout
+func openCrossroadSink(format string, extended bool) crossroadSink {
+	switch format {
+	case "", "csv":
+		var sink = &crossroadCSV{Writer: csv.NewWriter(os.Stdout), Extended: extended}
+		printCSVHeader(sink.Writer, extended)
+		return sink
+	case "json":
+		return &crossroadJSON{}
+	case "geojson":
+		return &crossroadGeoJSON{Writer: lib.NewGeoJSONWriter(false)}
+	}
+
+	log.Println("invalid format, expected one of csv/json/geojson")
+	os.Exit(1)
+	return nil
+}
+
 // print the CSV header
-func printCSVHeader(csvWriter *csv.Writer) {
-	err := csvWriter.Write([]string{
+func printCSVHeader(csvWriter *csv.Writer, extended bool) {
+	var header = []string{
 		"source",
 		"ID",
 		"layer",
@@ -74,25 +207,143 @@ func printCSVHeader(csvWriter *csv.Writer) {
 		"lon",
 		"street",
 		"cross_street",
-	})
+	}
+	if extended {
+		header = append(header, crossroadExtendedHeader...)
+	}
+	err := csvWriter.Write(header)
 	if err != nil {
 		fmt.Println(err)
 	}
 }
 
-// print crossroad info as CSV line
-func printCSVLines(csvWriter *csv.Writer, handler *handler.Xroads, nodeid int64, uniqueWayIds []int64) {
-	var coords = handler.Coords[nodeid]
+// crossroadExtendedHeader - the columns added by --extended
+var crossroadExtendedHeader = []string{
+	"node_id",
+	"way_ids",
+	"highways",
+	"legs",
+	"leg_ways",
+	"bearings",
+	"node_ids",
+}
+
+// crossroadCSV - one row per crossroad, the extended lists are separated by ';'
+type crossroadCSV struct {
+	Writer   *csv.Writer
+	Extended bool
+}
+
+func (s *crossroadCSV) Write(xroad crossroad) {
+	var row = []string{
+		xroad.Source,
+		xroad.ID,
+		xroad.Layer,
+		fmt.Sprintf("%f", xroad.Lat),
+		fmt.Sprintf("%f", xroad.Lon),
+		xroad.Street,
+		xroad.CrossStreet,
+	}
+	if s.Extended {
+		var bearings []string
+		for _, bearing := range xroad.Bearings {
+			bearings = append(bearings, strconv.FormatFloat(bearing, 'f', 1, 64))
+		}
+		row = append(row, strconv.FormatInt(xroad.NodeID, 10),
+			joinIDs(xroad.WayIDs),
+			strings.Join(xroad.Highways, ";"),
+			strconv.Itoa(xroad.Legs),
+			joinIDs(xroad.LegWays),
+			strings.Join(bearings, ";"),
+			joinIDs(xroad.NodeIDs),
+		)
+	}
+
+	err := s.Writer.Write(row)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *crossroadCSV) Close() {
+	s.Writer.Flush()
+}
+
+// crossroadJSON - one line of JSON per crossroad
+type crossroadJSON struct{}
+
+func (s *crossroadJSON) Write(xroad crossroad) {
+	bytes, err := json.Marshal(xroad)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Println(string(bytes))
+}
+
+func (s *crossroadJSON) Close() {}
+
+// crossroadGeoJSON - a FeatureCollection of points, the crossroad fields
+// are written as properties
+type crossroadGeoJSON struct {
+	Writer *lib.GeoJSONWriter
+}
+
+func (s *crossroadGeoJSON) Write(xroad crossroad) {
+	var feature = geojson.NewPointFeature([]float64{xroad.Lon, xroad.Lat})
+	feature.ID = xroad.ID
+
+	// reuse the json encoding of the fields as properties
+	bytes, _ := json.Marshal(xroad)
+	json.Unmarshal(bytes, &feature.Properties)
+	delete(feature.Properties, "lat")
+	delete(feature.Properties, "lon")
+
+	s.Writer.WriteGeoJSON(feature)
+}
+
+func (s *crossroadGeoJSON) Close() {
+	s.Writer.Close()
+}
+
+// joinIDs - ids separated by ';'
+func joinIDs(ids []int64) string {
+	var strs = make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(strs, ";")
+}
+
+// crossroadsAt - the intersections of the streets at a node, named in the
+// first available language
+func crossroadsAt(junction *handler.Junction, langs []string) []crossroad {
+	var nodeid, uniqueWayIds, coords = junction.NodeID, junction.WayIDs, junction.Coords
 	var seen = make(map[string]bool)
+	var xroads []crossroad
+
+	// the node was not included in the file
+	if nil == coords {
+		return nil
+	}
+
+	// the highway class of each way
+	var highways = make([]string, len(uniqueWayIds))
+	for i, wayID := range uniqueWayIds {
+		highways[i] = junction.Highways[wayID]
+	}
+
+	// the bearing of each leg
+	var legWays, bearings = legBearings(junction.Nodes, coords, junction.Legs)
 
 	// generate one row per intersection
 	// (there may be multiple streets intersecting a single node)
 	for i, wayID1 := range uniqueWayIds {
 		for j, wayID2 := range uniqueWayIds {
 
-			// street names
-			var name1 = strings.TrimSpace(handler.WayNames[wayID1])
-			var name2 = strings.TrimSpace(handler.WayNames[wayID2])
+			// street names, 'addr:street' is preferred to 'name'
+			var name1 = strings.TrimSpace(tags.Localized(junction.Names[wayID1], langs, "addr:street"))
+			var name2 = strings.TrimSpace(tags.Localized(junction.Names[wayID2], langs, "addr:street"))
 
 			// normalized street names (for deduplication)
 			var norm1 = strings.ToLower(name1)
@@ -117,18 +368,111 @@ func printCSVLines(csvWriter *csv.Writer, handler *handler.Xroads, nodeid int64,
 				seen[identifier] = true
 			}
 
-			err := csvWriter.Write([]string{
-				"openstreetmap",
-				fmt.Sprintf("w%d-n%d-w%d", wayID1, nodeid, wayID2),
-				"intersection",
-				fmt.Sprintf("%f", coords.Lat),
-				fmt.Sprintf("%f", coords.Lon),
-				name1,
-				name2,
+			xroads = append(xroads, crossroad{
+				ID:               fmt.Sprintf("w%d-n%d-w%d", wayID1, nodeid, wayID2),
+				Lat:              coords.Lat,
+				Lon:              coords.Lon,
+				Street:           name1,
+				CrossStreet:      name2,
+				StreetNames:      junction.Names[wayID1],
+				CrossStreetNames: junction.Names[wayID2],
+				NodeID:           nodeid,
+				WayIDs:           uniqueWayIds,
+				Highways:         highways,
+				Legs:             len(legWays),
+				LegWays:          legWays,
+				Bearings:         bearings,
+				pair:             identifier,
+				roundabouts:      junction.Roundabouts,
 			})
-			if err != nil {
-				log.Println(err)
-			}
 		}
 	}
+
+	return xroads
+}
+
+// roundaboutLegs - the streets meeting an unnamed roundabout at a node, the
+// streets meet each other at different nodes of the roundabout so they are
+// paired when the nodes are clustered
+func roundaboutLegs(junction *handler.Junction, langs []string) []crossroad {
+	if nil == junction.Coords {
+		return nil
+	}
+
+	// the unnamed roundabouts at the node
+	var roundabouts []int64
+	var isRoundabout = make(map[int64]bool)
+	for _, wayID := range junction.Roundabouts {
+		isRoundabout[wayID] = true
+		if "" == strings.TrimSpace(tags.Localized(junction.Names[wayID], langs, "addr:street")) {
+			roundabouts = append(roundabouts, wayID)
+		}
+	}
+	if 0 == len(roundabouts) {
+		return nil
+	}
+
+	var highways = make([]string, len(junction.WayIDs))
+	for i, wayID := range junction.WayIDs {
+		highways[i] = junction.Highways[wayID]
+	}
+	var legWays, bearings = legBearings(junction.Nodes, junction.Coords, junction.Legs)
+
+	// one leg per named street
+	var seen = make(map[string]bool)
+	var legs []crossroad
+	for _, wayID := range junction.WayIDs {
+		var name = strings.TrimSpace(tags.Localized(junction.Names[wayID], langs, "addr:street"))
+		var norm = strings.ToLower(name)
+		if isRoundabout[wayID] || "" == name || seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		legs = append(legs, crossroad{
+			Lat:         junction.Coords.Lat,
+			Lon:         junction.Coords.Lon,
+			Street:      name,
+			StreetNames: junction.Names[wayID],
+			NodeID:      junction.NodeID,
+			WayIDs:      junction.WayIDs,
+			Highways:    highways,
+			Legs:        len(legWays),
+			LegWays:     legWays,
+			Bearings:    bearings,
+			pair:        norm,
+			roundabouts: roundabouts,
+			legWay:      wayID,
+		})
+	}
+	return legs
+}
+
+// legBearings - the way and bearing of each leg, clockwise from north.
+// legs towards nodes which were not included in the file are skipped
+func legBearings(coords map[int64]*gosmparse.Node, node *gosmparse.Node, legs []handler.Leg) ([]int64, []float64) {
+	var from = geo.NewPoint(node.Lon, node.Lat)
+	var sorted []handler.Leg
+	var bearings = make(map[handler.Leg]float64)
+	for _, leg := range legs {
+		if next, ok := coords[leg.NodeID]; ok {
+			sorted = append(sorted, leg)
+			bearings[leg] = bearing(from, geo.NewPoint(next.Lon, next.Lat))
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return bearings[sorted[i]] < bearings[sorted[j]] })
+
+	var legWays = make([]int64, len(sorted))
+	var legBearings = make([]float64, len(sorted))
+	for i, leg := range sorted {
+		legWays[i] = leg.WayID
+		legBearings[i] = bearings[leg]
+	}
+	return legWays, legBearings
+}
+
+// bearing - the initial bearing from a to b in degrees clockwise from north,
+// rounded to one decimal place
+func bearing(a, b *geo.Point) float64 {
+	var deg = math.Mod(a.BearingTo(b)+360, 360)
+	return math.Mod(math.Round(deg*10)/10, 360)
 }
@@ -0,0 +1,312 @@
+package command
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/missinglink/pbf/kv"
+	geo "github.com/paulmach/go.geo"
+)
+
+// the minimum height of a cluster band in metres, members of the same
+// roundabout are only clustered when they are in adjacent bands
+const clusterMinBand = 500
+
+// key prefix used in the cluster store
+const clusterRowPrefix = 'X'
+
+// crossroadCluster - group the crossroads which form a single logical
+// junction, such as a street crossing both carriageways of a dual
+// carriageway or the streets meeting at a roundabout.
+// the crossroads are spilled to the store by latitude band and flushed
+// band by band, so only the junctions of two adjacent bands are held in
+// memory.
+type crossroadCluster struct {
+	Distance float64    // metres
+	Store    kv.Backend // must not be the store being iterated while writing
+	seq      uint32
+}
+
+// clusterRow - a crossroad with the fields required for clustering
+type clusterRow struct {
+	crossroad
+	Pair        string  `json:"pair,omitempty"`
+	Roundabouts []int64 `json:"roundabouts,omitempty"`
+	LegWay      int64   `json:"leg_way,omitempty"`
+	band        int64
+}
+
+// clusterGroup - the members of a junction which may still grow
+type clusterGroup struct {
+	rows    []clusterRow
+	maxBand int64
+}
+
+// Write - spill a crossroad to the store
+func (cc *crossroadCluster) Write(xroad crossroad) error {
+	var row = clusterRow{crossroad: xroad, Pair: xroad.pair, Roundabouts: xroad.roundabouts, LegWay: xroad.legWay}
+	value, err := json.Marshal(row)
+	if nil != err {
+		return err
+	}
+	cc.seq++
+	return cc.Store.Put(clusterRowKey(cc.band(xroad.Lat), xroad.NodeID, cc.seq), value)
+}
+
+// Flush - one representative crossroad per junction, the junctions are
+// visited in latitude band order and in node id order within a band
+func (cc *crossroadCluster) Flush(fn func(xroad crossroad)) error {
+	var open []*clusterGroup
+	var band []clusterRow
+	var current int64
+	var decodeErr error
+
+	// the junctions which can no longer grow once band is reached
+	var flush = func(band int64) {
+		var done []*clusterGroup
+		var keep = open[:0]
+		for _, group := range open {
+			if group.maxBand < band-1 {
+				done = append(done, group)
+			} else {
+				keep = append(keep, group)
+			}
+		}
+		open = keep
+		var xroads []crossroad
+		for _, group := range done {
+			xroads = append(xroads, junction(group.rows)...)
+		}
+		sort.SliceStable(xroads, func(i, j int) bool { return xroads[i].NodeID < xroads[j].NodeID })
+		for _, xroad := range xroads {
+			fn(xroad)
+		}
+	}
+
+	var prefix = []byte{clusterRowPrefix}
+	err := cc.Store.Iterate(prefix, kv.PrefixLimit(prefix), func(key []byte, value []byte) bool {
+		var row clusterRow
+		if decodeErr = json.Unmarshal(value, &row); nil != decodeErr {
+			return false
+		}
+		row.crossroad.pair = row.Pair
+		row.crossroad.roundabouts = row.Roundabouts
+		row.crossroad.legWay = row.LegWay
+		row.band = int64(binary.BigEndian.Uint32(key[1:5])) - math.MaxInt32
+
+		if len(band) > 0 && row.band != current {
+			flush(current)
+			open = cc.join(open, band)
+			band = nil
+		}
+		current = row.band
+		band = append(band, row)
+		return true
+	})
+	if nil != err {
+		return err
+	}
+	if nil != decodeErr {
+		return decodeErr
+	}
+	if len(band) > 0 {
+		flush(current)
+		open = cc.join(open, band)
+	}
+	flush(math.MaxInt64)
+	return nil
+}
+
+// join - add the crossroads of a band to the open junctions, crossroads
+// on the same roundabout or of the same two streets within distance
+// form one junction
+func (cc *crossroadCluster) join(open []*clusterGroup, band []clusterRow) []*clusterGroup {
+	var groups = make([]*clusterGroup, 0, len(open)+len(band))
+	groups = append(groups, open...)
+	for _, row := range band {
+		groups = append(groups, &clusterGroup{rows: []clusterRow{row}, maxBand: row.band})
+	}
+
+	var parent = make([]int, len(groups))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	var union = func(i, j int) {
+		if a, b := find(i), find(j); a < b {
+			parent[b] = a
+		} else if b < a {
+			parent[a] = b
+		}
+	}
+
+	// index the members of each group by roundabout and street pair
+	var roundabouts = make(map[int64][]int)
+	var pairs = make(map[string][]int)
+	var members [][2]int // group, row
+	for g, group := range groups {
+		for r, row := range group.rows {
+			for _, wayID := range row.Roundabouts {
+				roundabouts[wayID] = append(roundabouts[wayID], g)
+			}
+			if 0 == row.LegWay {
+				pairs[row.Pair] = append(pairs[row.Pair], len(members))
+			}
+			members = append(members, [2]int{g, r})
+		}
+	}
+	for _, gs := range roundabouts {
+		for _, g := range gs[1:] {
+			union(gs[0], g)
+		}
+	}
+	for _, ms := range pairs {
+		for i, a := range ms {
+			var from = groups[members[a][0]].rows[members[a][1]]
+			for _, b := range ms[i+1:] {
+				var to = groups[members[b][0]].rows[members[b][1]]
+
+				// the pairs within the open groups were joined by earlier bands
+				if members[a][0] < len(open) && members[b][0] < len(open) {
+					continue
+				}
+				if geo.NewPoint(from.Lon, from.Lat).GeoDistanceFrom(geo.NewPoint(to.Lon, to.Lat), true) <= cc.Distance {
+					union(members[a][0], members[b][0])
+				}
+			}
+		}
+	}
+
+	// merge the groups, in the order they were first seen
+	var merged = make(map[int]*clusterGroup)
+	var ret []*clusterGroup
+	for g, group := range groups {
+		var root = find(g)
+		if _, ok := merged[root]; !ok {
+			merged[root] = &clusterGroup{maxBand: group.maxBand}
+			ret = append(ret, merged[root])
+		}
+		merged[root].rows = append(merged[root].rows, group.rows...)
+		if group.maxBand > merged[root].maxBand {
+			merged[root].maxBand = group.maxBand
+		}
+	}
+	return ret
+}
+
+// band - the latitude band of a crossroad, bands are at least the distance
+// high so neighbours are in the same or adjacent bands
+func (cc *crossroadCluster) band(lat float64) int64 {
+	var size = math.Max(cc.Distance, clusterMinBand) / metresPerDegree
+	return int64(math.Floor(lat / size))
+}
+
+// clusterRowKey - band, node id and sequence, the band is offset so that
+// keys sort in band order
+func clusterRowKey(band int64, nodeid int64, seq uint32) []byte {
+	var key = make([]byte, 17)
+	key[0] = clusterRowPrefix
+	binary.BigEndian.PutUint32(key[1:5], uint32(band+math.MaxInt32))
+	binary.BigEndian.PutUint64(key[5:13], uint64(nodeid))
+	binary.BigEndian.PutUint32(key[13:17], seq)
+	return key
+}
+
+// junction - the crossroads output for the members of a junction, a single
+// representative of the crossroads or, when the streets only meet an
+// unnamed roundabout, one per pair of streets at the roundabout
+func junction(rows []clusterRow) []crossroad {
+	var xroads, legs []crossroad
+	for _, row := range rows {
+		if 0 != row.LegWay {
+			legs = append(legs, row.crossroad)
+		} else {
+			xroads = append(xroads, row.crossroad)
+		}
+	}
+	if len(xroads) > 0 {
+		return []crossroad{representative(xroads)}
+	}
+
+	// the first leg of each street
+	var streets []crossroad
+	var seen = make(map[string]bool)
+	for _, leg := range legs {
+		if !seen[leg.pair] {
+			seen[leg.pair] = true
+			streets = append(streets, leg)
+		}
+	}
+
+	var centre = representative(legs)
+	var ret []crossroad
+	for i, street := range streets {
+		for _, cross := range streets[i+1:] {
+			var xroad = centre
+			xroad.ID = fmt.Sprintf("w%d-n%d-w%d", street.legWay, street.NodeID, cross.legWay)
+			xroad.Street, xroad.StreetNames = street.Street, street.StreetNames
+			xroad.CrossStreet, xroad.CrossStreetNames = cross.Street, cross.StreetNames
+			xroad.pair, xroad.legWay = street.pair+"_"+cross.pair, 0
+			ret = append(ret, xroad)
+		}
+	}
+	return ret
+}
+
+// representative - a single crossroad for the members of a junction,
+// the member nearest to the centre is used and positioned at the centre.
+// the node ids of all the members are recorded along with their ways.
+func representative(members []crossroad) crossroad {
+	var lat, lon float64
+	for _, member := range members {
+		lat += member.Lat
+		lon += member.Lon
+	}
+	lat /= float64(len(members))
+	lon /= float64(len(members))
+
+	var centre = geo.NewPoint(lon, lat)
+	var nearest = members[0]
+	for _, member := range members[1:] {
+		if centre.GeoDistanceFrom(geo.NewPoint(member.Lon, member.Lat), true) <
+			centre.GeoDistanceFrom(geo.NewPoint(nearest.Lon, nearest.Lat), true) {
+			nearest = member
+		}
+	}
+
+	var xroad = nearest
+	xroad.Lat, xroad.Lon = lat, lon
+	xroad.NodeIDs = nil
+	xroad.WayIDs = nil
+	xroad.Highways = nil
+
+	var nodes = make(map[int64]bool)
+	var ways = make(map[int64]string)
+	for _, member := range members {
+		nodes[member.NodeID] = true
+		for i, wayID := range member.WayIDs {
+			ways[wayID] = member.Highways[i]
+		}
+	}
+	for nodeID := range nodes {
+		xroad.NodeIDs = append(xroad.NodeIDs, nodeID)
+	}
+	sort.Slice(xroad.NodeIDs, func(i, j int) bool { return xroad.NodeIDs[i] < xroad.NodeIDs[j] })
+	for wayID := range ways {
+		xroad.WayIDs = append(xroad.WayIDs, wayID)
+	}
+	sort.Slice(xroad.WayIDs, func(i, j int) bool { return xroad.WayIDs[i] < xroad.WayIDs[j] })
+	for _, wayID := range xroad.WayIDs {
+		xroad.Highways = append(xroad.Highways, ways[wayID])
+	}
+	return xroad
+}
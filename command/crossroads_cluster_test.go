@@ -0,0 +1,124 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+// flushCluster - the crossroads output by a cluster
+func flushCluster(t *testing.T, cc *crossroadCluster) []crossroad {
+	var xroads []crossroad
+	assert.Nil(t, cc.Flush(func(xroad crossroad) { xroads = append(xroads, xroad) }))
+	return xroads
+}
+
+func TestCrossroadClusterDualCarriageway(t *testing.T) {
+	var cc = &crossroadCluster{Distance: 50, Store: kv.NewMemory()}
+
+	// a street crossing both carriageways, and again further along
+	cc.Write(crossroad{NodeID: 1, Lat: 0, Lon: 0, WayIDs: []int64{10, 20}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+	cc.Write(crossroad{NodeID: 2, Lat: 0.0002, Lon: 0, WayIDs: []int64{11, 20}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+	cc.Write(crossroad{NodeID: 3, Lat: 0.01, Lon: 0, WayIDs: []int64{10, 21}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+
+	// a different street nearby
+	cc.Write(crossroad{NodeID: 4, Lat: 0.0001, Lon: 0, WayIDs: []int64{10, 22}, Highways: []string{"primary", "service"}, pair: "a_c"})
+
+	var xroads = flushCluster(t, cc)
+	assert.Len(t, xroads, 3)
+
+	assert.Equal(t, []int64{1, 2}, xroads[0].NodeIDs)
+	assert.Equal(t, []int64{10, 11, 20}, xroads[0].WayIDs)
+	assert.Equal(t, []string{"primary", "primary", "residential"}, xroads[0].Highways)
+	assert.InDelta(t, 0.0001, xroads[0].Lat, 1e-9)
+
+	// junctions are output band by band
+	assert.Equal(t, []int64{4}, xroads[1].NodeIDs)
+	assert.Equal(t, []int64{3}, xroads[2].NodeIDs)
+}
+
+func TestCrossroadClusterBands(t *testing.T) {
+	var cc = &crossroadCluster{Distance: 50, Store: kv.NewMemory()}
+
+	// the same streets either side of a band edge, and south of the equator
+	var edge = clusterMinBand / metresPerDegree
+	cc.Write(crossroad{NodeID: 1, Lat: edge - 0.0001, Lon: 0, WayIDs: []int64{10, 20}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+	cc.Write(crossroad{NodeID: 2, Lat: edge + 0.0001, Lon: 0, WayIDs: []int64{11, 20}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+	cc.Write(crossroad{NodeID: 3, Lat: -0.0001, Lon: 0, WayIDs: []int64{12, 21}, Highways: []string{"primary", "residential"}, pair: "a_b"})
+
+	var xroads = flushCluster(t, cc)
+	assert.Len(t, xroads, 2)
+	assert.Equal(t, []int64{3}, xroads[0].NodeIDs)
+	assert.Equal(t, []int64{1, 2}, xroads[1].NodeIDs)
+}
+
+func TestCrossroadClusterRoundabout(t *testing.T) {
+	var cc = &crossroadCluster{Distance: 10, Store: kv.NewMemory()}
+
+	// streets meeting a roundabout more than the distance apart
+	cc.Write(crossroad{NodeID: 5, Lat: 0, Lon: 0.0003, WayIDs: []int64{30, 31}, Highways: []string{"primary", "primary"}, pair: "a_ring", roundabouts: []int64{30}})
+	cc.Write(crossroad{NodeID: 6, Lat: 0, Lon: -0.0003, WayIDs: []int64{30, 32}, Highways: []string{"primary", "primary"}, pair: "b_ring", roundabouts: []int64{30}})
+
+	var xroads = flushCluster(t, cc)
+	assert.Len(t, xroads, 1)
+	assert.Equal(t, []int64{5, 6}, xroads[0].NodeIDs)
+	assert.Equal(t, []int64{30, 31, 32}, xroads[0].WayIDs)
+	assert.InDelta(t, 0, xroads[0].Lon, 1e-9)
+}
+
+func TestCrossroadClusterUnnamedRoundabout(t *testing.T) {
+	var x = handler.NewXroads(kv.NewMemory())
+	x.TagWhiteList = map[string]bool{"primary": false, "residential": false}
+
+	// three streets meeting an unnamed roundabout at different nodes
+	var nodes = []gosmparse.Node{
+		{ID: 1, Lat: 0, Lon: 0.0003},
+		{ID: 2, Lat: 0.0003, Lon: 0},
+		{ID: 3, Lat: 0, Lon: -0.0003},
+		{ID: 4, Lat: -0.0003, Lon: 0},
+		{ID: 5, Lat: 0, Lon: 0.001},
+		{ID: 6, Lat: 0.001, Lon: 0},
+		{ID: 7, Lat: 0, Lon: -0.001},
+	}
+	var ways = []gosmparse.Way{
+		{ID: 30, NodeIDs: []int64{1, 2, 3, 4, 1}, Tags: map[string]string{"highway": "primary", "junction": "roundabout"}},
+		{ID: 31, NodeIDs: []int64{1, 5}, Tags: map[string]string{"highway": "primary", "name": "A Street"}},
+		{ID: 32, NodeIDs: []int64{2, 6}, Tags: map[string]string{"highway": "residential", "name": "B Street"}},
+		{ID: 33, NodeIDs: []int64{3, 7}, Tags: map[string]string{"highway": "residential", "name": "C Street"}},
+	}
+	for x.Pass = 0; x.Pass < 3; x.Pass++ {
+		for _, node := range nodes {
+			x.ReadNode(node)
+		}
+		for _, way := range ways {
+			x.ReadWay(way)
+		}
+	}
+
+	// no crossroads are output without clustering
+	var cc = &crossroadCluster{Distance: 10, Store: kv.NewMemory()}
+	assert.Nil(t, x.Junctions(func(junction *handler.Junction) {
+		assert.Empty(t, crossroadsAt(junction, nil))
+		for _, xroad := range roundaboutLegs(junction, nil) {
+			assert.Nil(t, cc.Write(xroad))
+		}
+	}))
+
+	// one crossroad per pair of streets at the centre of the roundabout
+	var xroads = flushCluster(t, cc)
+	assert.Len(t, xroads, 3)
+	var pairs []string
+	for _, xroad := range xroads {
+		pairs = append(pairs, xroad.Street+" & "+xroad.CrossStreet)
+		assert.Equal(t, []int64{1, 2, 3}, xroad.NodeIDs)
+		assert.Equal(t, []int64{30, 31, 32, 33}, xroad.WayIDs)
+		assert.InDelta(t, 0.0001, xroad.Lat, 1e-9)
+		assert.InDelta(t, 0, xroad.Lon, 1e-9)
+	}
+	assert.Equal(t, []string{"A Street & B Street", "A Street & C Street", "B Street & C Street"}, pairs)
+	assert.Equal(t, "w31-n1-w32", xroads[0].ID)
+	assert.Equal(t, "B Street", xroads[0].CrossStreetNames["name"])
+}
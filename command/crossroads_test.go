@@ -0,0 +1,116 @@
+package command
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+// crossroadsHandler - run the handler passes over a T junction
+func crossroadsHandler(store kv.Backend) *handler.Xroads {
+	var x = handler.NewXroads(store)
+	x.TagWhiteList = map[string]bool{"primary": false, "residential": false}
+
+	var nodes = []gosmparse.Node{
+		{ID: 1, Lat: 0, Lon: -0.001},
+		{ID: 2, Lat: 0, Lon: 0},
+		{ID: 3, Lat: 0, Lon: 0.001},
+		{ID: 4, Lat: -0.001, Lon: 0},
+	}
+	var ways = []gosmparse.Way{
+		{ID: 10, NodeIDs: []int64{1, 2, 3}, Tags: map[string]string{"highway": "primary", "name": "Main St", "addr:street": "Main Street"}},
+		{ID: 11, NodeIDs: []int64{2, 4}, Tags: map[string]string{"highway": "residential", "name": "Side Street", "name:fr": "Rue"}},
+		{ID: 12, NodeIDs: []int64{3, 4}, Tags: map[string]string{"highway": "footway", "name": "Path"}},
+	}
+
+	for x.Pass = 0; x.Pass < 3; x.Pass++ {
+		for _, node := range nodes {
+			x.ReadNode(node)
+		}
+		for _, way := range ways {
+			x.ReadWay(way)
+		}
+	}
+	return x
+}
+
+func TestCrossroadsAt(t *testing.T) {
+	var x = crossroadsHandler(kv.NewMemory())
+	assert.True(t, x.IsIntersection(2))
+	assert.False(t, x.IsIntersection(3))
+	assert.False(t, x.IsIntersection(4))
+
+	var junctions []*handler.Junction
+	assert.Nil(t, x.Junctions(func(junction *handler.Junction) {
+		junctions = append(junctions, junction)
+	}))
+	assert.Len(t, junctions, 1)
+	assert.Equal(t, int64(2), junctions[0].NodeID)
+	assert.Equal(t, []int64{10, 11}, junctions[0].WayIDs)
+
+	var xroads = crossroadsAt(junctions[0], []string{"fr"})
+	assert.Len(t, xroads, 1)
+
+	var xroad = xroads[0]
+	assert.Equal(t, "w10-n2-w11", xroad.ID)
+	assert.Equal(t, "Main Street", xroad.Street)
+	assert.Equal(t, "Rue", xroad.CrossStreet)
+	assert.Equal(t, "Side Street", xroad.CrossStreetNames["name"])
+	assert.Equal(t, "Main St", xroad.StreetNames["name"])
+	assert.Equal(t, "Main Street", xroad.StreetNames["addr:street"])
+	assert.Equal(t, int64(2), xroad.NodeID)
+	assert.Equal(t, []string{"primary", "residential"}, xroad.Highways)
+	assert.Equal(t, 3, xroad.Legs)
+	assert.Equal(t, []int64{10, 11, 10}, xroad.LegWays)
+	assert.Equal(t, []float64{90, 180, 270}, xroad.Bearings)
+}
+
+func TestCrossroadCSV(t *testing.T) {
+	var xroad = crossroad{Source: "openstreetmap", ID: "polyline:1", Layer: "intersection", Street: "Main Street", CrossStreet: "Side Street", NodeID: 2, WayIDs: []int64{10, 11}, Highways: []string{"primary", "residential"}, Legs: 3, LegWays: []int64{11, 10, 10}, Bearings: []float64{0, 90, 270}}
+
+	// the default columns are unchanged
+	var buf bytes.Buffer
+	var sink = &crossroadCSV{Writer: csv.NewWriter(&buf)}
+	printCSVHeader(sink.Writer, false)
+	sink.Write(xroad)
+	sink.Close()
+	assert.Equal(t, "source,ID,layer,lat,lon,street,cross_street\nopenstreetmap,polyline:1,intersection,0.000000,0.000000,Main Street,Side Street\n", buf.String())
+
+	// extended columns
+	buf.Reset()
+	sink = &crossroadCSV{Writer: csv.NewWriter(&buf), Extended: true}
+	printCSVHeader(sink.Writer, true)
+	sink.Write(xroad)
+	sink.Close()
+	rows, err := csv.NewReader(&buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+	assert.Len(t, rows[0], 14)
+	assert.Equal(t, []string{"2", "10;11", "primary;residential", "3", "11;10;10", "0.0;90.0;270.0", ""}, rows[1][7:])
+}
+
+// failingStore - a store which fails every write
+type failingStore struct {
+	kv.Backend
+}
+
+func (s *failingStore) Put(key []byte, value []byte) error {
+	return errors.New("disk full")
+}
+
+func (s *failingStore) Write(batch *kv.Batch) error {
+	return errors.New("disk full")
+}
+
+func TestCrossroadsStoreError(t *testing.T) {
+	assert.Nil(t, crossroadsHandler(kv.NewMemory()).Err())
+
+	var x = crossroadsHandler(&failingStore{Backend: kv.NewMemory()})
+	assert.EqualError(t, x.Err(), "disk full")
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/parser"
+
+	"github.com/urfave/cli"
+)
+
+// GeoJSON cli command
+func GeoJSON(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {pbf}")
+		os.Exit(1)
+	}
+
+	// select output format
+	var seq bool
+	switch c.String("format") {
+	case "", "collection":
+	case "seq":
+		seq = true
+	default:
+		log.Println("invalid format, expected one of collection/seq")
+		os.Exit(1)
+	}
+
+	// create parser
+	parser := parser.NewParser(argv[0])
+
+	// create writer
+	writer := lib.NewGeoJSONWriter(seq)
+	defer writer.Close()
+
+	// build and write geometries
+	writeGeometries(c, parser, writer, configFeatures(c))
+
+	return nil
+}
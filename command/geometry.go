@@ -0,0 +1,51 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/parser"
+
+	"github.com/urfave/cli"
+)
+
+// configFeatures - load the feature config named by the --config flag,
+// returns nil when no config was specified
+func configFeatures(c *cli.Context) *lib.FeatureSet {
+	if "" == c.String("config") {
+		return nil
+	}
+	features, err := lib.NewFeatureSetFromJSON(c.String("config"))
+	if nil != err {
+		log.Println("config error", err)
+		os.Exit(1)
+	}
+	return features
+}
+
+// writeGeometries - build geometries for the elements in the pbf file and
+// write them to writer, shared by the commands which export geometries,
+// only elements matching features are written unless features is nil
+func writeGeometries(c *cli.Context, p *parser.Parser, writer lib.FeatureWriter, features *lib.FeatureSet) {
+
+	// open location store
+	locations := openLocations(c, nil, "", false)
+	defer locations.Close()
+
+	// one pass per stage, see handler.Geometry
+	var handle = handler.NewGeometry(writer, locations)
+	handle.Features = features
+	for handle.Pass = 0; handle.Pass < 4; handle.Pass++ {
+		if handle.Pass > 0 {
+			p.Reset()
+		}
+
+		// Parse will block until it is done or an error occurs.
+		p.Parse(handle)
+	}
+
+	// write relations
+	handle.Flush()
+}
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/missinglink/pbf/gpkg"
+	"github.com/missinglink/pbf/parser"
+
+	"github.com/urfave/cli"
+)
+
+// column names used by the feature tables which cannot be used for tags
+var reservedColumns = map[string]bool{"fid": true, "geom": true, "osm_type": true, "osm_id": true}
+
+// GeoPackage cli command
+func GeoPackage(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 2 {
+		log.Println("invalid arguments, expected: {pbf} {gpkg}")
+		os.Exit(1)
+	}
+
+	// create parser
+	parser := parser.NewParser(argv[0])
+
+	// don't clobber existing db file
+	if _, err := os.Stat(argv[1]); err == nil {
+		log.Println("geopackage already exists; don't want to override it")
+		os.Exit(1)
+	}
+
+	// open database connection
+	conn := &gpkg.Connection{}
+	conn.Open(argv[1])
+	defer conn.Close()
+
+	// create feature tables
+	writer := gpkg.NewWriter(conn, tagColumns(c))
+	defer writer.Close()
+
+	// build and write geometries
+	writeGeometries(c, parser, writer, configFeatures(c))
+
+	return nil
+}
+
+// tagColumns - tag keys to write as columns, taken from the keys used by the
+// feature config and the --columns flag, defaulting to 'name'
+func tagColumns(c *cli.Context) []string {
+	var set = make(map[string]bool)
+
+	if features := configFeatures(c); nil != features {
+		for _, key := range features.Keys() {
+			set[key] = true
+		}
+	}
+
+	for _, key := range strings.Split(c.String("columns"), ",") {
+		if key = strings.TrimSpace(key); "" != key {
+			set[key] = true
+		}
+	}
+
+	if len(set) == 0 {
+		set["name"] = true
+	}
+
+	var keys = make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return uniqueColumns(keys)
+}
+
+// uniqueColumns - sorted column names with the reserved names removed,
+// sqlite identifiers are case-insensitive so keys which only differ by
+// case (such as 'name' and 'NAME') share the first column in sort order
+func uniqueColumns(keys []string) []string {
+	sort.Strings(keys)
+
+	var seen = make(map[string]string)
+	var columns = make([]string, 0, len(keys))
+	for _, key := range keys {
+		var folded = strings.ToLower(key)
+		if reservedColumns[folded] {
+			log.Printf("tag '%s' cannot be used as a column name, skipping\n", key)
+			continue
+		}
+		if first, ok := seen[folded]; ok {
+			log.Printf("tag '%s' has the same column name as '%s', skipping\n", key, first)
+			continue
+		}
+		seen[folded] = key
+		columns = append(columns, key)
+	}
+
+	return columns
+}
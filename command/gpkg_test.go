@@ -0,0 +1,12 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueColumns(t *testing.T) {
+	assert.Equal(t, []string{"NAME", "highway"}, uniqueColumns([]string{"name", "highway", "NAME", "Name"}))
+	assert.Equal(t, []string{"ref"}, uniqueColumns([]string{"FID", "geom", "OSM_ID", "ref"}))
+}
@@ -0,0 +1,214 @@
+package command
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+	"github.com/missinglink/pbf/parser"
+
+	geo "github.com/paulmach/go.geo"
+	"github.com/urfave/cli"
+)
+
+// interpolatedAddress - a synthetic address point on an interpolation way,
+// between the address nodes FromNodeID and ToNodeID of way WayID
+type interpolatedAddress struct {
+	WayID         int64   `json:"way_id"`
+	FromNodeID    int64   `json:"from_node_id"`
+	ToNodeID      int64   `json:"to_node_id"`
+	Interpolation string  `json:"interpolation"`
+	HouseNumber   string  `json:"housenumber"`
+	Street        string  `json:"street,omitempty"`
+	Postcode      string  `json:"postcode,omitempty"`
+	City          string  `json:"city,omitempty"`
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+}
+
+// Interpolate cli command
+func Interpolate(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {pbf}")
+		os.Exit(1)
+	}
+
+	// output
+	var write func(address interpolatedAddress)
+	switch strings.ToLower(c.String("format")) {
+	case "", "json":
+		write = printInterpolatedJSON
+	case "csv":
+		var writer = csv.NewWriter(os.Stdout)
+		defer writer.Flush()
+		printInterpolatedCSVHeader(writer)
+		write = func(address interpolatedAddress) { printInterpolatedCSV(writer, address) }
+	default:
+		log.Println("invalid format, expected one of json/csv")
+		os.Exit(1)
+	}
+
+	// open location store
+	locations := openLocations(c, nil, "", false)
+	defer locations.Close()
+
+	// create parser
+	parser := parser.NewParser(argv[0])
+
+	// one pass per stage, see handler.Interpolation
+	var handle = handler.NewInterpolation(locations)
+	for handle.Pass = 0; handle.Pass < 2; handle.Pass++ {
+		if handle.Pass > 0 {
+			parser.Reset()
+		}
+		parser.Parse(handle)
+	}
+
+	// expand each interpolation way
+	for _, way := range handle.Ways {
+		for _, address := range interpolateWay(way, locations, handle.Addresses) {
+			write(address)
+		}
+	}
+
+	return nil
+}
+
+// interpolateWay - the addresses between each pair of consecutive address
+// nodes along an interpolation way, evenly spaced along the way.
+// segments with an endpoint which is missing a location or a house number
+// which cannot be interpolated are skipped.
+func interpolateWay(way gosmparse.Way, locations location.Store, addresses map[int64]map[string]string) []interpolatedAddress {
+	var scheme = strings.TrimSpace(way.Tags["addr:interpolation"])
+	var wayAddress = handler.AddressTags(way.Tags)
+	var result []interpolatedAddress
+
+	var from = -1
+	var fromNumber lib.HouseNumber
+	for i, nodeid := range way.NodeIDs {
+		number, ok := lib.ParseHouseNumber(addresses[nodeid]["addr:housenumber"])
+		if !ok {
+			continue
+		}
+		if from >= 0 {
+			var numbers = lib.InterpolateHouseNumbers(fromNumber, number, scheme)
+			var points = interpolatePoints(way.NodeIDs[from:i+1], locations, len(numbers))
+			for n, point := range points {
+				var fromNodeID, toNodeID = way.NodeIDs[from], nodeid
+				result = append(result, interpolatedAddress{
+					WayID:         way.ID,
+					FromNodeID:    fromNodeID,
+					ToNodeID:      toNodeID,
+					Interpolation: scheme,
+					HouseNumber:   numbers[n].String(),
+					Street:        firstAddressTag("addr:street", wayAddress, addresses[fromNodeID], addresses[toNodeID]),
+					Postcode:      firstAddressTag("addr:postcode", wayAddress, addresses[fromNodeID], addresses[toNodeID]),
+					City:          firstAddressTag("addr:city", wayAddress, addresses[fromNodeID], addresses[toNodeID]),
+					Lat:           point.Lat(),
+					Lon:           point.Lng(),
+				})
+			}
+		}
+		from, fromNumber = i, number
+	}
+	return result
+}
+
+// interpolatePoints - count points evenly spaced along the path through
+// nodeids, excluding the endpoints. returns nil if a location is missing.
+func interpolatePoints(nodeids []int64, locations location.Store, count int) []*geo.Point {
+	if 0 == count {
+		return nil
+	}
+
+	// scale longitudes so that the spacing is even on the ground
+	var path = geo.NewPath()
+	var scale float64
+	for _, nodeid := range nodeids {
+		node, err := locations.ReadCoord(nodeid)
+		if nil != err || nil == node {
+			return nil
+		}
+		if 0 == scale {
+			scale = math.Cos(node.Lat * math.Pi / 180)
+		}
+		path.Push(geo.NewPoint(node.Lon*scale, node.Lat))
+	}
+
+	var points = make([]*geo.Point, count)
+	for i := range points {
+		var point = path.Interpolate(float64(i+1) / float64(count+1))
+		points[i] = geo.NewPoint(point.X()/scale, point.Y())
+	}
+	return points
+}
+
+// firstAddressTag - the value of key in the first address which has it
+func firstAddressTag(key string, addresses ...map[string]string) string {
+	for _, address := range addresses {
+		if val, ok := address[key]; ok {
+			return val
+		}
+	}
+	return ""
+}
+
+// printInterpolatedJSON - one line of JSON per address
+func printInterpolatedJSON(address interpolatedAddress) {
+	bytes, err := json.Marshal(address)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	fmt.Println(string(bytes))
+}
+
+// print the CSV header
+func printInterpolatedCSVHeader(csvWriter *csv.Writer) {
+	err := csvWriter.Write([]string{
+		"way_id",
+		"from_node_id",
+		"to_node_id",
+		"interpolation",
+		"housenumber",
+		"street",
+		"postcode",
+		"city",
+		"lat",
+		"lon",
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// printInterpolatedCSV - one row per address
+func printInterpolatedCSV(csvWriter *csv.Writer, address interpolatedAddress) {
+	err := csvWriter.Write([]string{
+		strconv.FormatInt(address.WayID, 10),
+		strconv.FormatInt(address.FromNodeID, 10),
+		strconv.FormatInt(address.ToNodeID, 10),
+		address.Interpolation,
+		address.HouseNumber,
+		address.Street,
+		address.Postcode,
+		address.City,
+		fmt.Sprintf("%f", address.Lat),
+		fmt.Sprintf("%f", address.Lon),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/location"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateWay(t *testing.T) {
+
+	var locations = location.NewMemory()
+	locations.WriteCoord(gosmparse.Node{ID: 1, Lat: 0, Lon: 0})
+	locations.WriteCoord(gosmparse.Node{ID: 2, Lat: 0, Lon: 0.001})
+	locations.WriteCoord(gosmparse.Node{ID: 3, Lat: 0, Lon: 0.002})
+	locations.WriteCoord(gosmparse.Node{ID: 4, Lat: 0, Lon: 0.004})
+
+	var addresses = map[int64]map[string]string{
+		1: {"addr:housenumber": "2", "addr:street": "Main Street"},
+		3: {"addr:housenumber": "6", "addr:postcode": "1234"},
+		4: {"addr:housenumber": "12"},
+	}
+
+	// node 2 is not an address, node 3 splits the way in to two segments
+	var way = gosmparse.Way{
+		ID:      10,
+		NodeIDs: []int64{1, 2, 3, 4},
+		Tags:    map[string]string{"addr:interpolation": "even", "addr:city": "Town"},
+	}
+	var result = interpolateWay(way, locations, addresses)
+	assert.Len(t, result, 3)
+
+	assert.Equal(t, interpolatedAddress{
+		WayID:         10,
+		FromNodeID:    1,
+		ToNodeID:      3,
+		Interpolation: "even",
+		HouseNumber:   "4",
+		Street:        "Main Street",
+		Postcode:      "1234",
+		City:          "Town",
+		Lat:           0,
+		Lon:           0.001,
+	}, result[0])
+
+	assert.Equal(t, "8", result[1].HouseNumber)
+	assert.Equal(t, int64(3), result[1].FromNodeID)
+	assert.Equal(t, int64(4), result[1].ToNodeID)
+	assert.InDelta(t, 0.0026667, result[1].Lon, 1e-6)
+	assert.Equal(t, "10", result[2].HouseNumber)
+	assert.InDelta(t, 0.0033333, result[2].Lon, 1e-6)
+
+	// a segment with a missing location is skipped
+	way.NodeIDs = []int64{1, 5, 3}
+	assert.Len(t, interpolateWay(way, locations, addresses), 0)
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 
 	"github.com/missinglink/pbf/handler"
-	"github.com/missinglink/pbf/leveldb"
 	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/parser"
 	"github.com/missinglink/pbf/proxy"
@@ -42,13 +41,16 @@ func JSONFlat(c *cli.Context) error {
 	lib.EnsureDirectoryExists(leveldbPath, "leveldb")
 
 	// open database connection
-	conn := &leveldb.Connection{}
-	conn.Open(leveldbPath)
+	conn := openConnection(c, leveldbPath, false)
 	defer conn.Close()
 
+	// open location store
+	locations := openLocations(c, conn, leveldbPath, false)
+	defer closeLocations(locations, conn)
+
 	// create parser handler
 	var handle = &handler.DenormalizedJSON{
-		Conn:            conn,
+		Locations:       locations,
 		Writer:          lib.NewBufferedWriter(),
 		ComputeCentroid: c.BoolT("centroid"),
 		ComputeGeohash:  c.Bool("geohash"),
@@ -59,7 +61,7 @@ func JSONFlat(c *cli.Context) error {
 	defer handle.Writer.Close()
 
 	// create db writer routine
-	writer := leveldb.NewCoordWriter(conn)
+	writer := newLocationWriter(locations)
 
 	// ensure all node refs are written to disk before starting on the ways
 	dec := p.GetDecoder()
@@ -3,9 +3,9 @@ package command
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/missinglink/pbf/handler"
-	"github.com/missinglink/pbf/leveldb"
 	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/parser"
 	"github.com/missinglink/pbf/proxy"
@@ -30,13 +30,23 @@ func LevelDB(c *cli.Context) error {
 	lib.EnsureDirectoryExists(argv[1], "leveldb")
 
 	// open database connection
-	conn := &leveldb.Connection{}
-	conn.Open(argv[1])
+	conn := openConnection(c, argv[1], true)
 	defer conn.Close()
 
 	// create parser handler
 	var handle = &handler.LevelDB{Conn: conn}
 
+	// maintain secondary tag indexes
+	if "" != c.String("index") {
+		if err := conn.AddIndexKeys(strings.Split(c.String("index"), ",")); nil != err {
+			log.Println("failed to configure index", err)
+			os.Exit(1)
+		}
+	}
+	if len(conn.IndexKeys()) > 0 {
+		handle.Index = conn
+	}
+
 	// check if a bitmask is to be used
 	var bitmaskPath = c.String("bitmask")
 
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/lib"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/urfave/cli"
+)
+
+// LevelDBExport cli command
+func LevelDBExport(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {leveldb}")
+		os.Exit(1)
+	}
+
+	// stat leveldb destination
+	lib.EnsureDirectoryExists(argv[0], "leveldb")
+
+	// open database connection
+	conn := openConnection(c, argv[0], true)
+	defer conn.Close()
+
+	// create handler for the selected format
+	var handle gosmparse.OSMReader
+	switch c.String("format") {
+	case "", "json":
+		var json = &handler.JSON{Writer: lib.NewBufferedWriter()}
+		defer json.Writer.Close()
+		handle = json
+	case "xml":
+		handle = &handler.XML{Mutex: &sync.Mutex{}}
+
+		// write header
+		fmt.Println("<?xml version=\"1.0\" encoding=\"UTF-8\"?>")
+		fmt.Println("<osm version=\"0.6\" generator=\"missinglink/pbf\">")
+
+		// write footer
+		defer fmt.Println("</osm>")
+	case "opl":
+		handle = &handler.OPL{Mutex: &sync.Mutex{}}
+	default:
+		log.Println("invalid format, expected one of: json, xml, opl")
+		os.Exit(1)
+	}
+
+	if err := exportElements(conn, handle); nil != err {
+		log.Println("export failed", err)
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+// exportElements - read every node, way and relation in to handle, stopping
+// on the first decoding error before the next element type is started
+func exportElements(conn *leveldb.Connection, handle gosmparse.OSMReader) error {
+	var failed error
+
+	conn.IterateNodes(nil, func(node *gosmparse.Node, err error) bool {
+		if nil != err {
+			failed = err
+			return false
+		}
+		handle.ReadNode(*node)
+		return true
+	})
+	if nil != failed {
+		return failed
+	}
+
+	conn.IterateWays(nil, func(way *gosmparse.Way, err error) bool {
+		if nil != err {
+			failed = err
+			return false
+		}
+		handle.ReadWay(*way)
+		return true
+	})
+	if nil != failed {
+		return failed
+	}
+
+	conn.IterateRelations(nil, func(relation *gosmparse.Relation, err error) bool {
+		if nil != err {
+			failed = err
+			return false
+		}
+		handle.ReadRelation(*relation)
+		return true
+	})
+	return failed
+}
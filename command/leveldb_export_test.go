@@ -0,0 +1,52 @@
+package command
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/kv"
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportElementsCorruptNode(t *testing.T) {
+	var conn = &leveldb.Connection{}
+	conn.OpenBackend(kv.NewMemory())
+	assert.Nil(t, conn.WriteNode(gosmparse.Node{ID: 1, Lat: 1, Lon: 1}))
+	assert.Nil(t, conn.WriteWay(gosmparse.Way{ID: 2, NodeIDs: []int64{1}}))
+	assert.Nil(t, conn.WriteRelation(gosmparse.Relation{ID: 3}))
+
+	var export = func() (*handler.ReadAll, error) {
+		var handle = &handler.ReadAll{
+			Mutex:     &sync.Mutex{},
+			Nodes:     make(map[int64]gosmparse.Node),
+			Ways:      make(map[int64]gosmparse.Way),
+			Relations: make(map[int64]gosmparse.Relation),
+		}
+		return handle, exportElements(conn, handle)
+	}
+
+	handle, err := export()
+	assert.Nil(t, err)
+	assert.Len(t, handle.Nodes, 1)
+	assert.Len(t, handle.Ways, 1)
+	assert.Len(t, handle.Relations, 1)
+
+	// corrupt the node record
+	var nodeKey []byte
+	conn.DB.Iterate([]byte{'N'}, kv.PrefixLimit([]byte{'N'}), func(key []byte, value []byte) bool {
+		nodeKey = append([]byte{}, key...)
+		return false
+	})
+	assert.NotNil(t, nodeKey)
+	assert.Nil(t, conn.DB.Put(nodeKey, []byte{0xff}))
+
+	// ways and relations are not exported after the failure
+	handle, err = export()
+	assert.NotNil(t, err)
+	assert.Len(t, handle.Nodes, 0)
+	assert.Len(t, handle.Ways, 0)
+	assert.Len(t, handle.Relations, 0)
+}
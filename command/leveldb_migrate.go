@@ -0,0 +1,38 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/lib"
+
+	"github.com/urfave/cli"
+)
+
+// LevelDBMigrate cli command
+func LevelDBMigrate(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 1 {
+		log.Println("invalid arguments, expected: {leveldb}")
+		os.Exit(1)
+	}
+
+	// stat leveldb destination
+	lib.EnsureDirectoryExists(argv[0], "leveldb")
+
+	// open database connection
+	conn := &leveldb.Connection{}
+	conn.Open(argv[0])
+	defer conn.Close()
+
+	// rewrite database using the current schema
+	if err := conn.Migrate(); nil != err {
+		log.Println("migration failed", err)
+		os.Exit(1)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package command
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/missinglink/pbf/handler"
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/lib"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/urfave/cli"
+)
+
+// LevelDBQuery cli command
+func LevelDBQuery(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 2 {
+		log.Println("invalid arguments, expected: {leveldb} {expression}")
+		os.Exit(1)
+	}
+
+	// parse expression
+	group, err := lib.ParseExpression(argv[1])
+	if nil != err {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// element types to query
+	var types = []string{"node", "way", "relation"}
+	if "" != c.String("type") {
+		types = strings.Split(c.String("type"), ",")
+		for _, typ := range types {
+			if "node" != typ && "way" != typ && "relation" != typ {
+				log.Println("invalid type, expected one of: node, way, relation")
+				os.Exit(1)
+			}
+		}
+	}
+
+	// stat leveldb destination
+	lib.EnsureDirectoryExists(argv[0], "leveldb")
+
+	// open database connection
+	conn := openConnection(c, argv[0], true)
+	defer conn.Close()
+
+	// create output handler
+	var handle = &handler.JSON{Writer: lib.NewBufferedWriter()}
+	defer handle.Writer.Close()
+
+	for _, typ := range types {
+
+		// an element may match more than one pattern
+		var seen = make(map[int64]bool)
+
+		for _, pattern := range group {
+			queryPattern(conn, typ, pattern, seen, handle)
+		}
+	}
+
+	return nil
+}
+
+// queryPattern - find candidate elements for a pattern, using an index when
+// one of its conditions is indexed, otherwise falling back to a full scan
+func queryPattern(conn *leveldb.Connection, typ string, pattern lib.Pattern, seen map[int64]bool, handle *handler.JSON) {
+
+	// write each matching element once
+	var visit = func(id int64, tags map[string]string, write func()) {
+		if seen[id] || !pattern.Match(tags) {
+			return
+		}
+		seen[id] = true
+		write()
+	}
+
+	// find the first indexed condition
+	var ids []int64
+	var indexed = false
+	for _, condition := range pattern {
+		if ids, indexed = conn.QueryIndex(typ, condition.Key(), condition.Value()); indexed {
+			break
+		}
+	}
+
+	// full scan
+	if !indexed {
+		log.Printf("no indexed key in '%s', scanning all %ss\n", joinPattern(pattern), typ)
+		var opts = &leveldb.IterateOptions{Filter: pattern.Match}
+		switch typ {
+		case "node":
+			conn.IterateNodes(opts, func(item *gosmparse.Node, err error) bool {
+				if nil == err {
+					visit(item.ID, item.Tags, func() { handle.ReadNode(*item) })
+				}
+				return nil == err
+			})
+		case "way":
+			conn.IterateWays(opts, func(item *gosmparse.Way, err error) bool {
+				if nil == err {
+					visit(item.ID, item.Tags, func() { handle.ReadWay(*item) })
+				}
+				return nil == err
+			})
+		case "relation":
+			conn.IterateRelations(opts, func(item *gosmparse.Relation, err error) bool {
+				if nil == err {
+					visit(item.ID, item.Tags, func() { handle.ReadRelation(*item) })
+				}
+				return nil == err
+			})
+		}
+		return
+	}
+
+	// read each candidate from the index
+	for _, id := range ids {
+		switch typ {
+		case "node":
+			if item, err := conn.ReadNode(id); nil == err {
+				visit(item.ID, item.Tags, func() { handle.ReadNode(*item) })
+			}
+		case "way":
+			if item, err := conn.ReadWay(id); nil == err {
+				visit(item.ID, item.Tags, func() { handle.ReadWay(*item) })
+			}
+		case "relation":
+			if item, err := conn.ReadRelation(id); nil == err {
+				visit(item.ID, item.Tags, func() { handle.ReadRelation(*item) })
+			}
+		}
+	}
+}
+
+// joinPattern - format a pattern for display
+func joinPattern(pattern lib.Pattern) string {
+	var parts = make([]string, 0, len(pattern))
+	for _, condition := range pattern {
+		parts = append(parts, string(condition))
+	}
+	return strings.Join(parts, " & ")
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/location"
+
+	"github.com/urfave/cli"
+)
+
+// openLocations - open the node location store selected by the --locations flag
+// note: when persistent is true the store must survive after the process exits,
+// conn may be nil for commands which don't use a leveldb database
+func openLocations(c *cli.Context, conn *leveldb.Connection, leveldbPath string, persistent bool) location.Store {
+
+	switch c.String("locations") {
+	case "", "leveldb":
+
+		// commands without a leveldb store locations in memory by default
+		if nil == conn {
+			if "leveldb" == c.String("locations") {
+				log.Println("leveldb location store is not available for this command")
+				os.Exit(1)
+			}
+			return location.NewMemory()
+		}
+		return conn
+
+	case "flat":
+		var path = c.String("locations-file")
+		if "" == path {
+			if "" == leveldbPath {
+				log.Println("--locations-file is required when using --locations=flat")
+				os.Exit(1)
+			}
+			path = filepath.Join(leveldbPath, "locations.flat")
+		}
+		store, err := location.OpenFlat(path)
+		if nil != err {
+			log.Println("failed to open flat location store", err)
+			os.Exit(1)
+		}
+		return store
+
+	case "memory":
+		if persistent {
+			log.Println("memory location store is not persisted, please use --locations=leveldb or --locations=flat")
+			os.Exit(1)
+		}
+		return location.NewMemory()
+
+	default:
+		log.Println("invalid locations store, expected one of: leveldb, flat, memory")
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+// newLocationWriter - create a writer suitable for the store
+func newLocationWriter(store location.Store) location.Writer {
+
+	// leveldb performs best when writes are batched
+	if conn, ok := store.(*leveldb.Connection); ok {
+		return leveldb.NewCoordWriter(conn)
+	}
+
+	return location.NewSyncWriter(store)
+}
+
+// closeLocations - close the store unless it is shared with the leveldb connection
+func closeLocations(store location.Store, conn *leveldb.Connection) {
+	if shared, ok := store.(*leveldb.Connection); ok && shared == conn {
+		return
+	}
+	store.Close()
+}
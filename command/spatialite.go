@@ -0,0 +1,45 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/missinglink/pbf/parser"
+	"github.com/missinglink/pbf/spatialite"
+
+	"github.com/urfave/cli"
+)
+
+// Spatialite cli command
+func Spatialite(c *cli.Context) error {
+
+	// validate args
+	var argv = c.Args()
+	if len(argv) != 2 {
+		log.Println("invalid arguments, expected: {pbf} {db}")
+		os.Exit(1)
+	}
+
+	// create parser
+	parser := parser.NewParser(argv[0])
+
+	// don't clobber existing db file
+	if _, err := os.Stat(argv[1]); err == nil {
+		log.Println("spatialite database already exists; don't want to override it")
+		os.Exit(1)
+	}
+
+	// open database connection
+	conn := &spatialite.Connection{}
+	conn.Open(argv[1])
+	defer conn.Close()
+
+	// create tables
+	writer := spatialite.NewWriter(conn)
+	defer writer.Close()
+
+	// build and write geometries
+	writeGeometries(c, parser, writer, configFeatures(c))
+
+	return nil
+}
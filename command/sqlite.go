@@ -27,16 +27,52 @@ func Sqlite3(c *cli.Context) error {
 	parser := parser.NewParser(argv[0])
 
 	// don't clobber existing db file
-	if _, err := os.Stat(argv[1]); err == nil {
-		log.Println("sqlite database already exists; don't want to override it")
+	var appending = c.Bool("append")
+	if _, err := os.Stat(argv[1]); err == nil && !appending {
+		log.Println("sqlite database already exists; don't want to override it, use --append to add to it")
 		os.Exit(1)
 	}
 
+	// style mapping writes feature tables instead of the element tables
+	if "" != c.String("style") {
+		if appending || "" != c.String("bitmask") {
+			log.Println("--style cannot be combined with --append or --bitmask")
+			os.Exit(1)
+		}
+		sqlite3Style(c, parser, argv[0], argv[1])
+		return nil
+	}
+
+	// only databases using the current schema can be appended to
+	if appending {
+		version, err := sqlite.ReadVersion(argv[1])
+		if nil != err {
+			log.Println(err)
+			os.Exit(1)
+		}
+		if version != 0 && version != sqlite.SchemaVersion {
+			log.Printf("sqlite database uses schema v%d, only v%d databases can be appended to\n", version, sqlite.SchemaVersion)
+			os.Exit(1)
+		}
+	}
+
 	// open database connection
 	conn := &sqlite.Connection{}
 	conn.Open(argv[1])
 	defer conn.Close()
 
+	// record metadata
+	if err := conn.RecordSource(argv[0]); nil != err {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// indexes of an existing database are not maintained while appending
+	if err := conn.DropIndexes(); nil != err {
+		log.Println("failed to drop indexes", err)
+		os.Exit(1)
+	}
+
 	// create parser handler
 	handle := &handler.Sqlite3{Conn: conn}
 
@@ -49,23 +85,54 @@ func Sqlite3(c *cli.Context) error {
 		// Parse will block until it is done or an error occurs.
 		parser.Parse(handle)
 
-		return nil
-	}
+	} else {
+
+		// read bitmask from disk
+		masks := lib.NewBitmaskMap()
+		masks.ReadFromFile(bitmaskPath)
 
-	// read bitmask from disk
-	masks := lib.NewBitmaskMap()
-	masks.ReadFromFile(bitmaskPath)
+		// create filter proxy
+		filter := &proxy.WhiteList{
+			Handler:      handle,
+			NodeMask:     masks.Nodes,
+			WayMask:      masks.Ways,
+			RelationMask: masks.Relations,
+		}
 
-	// create filter proxy
-	filter := &proxy.WhiteList{
-		Handler:      handle,
-		NodeMask:     masks.Nodes,
-		WayMask:      masks.Ways,
-		RelationMask: masks.Relations,
+		// Parse will block until it is done or an error occurs.
+		parser.Parse(filter)
 	}
 
-	// Parse will block until it is done or an error occurs.
-	parser.Parse(filter)
+	// build indexes once all elements have been written
+	if err := conn.CreateIndexes(); nil != err {
+		log.Println("failed to create indexes", err)
+		os.Exit(1)
+	}
 
 	return nil
 }
+
+// sqlite3Style - write the features selected by a style file to the
+// tables it defines
+func sqlite3Style(c *cli.Context, p *parser.Parser, source string, path string) {
+
+	// load style
+	style, err := sqlite.NewStyleFromJSON(c.String("style"))
+	if nil != err {
+		log.Println("style error", err)
+		os.Exit(1)
+	}
+
+	// create style tables
+	writer := sqlite.NewStyleWriter(path, style)
+	defer writer.Close()
+
+	// record metadata
+	if err := writer.RecordSource(source); nil != err {
+		log.Println(err)
+		os.Exit(1)
+	}
+
+	// build and write geometries
+	writeGeometries(c, p, writer, style.Features())
+}
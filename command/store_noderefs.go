@@ -6,7 +6,6 @@ import (
 
 	"github.com/urfave/cli"
 	"github.com/missinglink/pbf/handler"
-	"github.com/missinglink/pbf/leveldb"
 	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/parser"
 	"github.com/missinglink/pbf/proxy"
@@ -41,12 +40,15 @@ func StoreNodeRefs(c *cli.Context) error {
 	lib.EnsureDirectoryExists(leveldbPath, "leveldb")
 
 	// open database connection
-	conn := &leveldb.Connection{}
-	conn.Open(leveldbPath)
+	conn := openConnection(c, leveldbPath, true)
 	defer conn.Close()
 
+	// open location store
+	locations := openLocations(c, conn, leveldbPath, true)
+	defer closeLocations(locations, conn)
+
 	// create db writer routine
-	writer := leveldb.NewCoordWriter(conn)
+	writer := newLocationWriter(locations)
 
 	// ensure all node refs are written to disk before starting on the ways
 	dec := parser.GetDecoder()
@@ -0,0 +1,627 @@
+package command
+
+import (
+	"math"
+	"sort"
+
+	geo "github.com/paulmach/go.geo"
+)
+
+// grid cell size in degrees, the same as the merge distance tolerance so
+// that most distance queries only visit the cells around a point
+const streetCellSize = 0.003
+
+// which endpoints of a street a query considers
+const (
+	firstPoints = 1 << iota
+	lastPoints
+	bothPoints = firstPoints | lastPoints
+)
+
+type gridCell [2]int
+
+func cellOf(x, y float64) gridCell {
+	return gridCell{int(math.Floor(x / streetCellSize)), int(math.Floor(y / streetCellSize))}
+}
+
+// positionSet - a set of the positions 0..n-1 backed by a fenwick tree, so
+// that finding the index of a position among the members (and the member
+// at an index) is O(log n)
+type positionSet struct {
+	tree []int
+	has  []bool
+	size int
+	step int // highest power of two <= n
+}
+
+// newPositionSet - constructor, the set is full when all is true
+func newPositionSet(n int, all bool) *positionSet {
+	var s = &positionSet{tree: make([]int, n+1), has: make([]bool, n), step: 1}
+	for s.step*2 <= n {
+		s.step *= 2
+	}
+	if all {
+		for i := 1; i <= n; i++ {
+			s.tree[i] = i & -i
+			s.has[i-1] = true
+		}
+		s.size = n
+	}
+	return s
+}
+
+// Add - add position p
+func (s *positionSet) Add(p int) {
+	if s.has[p] {
+		return
+	}
+	s.has[p] = true
+	s.size++
+	for i := p + 1; i < len(s.tree); i += i & -i {
+		s.tree[i]++
+	}
+}
+
+// Remove - remove position p
+func (s *positionSet) Remove(p int) {
+	if !s.has[p] {
+		return
+	}
+	s.has[p] = false
+	s.size--
+	for i := p + 1; i < len(s.tree); i += i & -i {
+		s.tree[i]--
+	}
+}
+
+// Has - yes/no if p is a member
+func (s *positionSet) Has(p int) bool {
+	return s.has[p]
+}
+
+// Len - the number of members
+func (s *positionSet) Len() int {
+	return s.size
+}
+
+// Rank - the number of members before position p
+func (s *positionSet) Rank(p int) int {
+	var sum int
+	for i := p; i > 0; i -= i & -i {
+		sum += s.tree[i]
+	}
+	return sum
+}
+
+// Select - the position of the kth member, k must be less than Len()
+func (s *positionSet) Select(k int) int {
+	var pos, remaining = 0, k + 1
+	for step := s.step; step > 0; step >>= 1 {
+		if pos+step < len(s.tree) && s.tree[pos+step] < remaining {
+			pos += step
+			remaining -= s.tree[pos]
+		}
+	}
+	return pos
+}
+
+// Next - the first member at or after position p, -1 if there is none
+func (s *positionSet) Next(p int) int {
+	var rank = s.Rank(p)
+	if rank >= s.size {
+		return -1
+	}
+	return s.Select(rank)
+}
+
+// streetIndex - grid over the first and last points and the segments of a
+// group of streets, entries refer to the position of the street in the
+// group and can be deactivated as streets are merged or removed
+type streetIndex struct {
+	streets  []*street
+	active   []bool
+	count    int
+	firsts   map[gridCell][]int
+	lasts    map[gridCell][]int
+	segments map[gridCell][]int // nil unless crossings are indexed
+	min, max gridCell           // extent of the endpoint cells
+}
+
+// newStreetIndex - index all streets, segments are only indexed when
+// crossing streets need to be found
+func newStreetIndex(streets []*street, segments bool) *streetIndex {
+	var x = &streetIndex{
+		streets: streets,
+		active:  make([]bool, len(streets)),
+		count:   len(streets),
+		firsts:  make(map[gridCell][]int),
+		lasts:   make(map[gridCell][]int),
+		min:     gridCell{math.MaxInt32, math.MaxInt32},
+		max:     gridCell{math.MinInt32, math.MinInt32},
+	}
+	if segments {
+		x.segments = make(map[gridCell][]int)
+	}
+
+	for pos, s := range streets {
+		x.active[pos] = true
+		for _, end := range []struct {
+			point *geo.Point
+			cells map[gridCell][]int
+		}{{s.Path.First(), x.firsts}, {s.Path.Last(), x.lasts}} {
+			var cell = cellOf(end.point[0], end.point[1])
+			end.cells[cell] = append(end.cells[cell], pos)
+			x.extend(cell)
+		}
+		if segments {
+			var points = s.Path.PointSet
+			for i := 0; i+1 < len(points); i++ {
+				eachSegmentCell(&points[i], &points[i+1], 0, func(cell gridCell) {
+					var entries = x.segments[cell]
+					if len(entries) == 0 || entries[len(entries)-1] != pos {
+						x.segments[cell] = append(entries, pos)
+					}
+				})
+			}
+		}
+	}
+
+	return x
+}
+
+func (x *streetIndex) extend(cell gridCell) {
+	for axis := 0; axis < 2; axis++ {
+		if cell[axis] < x.min[axis] {
+			x.min[axis] = cell[axis]
+		}
+		if cell[axis] > x.max[axis] {
+			x.max[axis] = cell[axis]
+		}
+	}
+}
+
+// remove - exclude the street at pos from all further queries
+func (x *streetIndex) remove(pos int) {
+	if x.active[pos] {
+		x.active[pos] = false
+		x.count--
+	}
+}
+
+// budget - the number of cells a query may visit before it is cheaper to
+// scan every active street
+func (x *streetIndex) budget() int {
+	return 4*x.count + 16
+}
+
+// nearest - the distance from p to the closest chosen endpoint of the
+// active streets, +Inf when there are none
+func (x *streetIndex) nearest(p *geo.Point, which int) float64 {
+	var best = math.Inf(1)
+	if x.count == 0 {
+		return best
+	}
+
+	var c = cellOf(p[0], p[1])
+	var reach = 0
+	for axis := 0; axis < 2; axis++ {
+		reach = maxInt(reach, maxInt(c[axis]-x.min[axis], x.max[axis]-c[axis]))
+	}
+
+	// search rings of cells outwards, the cells in ring r are at least
+	// (r-1) cells away from p
+	var visited = 0
+	for r := 0; r <= reach; r++ {
+		if best <= float64(r-1)*streetCellSize {
+			break
+		}
+		if visited += maxInt(1, 8*r); visited > x.budget() {
+			return x.scan(p, which)
+		}
+		for dx := -r; dx <= r; dx++ {
+			for dy := -r; dy <= r; dy++ {
+				if dx != -r && dx != r && dy != -r && dy != r {
+					continue
+				}
+				var cell = gridCell{c[0] + dx, c[1] + dy}
+				x.eachEndpoint(cell, which, func(pos int, point *geo.Point) {
+					if d := p.DistanceFrom(point); d < best {
+						best = d
+					}
+				})
+			}
+		}
+	}
+
+	return best
+}
+
+// scan - nearest without the grid
+func (x *streetIndex) scan(p *geo.Point, which int) float64 {
+	var best = math.Inf(1)
+	for pos, s := range x.streets {
+		if !x.active[pos] {
+			continue
+		}
+		if which&firstPoints != 0 {
+			best = math.Min(best, p.DistanceFrom(s.Path.First()))
+		}
+		if which&lastPoints != 0 {
+			best = math.Min(best, p.DistanceFrom(s.Path.Last()))
+		}
+	}
+	return best
+}
+
+// within - call fn for the active streets which may have a chosen endpoint
+// within distance r of p, streets may be reported more than once
+func (x *streetIndex) within(p *geo.Point, r float64, which int, fn func(pos int)) {
+	if x.count == 0 {
+		return
+	}
+
+	// pad by a cell to cover rounding at the edges
+	var lo = cellOf(p[0]-r, p[1]-r)
+	var hi = cellOf(p[0]+r, p[1]+r)
+	var cells = float64(hi[0]-lo[0]+3) * float64(hi[1]-lo[1]+3)
+	if math.IsInf(r, 1) || cells > float64(x.budget()) {
+		for pos := range x.streets {
+			if x.active[pos] {
+				fn(pos)
+			}
+		}
+		return
+	}
+
+	for cx := lo[0] - 1; cx <= hi[0]+1; cx++ {
+		for cy := lo[1] - 1; cy <= hi[1]+1; cy++ {
+			x.eachEndpoint(gridCell{cx, cy}, which, func(pos int, point *geo.Point) { fn(pos) })
+		}
+	}
+}
+
+// crossing - call fn for the active streets which have a segment sharing a
+// cell with one of the segments between points, segments whose bounds
+// don't overlap cannot intersect so this finds every street which
+// may intersect the points
+func (x *streetIndex) crossing(points geo.PointSet, fn func(pos int)) {
+	for i := 0; i+1 < len(points); i++ {
+		eachSegmentCell(&points[i], &points[i+1], 1, func(cell gridCell) {
+			for _, pos := range x.segments[cell] {
+				if x.active[pos] {
+					fn(pos)
+				}
+			}
+		})
+	}
+}
+
+func (x *streetIndex) eachEndpoint(cell gridCell, which int, fn func(pos int, point *geo.Point)) {
+	if which&firstPoints != 0 {
+		for _, pos := range x.firsts[cell] {
+			if x.active[pos] {
+				fn(pos, x.streets[pos].Path.First())
+			}
+		}
+	}
+	if which&lastPoints != 0 {
+		for _, pos := range x.lasts[cell] {
+			if x.active[pos] {
+				fn(pos, x.streets[pos].Path.Last())
+			}
+		}
+	}
+}
+
+// eachSegmentCell - call fn for the cells covered by the bounds of the
+// segment a-b, padded by pad cells
+func eachSegmentCell(a, b *geo.Point, pad int, fn func(cell gridCell)) {
+	var lo = cellOf(math.Min(a[0], b[0]), math.Min(a[1], b[1]))
+	var hi = cellOf(math.Max(a[0], b[0]), math.Max(a[1], b[1]))
+	for cx := lo[0] - pad; cx <= hi[0]+pad; cx++ {
+		for cy := lo[1] - pad; cy <= hi[1]+pad; cy++ {
+			fn(gridCell{cx, cy})
+		}
+	}
+}
+
+// streetMerger - the streets of a group which remain to be merged in to a
+// base street. the merge passes visit the remaining streets in order and
+// restart after every merge, most visits do nothing so the merger tracks
+// which streets a visit can act on: those at the shortest distance from the
+// base, those which may cross it, copies of the base and, when tolerance
+// is set, oneway streets further than their tolerance from the base.
+type streetMerger struct {
+	streets   []*street
+	alive     *positionSet // the remaining streets
+	index     *streetIndex // the remaining streets other than the base
+	watch     *positionSet // streets crossing or sharing a path with the base
+	oneways   *positionSet // remaining oneway streets, nil unless tolerance is set
+	tolerance func(base, other *street) float64
+	crossings bool
+
+	base     *street
+	baseLen  int
+	baseGrid *segmentGrid        // segments of the base, see intersects
+	shared   map[*geo.Path][]int // positions of streets sharing a path
+	dups     []int               // positions of streets sharing the base path
+
+	// distances from the base to the remaining streets
+	shortest, shortestSameDirection float64
+	nearest                         []int // sorted positions at those distances
+}
+
+// newStreetMerger - constructor, crossings enables tracking the streets
+// which cross the base
+func newStreetMerger(streets []*street, crossings bool, tolerance func(base, other *street) float64) *streetMerger {
+	var m = &streetMerger{
+		streets:   streets,
+		alive:     newPositionSet(len(streets), true),
+		index:     newStreetIndex(streets, crossings),
+		watch:     newPositionSet(len(streets), false),
+		tolerance: tolerance,
+		crossings: crossings,
+		shared:    make(map[*geo.Path][]int),
+	}
+	for pos, s := range streets {
+		m.shared[s.Path] = append(m.shared[s.Path], pos)
+	}
+	if nil != tolerance {
+		m.oneways = newPositionSet(len(streets), false)
+		for pos, s := range streets {
+			if s.Oneway == "yes" {
+				m.oneways.Add(pos)
+			}
+		}
+	}
+	return m
+}
+
+// Len - the number of remaining streets
+func (m *streetMerger) Len() int {
+	return m.alive.Len()
+}
+
+// At - the ith remaining street
+func (m *streetMerger) At(i int) *street {
+	return m.streets[m.alive.Select(i)]
+}
+
+// setBase - use the ith remaining street as the base, the base is excluded
+// from distances but remains in the list until it is removed
+func (m *streetMerger) setBase(i int) {
+	var pos = m.alive.Select(i)
+	m.base = m.streets[pos]
+	m.baseLen = m.base.Path.Length()
+	m.baseGrid = nil
+	m.index.remove(pos)
+
+	// copies of the base change as it grows, so they are always visited
+	// rather than indexed
+	m.dups = nil
+	for _, p := range m.shared[m.base.Path] {
+		if p != pos && m.alive.Has(p) {
+			m.dups = append(m.dups, p)
+			m.index.remove(p)
+			m.watch.Add(p)
+		}
+	}
+
+	if m.crossings {
+		m.index.crossing(m.base.Path.PointSet, m.watch.Add)
+	}
+}
+
+// remove - remove the ith remaining street, out of range indices are
+// ignored
+func (m *streetMerger) remove(i int) {
+	if i < 0 || i >= m.Len() {
+		return
+	}
+	var pos = m.alive.Select(i)
+	m.alive.Remove(pos)
+	m.index.remove(pos)
+	m.watch.Remove(pos)
+	if nil != m.oneways {
+		m.oneways.Remove(pos)
+	}
+}
+
+// next - the index of the first remaining street at or after i which a
+// pass may act on, or the last index when there are none
+func (m *streetMerger) next(i int) int {
+	m.update()
+
+	var last = m.Len() - 1
+	if i >= last {
+		return i
+	}
+	var from = m.alive.Select(i)
+	var best = last
+
+	if k := sort.SearchInts(m.nearest, from); k < len(m.nearest) {
+		best = minInt(best, m.alive.Rank(m.nearest[k]))
+	}
+	if p := m.watch.Next(from); p >= 0 {
+		best = minInt(best, m.alive.Rank(p))
+	}
+	if nil != m.oneways {
+		for p := m.oneways.Next(from); p >= 0; p = m.oneways.Next(p + 1) {
+			var index = m.alive.Rank(p)
+			if index >= best {
+				break
+			}
+			if shortestDistanceWhenSameDirection(m.base.Path, m.streets[p].Path) > m.tolerance(m.base, m.streets[p]) {
+				best = index
+				break
+			}
+		}
+	}
+
+	return best
+}
+
+// intersects - yes/no if the path intersects the base
+func (m *streetMerger) intersects(path *geo.Path) bool {
+	if nil == m.baseGrid {
+		m.baseGrid = &segmentGrid{}
+	}
+	m.baseGrid.sync(m.base.Path)
+	return m.baseGrid.intersects(path)
+}
+
+// update - find the streets which became candidates since the last call
+func (m *streetMerger) update() {
+	var path = m.base.Path
+
+	// the base only grows at either end, look for streets crossing the new
+	// segments
+	if m.crossings && path.Length() != m.baseLen {
+		var added = path.Length() - m.baseLen
+		m.index.crossing(path.PointSet[:added+1], m.watch.Add)
+		m.index.crossing(path.PointSet[m.baseLen-1:], m.watch.Add)
+		m.baseLen = path.Length()
+	}
+
+	// distances
+	var first, last = path.First(), path.Last()
+	m.shortest = math.Min(m.index.nearest(first, bothPoints), m.index.nearest(last, bothPoints))
+	m.shortestSameDirection = math.Min(m.index.nearest(last, firstPoints), m.index.nearest(first, lastPoints))
+	for _, p := range m.dups {
+		if m.alive.Has(p) {
+			m.shortest = math.Min(m.shortest, getShortestDistance(path, path))
+			m.shortestSameDirection = math.Min(m.shortestSameDirection, shortestDistanceWhenSameDirection(path, path))
+		}
+	}
+
+	// an empty list has a distance of 0 as in the original implementation
+	if math.IsInf(m.shortest, 1) {
+		m.shortest = 0
+	}
+	if math.IsInf(m.shortestSameDirection, 1) {
+		m.shortestSameDirection = 0
+	}
+
+	// streets at those distances
+	var found = make(map[int]bool)
+	var check = func(pos int) {
+		var other = m.streets[pos].Path
+		var lf, fl = last.DistanceFrom(other.First()), first.DistanceFrom(other.Last())
+		if lf == m.shortest || fl == m.shortest ||
+			last.DistanceFrom(other.Last()) == m.shortest ||
+			first.DistanceFrom(other.First()) == m.shortest ||
+			lf == m.shortestSameDirection || fl == m.shortestSameDirection {
+			found[pos] = true
+		}
+	}
+	m.index.within(first, m.shortest, bothPoints, check)
+	m.index.within(last, m.shortest, bothPoints, check)
+	m.index.within(last, m.shortestSameDirection, firstPoints, check)
+	m.index.within(first, m.shortestSameDirection, lastPoints, check)
+
+	m.nearest = m.nearest[:0]
+	for pos := range found {
+		m.nearest = append(m.nearest, pos)
+	}
+	sort.Ints(m.nearest)
+}
+
+// unionStreets - union the streets which have an endpoint within distance
+// of an endpoint of the other and are linked, returns the function finding
+// the root of the set containing the street at a position
+func unionStreets(streets []*street, distance float64, linked func(a, b *street) bool) func(int) int {
+	var parent = make([]int, len(streets))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	var index = newStreetIndex(streets, false)
+	for i, s := range streets {
+		for _, point := range []*geo.Point{s.Path.First(), s.Path.Last()} {
+			index.within(point, distance, bothPoints, func(j int) {
+				if linked(s, streets[j]) {
+					parent[find(i)] = find(j)
+				}
+			})
+		}
+	}
+	return find
+}
+
+// segmentGrid - the segments of a path by grid cell, kept in sync as the
+// path grows at either end or is reversed so that the base of a merger is
+// only indexed once however many streets are tested against it
+type segmentGrid struct {
+	cells       map[gridCell][]*geo.Line
+	first, last geo.Point
+	length      int
+}
+
+// add - index the segments between points
+func (g *segmentGrid) add(points geo.PointSet) {
+	for i := 0; i+1 < len(points); i++ {
+		var line = geo.NewLine(points[i].Clone(), points[i+1].Clone())
+		eachSegmentCell(line.A(), line.B(), 0, func(cell gridCell) {
+			g.cells[cell] = append(g.cells[cell], line)
+		})
+	}
+}
+
+// sync - index the segments added to path since the last call
+func (g *segmentGrid) sync(path *geo.Path) {
+	var points = path.PointSet
+	if nil != g.cells && g.length > 1 {
+		for p := 0; p+g.length <= len(points); p++ {
+			var a, b = points[p], points[p+g.length-1]
+			if (a.Equals(&g.first) && b.Equals(&g.last)) || (a.Equals(&g.last) && b.Equals(&g.first)) {
+				g.add(points[:p+1])
+				g.add(points[p+g.length-1:])
+				g.first, g.last, g.length = points[0], points[len(points)-1], len(points)
+				return
+			}
+		}
+	}
+
+	// a new path or one which changed other than at its ends
+	g.cells = make(map[gridCell][]*geo.Line)
+	g.add(points)
+	g.first, g.last, g.length = points[0], points[len(points)-1], len(points)
+}
+
+// intersects - yes/no if a segment of path intersects an indexed segment,
+// the same as IntersectsPath
+func (g *segmentGrid) intersects(path *geo.Path) bool {
+	var found bool
+	for i := 0; i+1 < len(path.PointSet) && !found; i++ {
+		var line = geo.NewLine(&path.PointSet[i], &path.PointSet[i+1])
+		eachSegmentCell(line.A(), line.B(), 0, func(cell gridCell) {
+			for _, other := range g.cells[cell] {
+				if !found && line.Intersects(other) {
+					found = true
+				}
+			}
+		})
+	}
+	return found
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1,23 +1,19 @@
 package command
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/missinglink/pbf/parser"
 	"github.com/missinglink/pbf/sqlite"
+	"github.com/missinglink/pbf/streetname"
 
 	"github.com/missinglink/pbf/handler"
 	"github.com/missinglink/pbf/lib"
@@ -25,6 +21,7 @@ import (
 	"github.com/missinglink/pbf/tags"
 
 	geo "github.com/paulmach/go.geo"
+	geojson "github.com/paulmach/go.geojson"
 	"github.com/urfave/cli"
 )
 
@@ -32,56 +29,179 @@ type street struct {
 	Path *geo.Path
 	Name string
 	Oneway string
+	Highway string
 	WayId int
+	Names map[string]string
+	Ways []streetWay
+	wayIDs map[int]bool // the ids of Ways, built by absorb
+}
+
+// streetWay - an osm way merged in to a street
+type streetWay struct {
+	ID      int
+	Name    string // the name before normalizing
+	Names   map[string]string
+	Highway string
+	Oneway  string
+}
+
+// streetJSON - the json output format
+type streetJSON struct {
+	Name      string              `json:"name"`
+	LocalName string              `json:"local_name,omitempty"`
+	Variants  map[string][]string `json:"variants"`
+	Geometry  *geojson.Geometry   `json:"geometry"`
+	Centroid  []float64           `json:"centroid,omitempty"`
+	Distance  float64             `json:"distance,omitempty"`
+	Bounds    []float64           `json:"bounds,omitempty"`
+	Ways      []int               `json:"ways,omitempty"`
+	Names     []string            `json:"names,omitempty"`
+	Highways  []string            `json:"highways,omitempty"`
+	Oneways   []string            `json:"oneways,omitempty"`
 }
 
 type config struct {
 	Format          string
 	Delim           string
 	ExtendedColumns bool
+	Provenance      bool
+	Languages       []string
 }
 
-// A Response struct to map the Entire Response
-type Response struct {
-    Solutions []Solution `json:"solutions"`
+type Vector struct {
+	dX float64
+	dY float64
 }
 
-// A Solution Struct to map every solution to.
-type Solution struct {
-    Score int            `json:"score"`
-    Classifications []Classification `json:"classifications"`
+// absorb - record the ways of other as merged in to s, ways are only
+// recorded once
+func (s *street) absorb(other *street) {
+	if nil == s.wayIDs {
+		s.wayIDs = make(map[int]bool, len(s.Ways)+len(other.Ways))
+		for _, way := range s.Ways {
+			s.wayIDs[way.ID] = true
+		}
+	}
+	for _, way := range other.Ways {
+		if !s.wayIDs[way.ID] {
+			s.wayIDs[way.ID] = true
+			s.Ways = append(s.Ways, way)
+		}
+	}
 }
 
-// A struct to map our Classification which includes it's value
-type Classification struct {
-    Value string `json:"value"`
-		Label string `json:"label"`
+// variants - the distinct values of each name variant of the merged ways
+func (s *street) variants() map[string][]string {
+	var variants = make(map[string][]string)
+	for _, way := range s.Ways {
+		for k, v := range way.Names {
+			var found = false
+			for _, existing := range variants[k] {
+				if existing == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				variants[k] = append(variants[k], v)
+			}
+		}
+	}
+	return variants
 }
 
-type Vector struct {
-	dX float64
-	dY float64
+// localName - the name in the first of the languages used by a merged way,
+// falling back to the original name, or the normalized name when no
+// languages were selected
+func (s *street) localName(langs []string) string {
+	if 0 == len(langs) {
+		return s.Name
+	}
+	var first = make(map[string]string)
+	for k, v := range s.variants() {
+		first[k] = v[0]
+	}
+	if name := tags.Localized(first, langs); "" != name {
+		return name
+	}
+	return s.Name
 }
 
-func (s *street) Print(conf *config) {
+// printJSON - print the street as a line of json
+func (s *street) printJSON(conf *config) {
+	var out = streetJSON{
+		Name:     s.Name,
+		Variants: s.variants(),
+		Geometry: s.Path.ToGeoJSON().Geometry,
+	}
+	if len(conf.Languages) > 0 {
+		out.LocalName = s.localName(conf.Languages)
+	}
+
+	if true == conf.ExtendedColumns {
+		var centroid = s.Path.Interpolate(0.5)
+		var bounds = s.Path.Bound()
+		out.Centroid = []float64{centroid.Lng(), centroid.Lat()}
+		out.Distance = math.Round(s.Path.GeoDistance())
+		out.Bounds = []float64{bounds.Left(), bounds.Bottom(), bounds.Right(), bounds.Top()}
+	}
+
+	if true == conf.Provenance {
+		for _, way := range s.Ways {
+			out.Ways = append(out.Ways, way.ID)
+			out.Names = append(out.Names, way.Name)
+			out.Highways = append(out.Highways, way.Highway)
+			out.Oneways = append(out.Oneways, way.Oneway)
+		}
+	}
 
-	// geojson
-	// feature := s.Path.ToGeoJSON()
-	// for _, way := range s.Ways {
-	// 	for k, v := range way.Tags {
-	// 		feature.SetProperty(k, v)
-	// 	}
-	// 	feature.SetProperty("id", way.ID)
-	// }
-	//
-	// json, _ := feature.MarshalJSON()
-	// fmt.Println(string(json))
+	bytes, err := json.Marshal(out)
+	if nil != err {
+		log.Println("failed to marshal json")
+		os.Exit(1)
+	}
+	fmt.Println(string(bytes))
+}
+
+// provenanceEscaper - escapes the separator of provenance values
+var provenanceEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`)
+
+// joinProvenance - join values with ';', escaping the values
+func joinProvenance(values []string) string {
+	var escaped = make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = provenanceEscaper.Replace(value)
+	}
+	return strings.Join(escaped, ";")
+}
+
+func (s *street) Print(conf *config) {
+	if "json" == conf.Format {
+		s.printJSON(conf)
+		return
+	}
 
 	var cols []string
 
 	switch conf.Format {
 	case "geojson":
-		bytes, err := s.Path.ToGeoJSON().MarshalJSON()
+		feature := s.Path.ToGeoJSON()
+		if true == conf.Provenance {
+			var ids []int
+			var names, highways, oneways []string
+			for _, way := range s.Ways {
+				ids = append(ids, way.ID)
+				names = append(names, way.Name)
+				highways = append(highways, way.Highway)
+				oneways = append(oneways, way.Oneway)
+			}
+			feature.SetProperty("name", s.Name)
+			feature.SetProperty("ways", ids)
+			feature.SetProperty("names", names)
+			feature.SetProperty("highways", highways)
+			feature.SetProperty("oneways", oneways)
+		}
+		bytes, err := feature.MarshalJSON()
 		if nil != err {
 			log.Println("failed to marshal geojson")
 			os.Exit(1)
@@ -113,7 +233,24 @@ func (s *street) Print(conf *config) {
 		cols = append(cols, strconv.FormatFloat(ne.Lat(), 'f', 7, 64))
 	}
 
-	cols = append(cols, s.Name)
+	cols = append(cols, s.localName(conf.Languages))
+
+	// provenance columns, one value per way separated by ';' with any ';'
+	// or '\' in a value escaped by a '\'
+	if true == conf.Provenance && "geojson" != conf.Format {
+		var ids, names, highways, oneways []string
+		for _, way := range s.Ways {
+			ids = append(ids, strconv.Itoa(way.ID))
+			names = append(names, way.Name)
+			highways = append(highways, way.Highway)
+			oneways = append(oneways, way.Oneway)
+		}
+		cols = append(cols, joinProvenance(ids))
+		cols = append(cols, joinProvenance(names))
+		cols = append(cols, joinProvenance(highways))
+		cols = append(cols, joinProvenance(oneways))
+	}
+
 	fmt.Println(strings.Join(cols, conf.Delim))
 }
 
@@ -124,17 +261,47 @@ func StreetMerge(c *cli.Context) error {
 		Format:          "polyline",
 		Delim:           "\x00",
 		ExtendedColumns: c.Bool("extended"),
+		Provenance:      c.Bool("provenance"),
+		Languages:       tags.ParseLanguages(c.String("lang")),
 	}
 	switch strings.ToLower(c.String("format")) {
 	case "geojson":
 		conf.Format = "geojson"
 	case "wkt":
 		conf.Format = "wkt"
+	case "json":
+		conf.Format = "json"
 	}
 	if "" != c.String("delim") {
 		conf.Delim = c.String("delim")
 	}
 
+	// street name normalizer
+	var normalizer streetname.Normalizer
+	switch c.String("normalizer") {
+	case "", "vietnamese":
+		normalizer = streetname.NewVietnamese()
+	case "http":
+		var parser = streetname.NewHTTP(c.String("normalizer-url"), c.Int("normalizer-rate"))
+		defer parser.Close()
+		normalizer = parser
+	default:
+		log.Println("invalid normalizer, expected one of vietnamese/http")
+		os.Exit(1)
+	}
+
+	// merge rules
+	var rules = defaultStreetRules()
+	if "" != c.String("config") {
+		var err error
+		rules, err = newStreetRulesFromJSON(c.String("config"))
+		if nil != err {
+			log.Println("config error", err)
+			os.Exit(1)
+		}
+	}
+	rules.configure(normalizer)
+
 	// open sqlite database connection
 	// note: sqlite is used to store nodes and ways
 	filename := lib.TempFileName("pbf_", ".temp.db")
@@ -146,13 +313,11 @@ func StreetMerge(c *cli.Context) error {
 	// parse
 	parsePBF(c, conn)
 	var streets = generateStreetsFromWays(conn)
-	var joined = joinStreets(streets)
+	var joined = joinStreets(streets, normalizer, rules)
 
 	// print streets
 	for _, street := range joined {
 		// var normName = strings.ToLower(street.Name)
-		// normName = removeAccent(normName)
-		// normName = streetNameParser(normName)
 		// street.Name = normName
 		street.Print(conf)
 	}
@@ -165,46 +330,22 @@ func StreetMerge(c *cli.Context) error {
 
 var debugMode = false
 
-func joinStreets(streets []*street) []*street {
+func joinStreets(streets []*street, normalizer streetname.Normalizer, rules *streetRules) []*street {
 
 	var nameMap = make(map[string][]*street)
 	var ret []*street
 	// var merged = make(map[*street]bool)
 
 	for _, st := range streets {
-		var normName = strings.ToLower(st.Name)
-
-		var normNameHasSign = normName
-
-		// Convert to unasign vietnamese
-		normName = removeAccent(normName)
-
-		// Ignore "kiet", "hem", "cau", "vong xuyen"
-		if strings.HasPrefix(normName, "kiet") ||
-			strings.HasPrefix(normName, "hem") ||
-			strings.HasPrefix(normName, "cau") ||
-			strings.HasPrefix(normName, "vong xuyen") {
-			continue
-		}
-
-		// TODO: Ignore "Ngõ Chu Huy Mân" to fix "Chu Huy Mân" street merge error
-		if (normName == "ngo chu huy man") {
-			continue
-		}
-
-		// Parse the street name
-		// Add string "đường" to street name if it don't start with "đường"
-		if !strings.HasPrefix(normNameHasSign, "đường") {
-			normName = streetNameParser("duong " + normName)
-		} else {
-			normName = streetNameParser(normName)
-		}
 
+		// Normalize the street name, skipping alleys, bridges etc.
+		var normName = rules.name(st.Highway, normalizer.Normalize(st.Name))
 		if normName == "" {
 			continue
 		}
 		// fmt.Println("debug::street::", normName)
 		// log.Println("debug::street::", normName)
+		st.Ways = []streetWay{{ID: st.WayId, Name: st.Name, Names: st.Names, Highway: st.Highway, Oneway: st.Oneway}}
 		st.Name = normName
 
 		if _, ok := nameMap[normName]; !ok {
@@ -214,173 +355,138 @@ func joinStreets(streets []*street) []*street {
 		}
 	}
 
-	// var distanceGroup = 0.01 // roughly 1000 meters
-	var distanceGroup = 0.003 // roughly 300 meters
+	// Project the streets of each name when distances are in metres
+	var projection = newStreetProjection()
+	if rules.project {
+		for _, strs := range nameMap {
+			projection.project(strs, rules.maxGroup(strs))
+		}
+	}
 
-	var groupDistanceNameMap = make(map[string][]*street)
+	// Group the streets in distance not exceed 300 meters
+	var groupDistanceNameMap = groupStreetsByDistance(nameMap, rules)
 
-	// Group the streets in distance not exceed 1 kilometers
-	for _, strs := range nameMap {
-		// Sort streets follow the descendant length
-		strs = sortStreetsDescLength(strs)
+	// Group the street follow same direction together
+	var groupDirectionNameMap = groupStreetsByDirection(groupDistanceNameMap)
 
-		var normName = strings.ToLower(strs[0].Name)
+	// DEBUG
+	var mergedStreetSameDirection = mergeStreetSameDirection(groupDirectionNameMap, false, rules)
 
-		var baseStreet *street = nil
-		var group = 1
+	var mergeLaneSameDirection = mergeLaneSameDirection(mergedStreetSameDirection, rules)
 
-		var streetGroup []*street = nil
+	// Merge one way and two way street together
+	var mergedStreet = mergeStreet(mergeLaneSameDirection, false, rules)
 
-		for i := 0; i < len(strs); i++ {
-			if (i == 0) {
-				baseStreet = strs[0]
-				normName = strings.ToLower(baseStreet.Name)
-				streetGroup = []*street{baseStreet}
-
-				// Create a new group street
-				normName += "__" + strconv.Itoa(group)
-				group++
-				groupDistanceNameMap[normName] = []*street{baseStreet}
-				continue
-			}
+	// output lines in consistent order
+	keys := make([]string, len(mergedStreet))
+	for k := range mergedStreet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-			var currentStreet = strs[i]
+	for _, k := range keys {
+		var strs = mergedStreet[k]
 
-			// Check if distance from the street with street group < range then add street to group
-			if (shortestDistanceToOtherStreets(currentStreet, streetGroup) < distanceGroup) {
-				streetGroup = append(streetGroup, currentStreet)
-				groupDistanceNameMap[normName] = append(groupDistanceNameMap[normName], currentStreet)
-				strs = removeStreet(strs, i)
-				// i--
-				i = 0
-				continue
-			}
+		if (strs == nil) {
+			continue
+		}
 
-			// When to final element then remove first element from array, and loop array again
-			if (i == (len(strs) - 1)) {
-				strs = removeStreet(strs, 0)
-				i = -1
-			}
+		for _, str := range strs {
+			ret = append(ret, str)
 		}
 	}
 
-	var groupDirectionNameMap = make(map[string][]*street)
+	projection.restore(ret)
 
-	// Group the street follow same direction together
-	for groupStreetName, strs := range groupDistanceNameMap {
+	return ret
+}
+
+// groupStreetsByDistance - split the streets sharing a name in to groups of
+// streets connected by endpoints closer than the group distance, the groups
+// are numbered in order of their longest street
+func groupStreetsByDistance(nameMap map[string][]*street, rules *streetRules) map[string][]*street {
+	var groupDistanceNameMap = make(map[string][]*street)
+
+	for _, strs := range nameMap {
 		// Sort streets follow the descendant length
 		strs = sortStreetsDescLength(strs)
 
-		var normName = groupStreetName
-
-		var baseStreet *street = nil
-		var baseVector *Vector = nil
-		var group = 0
+		// union the streets with endpoints in range of each other
+		var find = unionStreets(strs, rules.maxGroup(strs), func(a, b *street) bool {
+			return getShortestDistance(a.Path, b.Path) < rules.between(a, b).Group
+		})
 
-		for i := 0; i < len(strs); i++ {
-			if (i == 0) {
-				baseStreet = strs[0]
-				baseVector = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
+		// Create a new group street for each longest street
+		var groups = make(map[int]string)
+		var group = 1
+		for i, currentStreet := range strs {
+			var root = find(i)
+			if normName, ok := groups[root]; ok {
+				groupDistanceNameMap[normName] = append(groupDistanceNameMap[normName], currentStreet)
+				continue
 			}
 
-			// Only group streets when street is oneway
-			if (strs[i].Oneway == "yes") {
-				if debugMode {
-					debugStreets(baseStreet, strs[i], normName, strs)
-				}
+			var normName = strings.ToLower(currentStreet.Name) + "__" + strconv.Itoa(group)
+			group++
+			groups[root] = normName
+			groupDistanceNameMap[normName] = []*street{currentStreet}
+		}
+	}
 
-				if (len(strs) <= 1) {
-					// Create a new group street
-					normName = groupStreetName + "--" + strconv.Itoa(group)
-					group++
+	return groupDistanceNameMap
+}
 
-					if _, ok := groupDirectionNameMap[normName]; !ok {
-						groupDirectionNameMap[normName] = []*street{baseStreet}
-					} else {
-						groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], baseStreet)
-					}
-					continue
-				}
+// groupStreetsByDirection - two way streets keep the name of their distance
+// group, oneway streets are split in to groups going in the same direction
+// as the longest street of the group
+func groupStreetsByDirection(groupDistanceNameMap map[string][]*street) map[string][]*street {
+	var groupDirectionNameMap = make(map[string][]*street)
 
-				if (i == 0) {
-					// Create a new group street
-					normName = groupStreetName + "--" + strconv.Itoa(group)
-					group++
+	for groupStreetName, strs := range groupDistanceNameMap {
+		// Sort streets follow the descendant length
+		strs = sortStreetsDescLength(strs)
 
-					if _, ok := groupDirectionNameMap[normName]; !ok {
-						groupDirectionNameMap[normName] = []*street{baseStreet}
-					} else {
-						groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], baseStreet)
-					}
-					continue
-				}
+		// Only group streets when street is oneway
+		var oneways []*street
+		for _, currentStreet := range strs {
+			if currentStreet.Oneway == "yes" {
+				oneways = append(oneways, currentStreet)
+				continue
+			}
+			groupDirectionNameMap[groupStreetName] = append(groupDirectionNameMap[groupStreetName], currentStreet)
+		}
 
-				var currentStreet = strs[i]
+		for group := 0; len(oneways) > 0; group++ {
+			var baseStreet = oneways[0]
+			var baseVector = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
 
-				var isTwoPathsSameDirection = isTwoPathsSameDirection(baseVector, createPathVector(currentStreet.Path.First(), currentStreet.Path.Last()))
+			// Create a new group street
+			var normName = groupStreetName + "--" + strconv.Itoa(group)
+			groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], baseStreet)
 
-				// Check distance between 2 street to divide group
-				if (isTwoPathsSameDirection) {
+			var remaining []*street
+			for _, currentStreet := range oneways[1:] {
+				if isTwoPathsSameDirection(baseVector, createPathVector(currentStreet.Path.First(), currentStreet.Path.Last())) {
 					groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], currentStreet)
-					strs = removeStreet(strs, i)
-					i--
-				}
-
-				// When reach to the last item of list street, then remove the first item and loop again the list street
-				if (i == (len(strs) - 1)) {
-					strs = removeStreet(strs, 0)
-					i = -1
-				}
-
-			} else {
-
-				if _, ok := groupDirectionNameMap[groupStreetName]; !ok {
-					groupDirectionNameMap[groupStreetName] = []*street{strs[i]}
-
-				} else {
-					groupDirectionNameMap[groupStreetName] = append(groupDirectionNameMap[groupStreetName], strs[i])
-
-				}
-				strs = removeStreet(strs, i)
-				i--
-
-				// When reach to the last item of list street, then remove the first item and loop again the list street
-				if (i == (len(strs) - 1)) {
-					strs = removeStreet(strs, 0)
-					i = -1
+					continue
 				}
+				remaining = append(remaining, currentStreet)
 			}
+			oneways = remaining
 		}
 	}
 
-	// DEBUG
-	var mergedStreetSameDirection = mergeStreetSameDirection(groupDirectionNameMap, false)
-
-	var mergeLaneSameDirection = mergeLaneSameDirection(mergedStreetSameDirection)
-
-	// Merge one way and two way street together
-	var mergedStreet = mergeStreet(mergeLaneSameDirection, false)
-
-	// output lines in consistent order
-	keys := make([]string, len(mergedStreet))
-	for k := range mergedStreet {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		var strs = mergedStreet[k]
-
-		if (strs == nil) {
-			continue
-		}
+	return groupDirectionNameMap
+}
 
-		for _, str := range strs {
-			ret = append(ret, str)
-		}
+// sortedStreetNames - the keys of nameMap in order
+func sortedStreetNames(nameMap map[string][]*street) []string {
+	var names = make([]string, 0, len(nameMap))
+	for name := range nameMap {
+		names = append(names, name)
 	}
-
-	return ret
+	sort.Strings(names)
+	return names
 }
 
 func loadStreetsFromDatabase(conn *sqlite.Connection, callback func(*sql.Rows)) {
@@ -407,7 +513,20 @@ func loadStreetsFromDatabase(conn *sqlite.Connection, callback func(*sql.Rows))
 			WHERE ref = ways.id
 			AND key = 'oneway'
 			LIMIT 1
-		) AS oneway
+		) AS oneway,
+		(
+			SELECT value
+			FROM way_tags
+			WHERE ref = ways.id
+			AND key = 'highway'
+			LIMIT 1
+		) AS highway,
+		(
+			SELECT GROUP_CONCAT(( key || char(31) || value ), char(30))
+			FROM way_tags
+			WHERE ref = ways.id
+			AND ( key GLOB 'name:*' OR key IN ( 'alt_name', 'old_name', 'official_name', 'short_name' ) )
+		) AS names
 	FROM ways
 	ORDER BY ways.id ASC;
 	`)
@@ -431,10 +550,10 @@ func generateStreetsFromWays(conn *sqlite.Connection) []*street {
 
 		var wayid int
 		var nodeids, name string
-		var maybeNodeIds, maybeOneway sql.NullString
+		var maybeNodeIds, maybeOneway, maybeHighway, maybeNames sql.NullString
 		var oneway = ""
 
-		err := rows.Scan(&wayid, &maybeNodeIds, &name, &maybeOneway)
+		err := rows.Scan(&wayid, &maybeNodeIds, &name, &maybeOneway, &maybeHighway, &maybeNames)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -479,7 +598,17 @@ func generateStreetsFromWays(conn *sqlite.Connection) []*street {
 			path.InsertAt(i, geo.NewPoint(lon, lat))
 		}
 
-		streets = append(streets, &street{Name: name, Path: path, Oneway: oneway, WayId: wayid})
+		// name variants, separated by record and unit separators
+		var names = map[string]string{"name": name}
+		if maybeNames.Valid {
+			for _, pair := range strings.Split(maybeNames.String, "\x1e") {
+				if kv := strings.SplitN(pair, "\x1f", 2); len(kv) == 2 {
+					names[kv[0]] = kv[1]
+				}
+			}
+		}
+
+		streets = append(streets, &street{Name: name, Path: path, Oneway: oneway, Highway: maybeHighway.String, Names: names, WayId: wayid})
 	})
 
 	return streets
@@ -523,330 +652,6 @@ func parsePBF(c *cli.Context, conn *sqlite.Connection) {
 	parser.Parse(filterNodes)
 }
 
-// Mang cac ky tu goc co dau
-var SOURCE_CHARACTERS, LL_LENGTH = stringToRune(`ÀÁÂÃÈÉÊÌÍÒÓÔÕÙÚÝàáâãèéêìíòóôõùúýĂăĐđĨĩŨũƠơƯưẠạẢảẤấẦầẨẩẪẫẬậẮắẰằẲẳẴẵẶặẸẹẺẻẼẽẾếỀềỂểỄễỆệỈỉỊịỌọỎỏỐốỒồỔổỖỗỘộỚớỜờỞởỠỡỢợỤụỦủỨứỪừỬửỮữỰựỹỳỷỵỸỲỶỴ`)
-
-// Mang cac ky tu thay the khong dau
-var DESTINATION_CHARACTERS, _ = stringToRune(`AAAAEEEIIOOOOUUYaaaaeeeiioooouuyAaDdIiUuOoUuAaAaAaAaAaAaAaAaAaAaAaAaEeEeEeEeEeEeEeEeIiIiOoOoOoOoOoOoOoOoOoOoOoOoUuUuUuUuUuUuUuyyyyyyyy`)
-
-func stringToRune(s string) ([]string, int) {
-
-	ll := utf8.RuneCountInString(s)
-
-	var texts = make([]string, ll+1)
-
-	var index = 0
-
-	for _, runeValue := range s {
-
-		texts[index] = string(runeValue)
-
-		index++
-
-	}
-
-	return texts, ll
-
-}
-
-func binarySearch(sortedArray []string, key string, low int, high int) int {
-
-	var middle int = (low + high) / 2
-
-	if high < low {
-		return -1
-	}
-
-	if key == sortedArray[middle] {
-
-		return middle
-
-	} else if key < sortedArray[middle] {
-
-		return binarySearch(sortedArray, key, low, middle-1)
-
-	} else {
-
-		return binarySearch(sortedArray, key, middle+1, high)
-
-	}
-}
-
-/** * Bo dau 1 ky tu * * @param ch * @return */
-func removeAccentChar(ch string) string {
-	var index int = binarySearch(SOURCE_CHARACTERS, ch, 0, LL_LENGTH)
-
-	if index >= 0 {
-		ch = DESTINATION_CHARACTERS[index]
-	}
-
-	return ch
-}
-
-/** * Bo dau 1 chuoi * * @param s * @return */
-func removeAccent(s string) string {
-	var buffer bytes.Buffer
-
-	// if (s == "quốc lộ 1a") {
-	// 	fmt.Println("debug")
-	// }
-
-	// apiKey := "1a37dc708fbdeffe2001397e5b7052a3"
-	// // Call API to replace specific characters to normal characters
-
-	// text := strings.ReplaceAll(s, " ", "%20")
-	// url := "https://api-private.map4d.vn/app/geojson/replace?str=" + text + "&Key=" + apiKey
-	// //	https://api-private.map4d.vn/app/geojson/replace?str=Hoa%CC%80ng%20Qu%C3%B4%CC%81c%20Vi%C3%AA%CC%A3t&Key=1a37dc708fbdeffe2001397e5b7052a3
-	// log.Println("replace url::", url)
-
-	// req, _ := http.NewRequest("GET", url, nil)
-	// q := req.URL.Query()
-	// q.Add("str", text)
-	// q.Add("Key", apiKey)
-	// req.URL.RawQuery = q.Encode()
-	// response, _ := client.Do(req)
-
-	// response, err := http.Get(url)
-
-	// if err != nil {
-	// 	fmt.Print(err.Error())
-	// 	os.Exit(1)
-	// }
-
-	// responseData, err := ioutil.ReadAll(response.Body)
-
-	// var responseObject {t: string}
-	// json.Unmarshal(responseData, &responseObject)
-
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-
-	// fmt.Println("responseData::", response)
-
-	// fmt.Println("responseData::", responseData)
-
-
-	// TODO: Must use replace because not convert this characters
-	if (strings.Contains(s, "ỷ")) {
-		s = strings.ReplaceAll(s, "ỷ", "y")
-	}
-	if (strings.Contains(s, "ỳ")) {
-		s = strings.ReplaceAll(s, "ỳ", "y")
-	}
-	if (strings.Contains(s, "ỵ")) {
-		s = strings.ReplaceAll(s, "ỵ", "y")
-	}
-	if (strings.Contains(s, "ỳ")) {
-		s = strings.ReplaceAll(s, "ỳ", "y")
-	}
-	if (strings.Contains(s, "ý")) {
-		s = strings.ReplaceAll(s, "ý", "y")
-	}
-	if (strings.Contains(s, "ố")) {
-		s = strings.ReplaceAll(s, "ố", "o")
-	}
-	if (strings.Contains(s, "ộ")) {
-		s = strings.ReplaceAll(s, "ộ", "o")
-	}
-	if (strings.Contains(s, "ợ")) {
-		s = strings.ReplaceAll(s, "ợ", "o")
-	}
-	if (strings.Contains(s, "ồ")) {
-		s = strings.ReplaceAll(s, "ồ", "o")
-	}
-	if (strings.Contains(s, "ờ")) {
-		s = strings.ReplaceAll(s, "ờ", "o")
-	}
-	if (strings.Contains(s, "ỗ")) {
-		s = strings.ReplaceAll(s, "ỗ", "o")
-	}
-	if (strings.Contains(s, "ở")) {
-		s = strings.ReplaceAll(s, "ở", "o")
-	}
-	if (strings.Contains(s, "ó")) {
-		s = strings.ReplaceAll(s, "ó", "o")
-	}
-	if (strings.Contains(s, "ỏ")) {
-		s = strings.ReplaceAll(s, "ỏ", "o")
-	}
-	if (strings.Contains(s, "õ")) {
-		s = strings.ReplaceAll(s, "õ", "o")
-	}
-	if (strings.Contains(s, "ò")) {
-		s = strings.ReplaceAll(s, "ò", "o")
-	}
-	if (strings.Contains(s, "ò")) {
-		s = strings.ReplaceAll(s, "ò", "o")
-	}
-	if (strings.Contains(s, "ọ")) {
-		s = strings.ReplaceAll(s, "ọ", "o")
-	}
-	if (strings.Contains(s, "ð")) {
-		s = strings.ReplaceAll(s, "ð", "d")
-	}
-	if (strings.Contains(s, "ằ")) {
-		s = strings.ReplaceAll(s, "ằ", "a")
-	}
-	if (strings.Contains(s, "ầ")) {
-		s = strings.ReplaceAll(s, "ầ", "a")
-	}
-	if (strings.Contains(s, "ậ")) {
-		s = strings.ReplaceAll(s, "ậ", "a")
-	}
-	if (strings.Contains(s, "ẩ")) {
-		s = strings.ReplaceAll(s, "ẩ", "a")
-	}
-	if (strings.Contains(s, "ấ")) {
-		s = strings.ReplaceAll(s, "ấ", "a")
-	}
-	if (strings.Contains(s, "ã")) {
-		s = strings.ReplaceAll(s, "ã", "a")
-	}
-	if (strings.Contains(s, "ã")) {
-		s = strings.ReplaceAll(s, "ã", "a")
-	}
-	if (strings.Contains(s, "à")) {
-		s = strings.ReplaceAll(s, "à", "a")
-	}
-	if (strings.Contains(s, "ạ")) {
-		s = strings.ReplaceAll(s, "ạ", "a")
-	}
-	if (strings.Contains(s, "á")) {
-		s = strings.ReplaceAll(s, "á", "a")
-	}
-	if (strings.Contains(s, "ả")) {
-		s = strings.ReplaceAll(s, "ả", "a")
-	}
-	if (strings.Contains(s, "ễ")) {
-		s = strings.ReplaceAll(s, "ễ", "e")
-	}
-	if (strings.Contains(s, "ệ")) {
-		s = strings.ReplaceAll(s, "ệ", "e")
-	}
-	if (strings.Contains(s, "ề")) {
-		s = strings.ReplaceAll(s, "ề", "e")
-	}
-	if (strings.Contains(s, "ế")) {
-		s = strings.ReplaceAll(s, "ế", "e")
-	}
-	if (strings.Contains(s, "ẹ")) {
-		s = strings.ReplaceAll(s, "ẹ", "e")
-	}
-	if (strings.Contains(s, "è")) {
-		s = strings.ReplaceAll(s, "è", "e")
-	}
-	if (strings.Contains(s, "é")) {
-		s = strings.ReplaceAll(s, "é", "e")
-	}
-	if (strings.Contains(s, "ẽ")) {
-		s = strings.ReplaceAll(s, "ẽ", "e")
-	}
-	if (strings.Contains(s, "ẻ")) {
-		s = strings.ReplaceAll(s, "ẻ", "e")
-	}
-	if (strings.Contains(s, "ì")) {
-		s = strings.ReplaceAll(s, "ì", "i")
-	}
-	if (strings.Contains(s, "ị")) {
-		s = strings.ReplaceAll(s, "ị", "i")
-	}
-	if (strings.Contains(s, "į")) {
-		s = strings.ReplaceAll(s, "į", "i")
-	}
-	if (strings.Contains(s, "í")) {
-		s = strings.ReplaceAll(s, "í", "i")
-	}
-	if (strings.Contains(s, "ĩ")) {
-		s = strings.ReplaceAll(s, "ĩ", "i")
-	}
-	if (strings.Contains(s, "ỉ")) {
-		s = strings.ReplaceAll(s, "ỉ", "i")
-	}
-	if (strings.Contains(s, "ữ")) {
-		s = strings.ReplaceAll(s, "ữ", "u")
-	}
-	if (strings.Contains(s, "ứ")) {
-		s = strings.ReplaceAll(s, "ứ", "u")
-	}
-	if (strings.Contains(s, "ự")) {
-		s = strings.ReplaceAll(s, "ự", "u")
-	}
-	if (strings.Contains(s, "ũ")) {
-		s = strings.ReplaceAll(s, "ũ", "u")
-	}
-	if (strings.Contains(s, "ù")) {
-		s = strings.ReplaceAll(s, "ù", "u")
-	}
-	if (strings.Contains(s, "ủ")) {
-		s = strings.ReplaceAll(s, "ủ", "u")
-	}
-	if (strings.Contains(s, "ụ")) {
-		s = strings.ReplaceAll(s, "ụ", "u")
-	}
-	if (strings.Contains(s, "ú")) {
-		s = strings.ReplaceAll(s, "ú", "u")
-	}
-
-	for _, runeValue := range s {
-		buffer.WriteString(removeAccentChar(string(runeValue)))
-	}
-
-	// Debug
-	// fmt.Println("s::", s)
-	// fmt.Println("buffer::", buffer.String())
-
-	return buffer.String()
-}
-
-func streetNameParser(searchText string) string {
-	var url string = ""
-	// if (!strings.Contains(searchText, "duong")) {
-	// 	url = "http://parser.map4d.vn/parser/parse?text=duong " + searchText
-	// } else {
-	// 	url = "http://parser.map4d.vn/parser/parse?text=" + searchText
-	// }
-	url = "http://parser.map4d.vn/parser/parse?text=" + searchText
-	url = strings.ReplaceAll(url, " ", "%20")
-
-	// TODO: Fix for street name same "Ten duong 1 (Ten duong 2)"
-	removeString := regexp.MustCompile(`\(|\)|'|\*`)
-	url = removeString.ReplaceAllString(url, "%20")
-
-	// Debug
-	// log.Println("url::", url)
-
-	response, err := http.Get(url)
-
-	if err != nil {
-		fmt.Print(err.Error())
-		os.Exit(1)
-	}
-
-	responseData, err := ioutil.ReadAll(response.Body)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	var responseObject Response
-	json.Unmarshal(responseData, &responseObject)
-	var streetName = ""
-	if len(responseObject.Solutions) > 0 && len(responseObject.Solutions[0].Classifications) > 0 {
-		var classifications = responseObject.Solutions[0].Classifications
-		for _, classification := range classifications {
-			if classification.Label == "housenumber" {
-				return ""
-			} else if classification.Label == "street" {
-				streetName = classification.Value
-			}
-		}
-		return streetName
-	}
-
-	return streetName
-}
-
 func getShortestDistance(Path1, Path2 *geo.Path) float64 {
 	var shortestDistance = Path1.First().DistanceFrom(Path2.First())
 
@@ -875,37 +680,6 @@ func shortestDistanceWhenSameDirection(Path1, Path2 *geo.Path) float64 {
 	return shortestDistance
 }
 
-func shortestDistanceToOtherSameDirectionStreets(current *street, streets []*street) float64 {
-	if (len(streets) < 1) {
-		return 0.0
-	}
-
-	shortestDistance := shortestDistanceWhenSameDirection(current.Path, streets[0].Path)
-	for i := 1; i < len(streets); i++ {
-		distance := shortestDistanceWhenSameDirection(current.Path, streets[i].Path)
-		if (shortestDistance > distance) {
-			shortestDistance = distance
-		}
-	}
-
-	return shortestDistance
-}
-
-func shortestDistanceToOtherStreets(current *street, streets []*street) float64 {
-	if (len(streets) < 1) {
-		return 0.0
-	}
-
-	shortestDistance := getShortestDistance(current.Path, streets[0].Path)
-	for i := 1; i < len(streets); i++ {
-		distance := getShortestDistance(current.Path, streets[i].Path)
-		if (shortestDistance > distance) {
-			shortestDistance = distance
-		}
-	}
-	return shortestDistance
-}
-
 func isIntersection(path1, path2 *geo.Path) bool {
 	var firstLine = geo.NewLine(path1.First(), path1.Last())
 	var secondLine = geo.NewLine(path2.First(), path2.Last())
@@ -1021,26 +795,21 @@ func getLongestStreetIndex(streets []*street) int {
 	return index
 }
 
+// sortStreetsDescLength - order streets longest first, streets of the same
+// length keep their order
 func sortStreetsDescLength(streets []*street) []*street {
-	var sortedStreets = streets
-
-	// for i := 0; i < len(sortedStreets); i++ {
-	// 	fmt.Println("before::length", sortedStreets[i].Path.Distance())
-	// }
-
-	for i := 0; i < len(sortedStreets) - 1; i++ {
-		for j := i; j < len(sortedStreets); j++ {
-			if (sortedStreets[j].Path.Distance() > sortedStreets[i].Path.Distance()) {
-				temp := sortedStreets[i]
-				sortedStreets[i] = sortedStreets[j]
-				sortedStreets[j] = temp
-			}
-		}
+	var lengths = make([]float64, len(streets))
+	var order = make([]int, len(streets))
+	for i, street := range streets {
+		lengths[i] = street.Path.Distance()
+		order[i] = i
 	}
+	sort.SliceStable(order, func(a, b int) bool { return lengths[order[a]] > lengths[order[b]] })
 
-	// for i := 0; i < len(sortedStreets); i++ {
-	// 	fmt.Println("after::length", sortedStreets[i].Path.Distance())
-	// }
+	var sortedStreets = make([]*street, len(streets))
+	for i, j := range order {
+		sortedStreets[i] = streets[j]
+	}
 
 	return sortedStreets
 }
@@ -1058,37 +827,26 @@ func RemoveIndex(s []string, index int) []string {
 	return append(s[:index], s[index+1:]...)
 }
 
-func removeStreet(s []*street, index int) []*street {
-	var streets []*street
-	for i:= 0; i < len(s); i++ {
-		if (index == i) {
-			continue
-		}
-		streets = append(streets, s[i])
-	}
-	return streets
-}
-
 func removeRoundabout(streets []*street) []*street {
-	for i:= 0; i < len(streets); i++ {
-		first := streets[i].Path.PointSet.First()
-		last := streets[i].Path.PointSet.Last()
+	var remaining []*street
+	for _, street := range streets {
+		first := street.Path.PointSet.First()
+		last := street.Path.PointSet.Last()
 		if first.DistanceFrom(last) == 0 {
-			streets = removeStreet(streets, i)
-			i--
+			continue
 		}
+		remaining = append(remaining, street)
 	}
-	return streets
+	return remaining
 }
 
-func debugStreets(baseStreet *street, currentStreet *street, normName string, streets []*street) {
+// debugStreets - log a merge decision, the distances are those from the
+// base to the other remaining streets
+func debugStreets(baseStreet *street, currentStreet *street, normName string, shortestDistanceToOtherStreets, shortestDistanceToOtherSameDirectionStreets float64) {
 	var vector1 = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
 	var vector2 = createPathVector(currentStreet.Path.First(), currentStreet.Path.Last())
 	var isTwoPathsSameDirection = isTwoPathsSameDirection(vector1, vector2)
 
-	var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(baseStreet, streets)
-	var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(baseStreet, streets)
-
 	if (normName == "dang tu kinh" || normName == "dang tu kinh__1" ||
 			normName == "dang tu kinh__1--0" || normName == "dang tu kinh__1--1") {
 		fmt.Println("--Start debug log--")
@@ -1112,7 +870,7 @@ func debugStreets(baseStreet *street, currentStreet *street, normName string, st
 	}
 }
 
-func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool) map[string][]*street {
+func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool, rules *streetRules) map[string][]*street {
 	var reversePath = func(path *geo.Path) {
 		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
 			opp := path.PointSet.Length() - 1 - i
@@ -1122,39 +880,48 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 
 	var mergedStreetMap = make(map[string][]*street)
 
-	// points do not have to be exact matches
-	// var distanceTolerance = 0.01 // 1000 meters
-	var distanceTolerance = 0.003 // 300 meters
-	var distanceRange = 0.00065 // 65 meters, distance range to merge 2 paths which intersect together
 	var merged = make(map[*street]bool)
 
-	for strName, strs := range nameMap {
+	// merge groups in a consistent order
+	for _, strName := range sortedStreetNames(nameMap) {
+		var strs = nameMap[strName]
+
 		// Sort streets follow the descendant length
 		strs = sortStreetsDescLength(strs)
 
 		var normName = strName
 
 		var str1 *street = nil
+		var m = newStreetMerger(strs, false, nil)
 
-		for i := 0; i < len(strs); i++ {
+		for i := 0; i < m.Len(); i++ {
 
-			if (len(strs) == 1) {
+			if (m.Len() == 1) {
 				if _, ok := mergedStreetMap[normName]; !ok {
-					mergedStreetMap[normName] = []*street{strs[0]}
+					mergedStreetMap[normName] = []*street{m.At(0)}
 				} else {
-					mergedStreetMap[normName] = append(mergedStreetMap[normName], strs[0])
+					mergedStreetMap[normName] = append(mergedStreetMap[normName], m.At(0))
 				}
 				continue
 			}
 
 			if (i == 0) {
-				str1 = strs[0]
+				m.setBase(0)
+				str1 = m.base
 				continue
 			}
 
-			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(str1, removeStreet(strs, 0))
-			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(str1, removeStreet(strs, 0))
-			var str2 = strs[i]
+			// Skip the streets which can't be merged in this loop
+			i = m.next(i)
+
+			var shortestDistanceToOtherStreets = m.shortest
+			var shortestDistanceToOtherSameDirectionStreets = m.shortestSameDirection
+			var str2 = m.At(i)
+
+			// points do not have to be exact matches
+			var distances = rules.between(str1, str2)
+			var distanceTolerance = distances.Tolerance
+			var distanceRange = distances.CrossingWays // distance range to merge 2 paths which intersect together
 
 			var vector1 = createPathVector(str1.Path.First(), str1.Path.Last())
 			var vector2 = createPathVector(str2.Path.First(), str2.Path.Last())
@@ -1163,15 +930,15 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 			if (str2.Oneway == "yes") {
 
 				if debugMode {
-					debugStreets(str1, str2, normName, strs)
+					debugStreets(str1, str2, normName, m.shortest, m.shortestSameDirection)
 				}
 
 				shortestDistanceWhenSameDirection := shortestDistanceWhenSameDirection(str1.Path, str2.Path)
 
 				// Not merge streets same direction, intersect together but distance greater than distance range
-				if (str1.Path.IntersectsPath(str2.Path) && shortestDistanceWhenSameDirection > distanceRange) {
-					if (i == (len(strs) - 1)) {
-						strs = removeStreet(strs, 0)
+				if (shortestDistanceWhenSameDirection > distanceRange && m.intersects(str2.Path)) {
+					if (i == (m.Len() - 1)) {
+						m.remove(0)
 						i = -1
 
 						normName = strName
@@ -1198,8 +965,9 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 
 					merged[str2] = true
 					i = 0
@@ -1220,8 +988,9 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 
 					// flip str1 points back
 					reversePath(str1.Path)
@@ -1233,7 +1002,7 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 
 					// If distance not shortest
 					if debugMode {
-						debugStreets(str1, str2, normName, strs)
+						debugStreets(str1, str2, normName, m.shortest, m.shortestSameDirection)
 					}
 
 					if ((str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherSameDirectionStreets ||
@@ -1244,7 +1013,7 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						} else {
 							mergedStreetMap[normName] = append(mergedStreetMap[normName], str2)
 						}
-						strs = removeStreet(strs, i)
+						m.remove(i)
 						i--
 					} else if shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
 						// TODO
@@ -1259,15 +1028,15 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 			} else {
 				// If two way
 				if debugMode {
-					debugStreets(str1, str2, normName, strs)
+					debugStreets(str1, str2, normName, m.shortest, m.shortestSameDirection)
 				}
 
 				shortestDistance := getShortestDistance(str1.Path, str2.Path)
 
 				// Not merge streets same direction, intersect together but distance greater than distance range
-				if (str1.Path.IntersectsPath(str2.Path) && shortestDistance > distanceRange) {
-					if (i == (len(strs) - 1)) {
-						strs = removeStreet(strs, 0)
+				if (shortestDistance > distanceRange && m.intersects(str2.Path)) {
+					if (i == (m.Len() - 1)) {
+						m.remove(0)
 						i = -1
 
 						normName = strName
@@ -1293,8 +1062,9 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					merged[str2] = true
 					i = 0
 				} else if str1.Path.First().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets &&
@@ -1313,12 +1083,13 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
 					// flip str1 points back
 					reversePath(str1.Path)
 					reversePath(str2.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					merged[str2] = true
 					i = 0
 				} else if str1.Path.Last().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets &&
@@ -1336,11 +1107,12 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
 					// flip str2 points back
 					reversePath(str2.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					merged[str2] = true
 					i = 0
 				} else if str1.Path.First().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets &&
@@ -1358,18 +1130,19 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 						}
 						str1.Path.Push(&point)
 					}
+					str1.absorb(str2)
 
 					// flip str1 points back
 					reversePath(str1.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					merged[str2] = true
 					i = 0
 				} else {
 
 					// If two way and distance not shortest
 					if debugMode {
-						debugStreets(str1, str2, normName, strs)
+						debugStreets(str1, str2, normName, m.shortest, m.shortestSameDirection)
 					}
 
 					if (str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets ||
@@ -1382,7 +1155,7 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 							mergedStreetMap[normName] = append(mergedStreetMap[normName], str2)
 						}
 
-						strs = removeStreet(strs, i)
+						m.remove(i)
 						i--
 					} else if shortestDistanceToOtherStreets < distanceTolerance {
 						// TODO
@@ -1395,8 +1168,8 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 			}
 
 			// When reach to the last item of list street, then remove the first item and loop again the list street
-			if (i == (len(strs) - 1) || len(strs) < 1) {
-				strs = removeStreet(strs, 0)
+			if (i == (m.Len() - 1) || m.Len() < 1) {
+				m.remove(0)
 				i = -1
 				normName = strName
 
@@ -1414,7 +1187,7 @@ func mergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool
 
 
 
-func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
+func mergeLaneSameDirection(nameMap map[string][]*street, rules *streetRules) map[string][]*street {
 	var reversePath = func(path *geo.Path) {
 		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
 			opp := path.PointSet.Length() - 1 - i
@@ -1424,13 +1197,10 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 
 	var mergedStreetMap = make(map[string][]*street)
 
-	// points do not have to be exact matches
-	// var distanceTolerance = 0.01 // 1000 meters
-	// var distanceTolerance = 0.0075 // 750 meters
-	var distanceTolerance = 0.003 // 300 meters
-	var distanceRange = 0.0005 // 55 meters, distance range to merge 2 paths which intersect together
+	// merge groups in a consistent order
+	for _, strName := range sortedStreetNames(nameMap) {
+		var strs = nameMap[strName]
 
-	for strName, strs := range nameMap {
 		// Sort streets follow the descendant length
 		strs = sortStreetsDescLength(strs)
 
@@ -1450,13 +1220,23 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 		}
 
 		var index = getLongestStreetIndex(strs)
-		var baseStreet = strs[index]
-		strs = removeStreet(strs, index)
+		var m = newStreetMerger(strs, true, rules.tolerance)
+		m.setBase(index)
+		m.remove(index)
+		var baseStreet = m.base
+
+		for i := 0; i < m.Len(); i++ {
+			// Skip the streets which can't be merged or removed in this loop
+			i = m.next(i)
 
-		for i := 0; i < len(strs); i++ {
-			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(baseStreet, strs)
-			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(baseStreet, strs)
-			var currentStreet = strs[i]
+			var shortestDistanceToOtherStreets = m.shortest
+			var shortestDistanceToOtherSameDirectionStreets = m.shortestSameDirection
+			var currentStreet = m.At(i)
+
+			// points do not have to be exact matches
+			var distances = rules.between(baseStreet, currentStreet)
+			var distanceTolerance = distances.Tolerance
+			var distanceRange = distances.CrossingLanes // distance range to merge 2 paths which intersect together
 
 			var vector1 = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
 			var vector2 = createPathVector(currentStreet.Path.First(), currentStreet.Path.Last())
@@ -1465,18 +1245,18 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 			if (currentStreet.Oneway == "yes") {
 
 				if debugMode {
-					debugStreets(baseStreet, currentStreet, normName, strs)
+					debugStreets(baseStreet, currentStreet, normName, m.shortest, m.shortestSameDirection)
 				}
 
 				shortestDistanceWhenSameDirection := shortestDistanceWhenSameDirection(baseStreet.Path, currentStreet.Path)
 
 				// Not merge streets same direction, intersect together but distance greater than distance range
-				if (baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistanceWhenSameDirection > distanceRange) ||
+				if (shortestDistanceWhenSameDirection > distanceRange && m.intersects(currentStreet.Path)) ||
 					shortestDistanceWhenSameDirection > distanceTolerance {
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i--
 
-					if (i == (len(strs) - 1) || len(strs) < 1) {
+					if (i == (m.Len() - 1) || m.Len() < 1) {
 						if _, ok := mergedStreetMap[normName]; !ok {
 							mergedStreetMap[normName] = []*street{baseStreet}
 						} else {
@@ -1500,12 +1280,13 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
 					// Debug
 					// var merged, _ = baseStreet.Path.PointSet.ToGeoJSON().MarshalJSON()
 					// fmt.Println(baseStreet.Name, " Merged Street :: ", string(merged))
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 				} else if (isTwoPathsSameDirection &&
 									 baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherSameDirectionStreets &&
@@ -1524,12 +1305,13 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
 					// Debug
 					// var merged, _ = baseStreet.Path.PointSet.ToGeoJSON().MarshalJSON()
 					// fmt.Println(baseStreet.Name, " Merged Street :: ", string(merged))
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 
 					// flip baseStreet points back
@@ -1539,7 +1321,7 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 
 					// If distance not shortest
 					if debugMode {
-						debugStreets(baseStreet, currentStreet, normName, strs)
+						debugStreets(baseStreet, currentStreet, normName, m.shortest, m.shortestSameDirection)
 					}
 
 					if ((baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherSameDirectionStreets ||
@@ -1550,7 +1332,7 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						} else {
 							mergedStreetMap[normName] = append(mergedStreetMap[normName], currentStreet)
 						}
-						strs = removeStreet(strs, i)
+						m.remove(i)
 						i--
 					}
 
@@ -1559,17 +1341,17 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 			} else {
 				// If two way
 				if debugMode {
-					debugStreets(baseStreet, currentStreet, normName, strs)
+					debugStreets(baseStreet, currentStreet, normName, m.shortest, m.shortestSameDirection)
 				}
 
 				shortestDistance := getShortestDistance(baseStreet.Path, currentStreet.Path)
 
 				// Not merge streets same direction, intersect together but distance greater than distance range
-				if (baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistance > distanceRange) {
-					strs = removeStreet(strs, i)
+				if (shortestDistance > distanceRange && m.intersects(currentStreet.Path)) {
+					m.remove(i)
 					i--
 
-					if (i == (len(strs) - 1) || len(strs) < 1) {
+					if (i == (m.Len() - 1) || m.Len() < 1) {
 						if _, ok := mergedStreetMap[normName]; !ok {
 							mergedStreetMap[normName] = []*street{baseStreet}
 						} else {
@@ -1592,8 +1374,9 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 
 				} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
@@ -1612,12 +1395,13 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
 					// flip baseStreet points back
 					reversePath(baseStreet.Path)
 					reversePath(currentStreet.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 
 				} else if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
@@ -1635,11 +1419,12 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
 					// flip currentStreet points back
 					reversePath(currentStreet.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 
 				} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
@@ -1657,18 +1442,19 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 						}
 						baseStreet.Path.Push(&point)
 					}
+					baseStreet.absorb(currentStreet)
 
 					// flip baseStreet points back
 					reversePath(baseStreet.Path)
 
-					strs = removeStreet(strs, i)
+					m.remove(i)
 					i = -1
 
 				} else {
 
 					// If two way and distance not shortest
 					if debugMode {
-						debugStreets(baseStreet, currentStreet, normName, strs)
+						debugStreets(baseStreet, currentStreet, normName, m.shortest, m.shortestSameDirection)
 					}
 
 					if (baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets ||
@@ -1681,15 +1467,15 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 							mergedStreetMap[normName] = append(mergedStreetMap[normName], currentStreet)
 						}
 
-						strs = removeStreet(strs, i)
+						m.remove(i)
 						i--
 					}
 				}
 			}
 
 			// When reach to the last item of list street, then remove the first item and loop again the list street
-			if (i == (len(strs) - 1) || len(strs) < 1) {
-				strs = removeStreet(strs, i)
+			if (i == (m.Len() - 1) || m.Len() < 1) {
+				m.remove(i)
 				i = -1
 
 				if _, ok := mergedStreetMap[normName]; !ok {
@@ -1707,7 +1493,7 @@ func mergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
 }
 
 
-func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string][]*street {
+func mergeStreet(nameMap map[string][]*street, isUseStreetName bool, rules *streetRules) map[string][]*street {
 	var reversePath = func(path *geo.Path) {
 		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
 			opp := path.PointSet.Length() - 1 - i
@@ -1717,12 +1503,10 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 
 	var mergedStreetMap = make(map[string][]*street)
 
-	// points do not have to be exact matches
-	// var distanceTolerance = 0.01 // 1000 meters
-	var distanceTolerance = 0.003 // 300 meters
-	var distanceRange = 0.0003 // roughly 30 meters
+	// merge groups in a consistent order
+	for _, strName := range sortedStreetNames(nameMap) {
+		var strs = nameMap[strName]
 
-	for strName, strs := range nameMap {
 		// Sort streets follow the descendant length
 		strs = sortStreetsDescLength(strs)
 		strs = removeRoundabout(strs)
@@ -1741,25 +1525,34 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 		}
 
 		var index = getLongestStreetIndex(strs)
-		var baseStreet = strs[index]
-		strs = removeStreet(strs, index)
+		var m = newStreetMerger(strs, true, nil)
+		m.setBase(index)
+		m.remove(index)
+		var baseStreet = m.base
+
+		for i := 0; i < m.Len(); i++ {
+			// Skip the streets which can't be merged or removed in this loop
+			i = m.next(i)
 
-		for i := 0; i < len(strs); i++ {
+			var shortestDistanceToOtherStreets = m.shortest
+			var shortestDistanceToOtherSameDirectionStreets = m.shortestSameDirection
+			var currentStreet = m.At(i)
 
-			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(baseStreet, strs)
-			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(baseStreet, strs)
-			var currentStreet = strs[i]
+			// points do not have to be exact matches
+			var distances = rules.between(baseStreet, currentStreet)
+			var distanceTolerance = distances.Tolerance
+			var distanceRange = distances.CrossingStreets
 
 			if debugMode {
-				debugStreets(baseStreet, currentStreet, normName, strs)
+				debugStreets(baseStreet, currentStreet, normName, m.shortest, m.shortestSameDirection)
 			}
 
 			// In the case the street is duplicated, then ignore the one
 			if (baseStreet.Path == currentStreet.Path) {
-				strs = removeStreet(strs, i)
+				m.remove(i)
 				i--
 
-				if (i == (len(strs) - 1) || len(strs) < 1) {
+				if (i == (m.Len() - 1) || m.Len() < 1) {
 					if _, ok := mergedStreetMap[normName]; !ok {
 						mergedStreetMap[normName] = []*street{baseStreet}
 					} else {
@@ -1781,7 +1574,7 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 			if ((shortestDistanceToOtherSameDirectionStreets == 0 &&
 				shortestDistanceToOtherSameDirectionStreets == shortestDistanceToOtherStreets) &&
 				!isTwoStreetsSameDirection &&
-				i < (len(strs) - 1)) {
+				i < (m.Len() - 1)) {
 					// strs = append(strs, strs[i])
 					continue
 			}
@@ -1789,11 +1582,11 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 			shortestDistance := getShortestDistance(baseStreet.Path, currentStreet.Path)
 
 			// Not merge streets same direction, intersect together but distance greater than distance range
-			if (baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistance > distanceRange) {
-				strs = removeStreet(strs, i)
+			if (shortestDistance > distanceRange && m.intersects(currentStreet.Path)) {
+				m.remove(i)
 				i--
 
-				if (i == (len(strs) - 1) || len(strs) < 1) {
+				if (i == (m.Len() - 1) || m.Len() < 1) {
 					if _, ok := mergedStreetMap[normName]; !ok {
 						mergedStreetMap[normName] = []*street{baseStreet}
 					} else {
@@ -1816,8 +1609,9 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 					}
 					baseStreet.Path.Push(&point)
 				}
+				baseStreet.absorb(currentStreet)
 
-				strs = removeStreet(strs, i)
+				m.remove(i)
 				i = -1
 			} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
 				shortestDistanceToOtherStreets < distanceTolerance {
@@ -1835,12 +1629,13 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 					}
 					baseStreet.Path.Push(&point)
 				}
+				baseStreet.absorb(currentStreet)
 
 				// flip baseStreet points back
 				reversePath(baseStreet.Path)
 				reversePath(currentStreet.Path)
 
-				strs = removeStreet(strs, i)
+				m.remove(i)
 				i = -1
 			} else if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
 				shortestDistanceToOtherStreets < distanceTolerance {
@@ -1857,11 +1652,12 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 					}
 					baseStreet.Path.Push(&point)
 				}
+				baseStreet.absorb(currentStreet)
 
 				// flip currentStreet points back
 				reversePath(currentStreet.Path)
 
-				strs = removeStreet(strs, i)
+				m.remove(i)
 				i = -1
 			} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
 				shortestDistanceToOtherStreets < distanceTolerance {
@@ -1878,11 +1674,12 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 					}
 					baseStreet.Path.Push(&point)
 				}
+				baseStreet.absorb(currentStreet)
 
 				// flip baseStreet points back
 				reversePath(baseStreet.Path)
 
-				strs = removeStreet(strs, i)
+				m.remove(i)
 				i = -1
 			} else {
 
@@ -1899,8 +1696,8 @@ func mergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string]
 			}
 
 			// When reach to the last item of list street, then remove the first item and loop again the list street
-			if (i == (len(strs) - 1) || len(strs) < 1) {
-				strs = removeStreet(strs, i)
+			if (i == (m.Len() - 1) || m.Len() < 1) {
+				m.remove(i)
 				i = -1
 
 				if _, ok := mergedStreetMap[normName]; !ok {
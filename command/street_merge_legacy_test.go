@@ -0,0 +1,1091 @@
+package command
+
+// the quadratic street merge implementation which was replaced by the
+// indexed version in street_merge.go, kept to check that both produce the
+// same output, see street_merge_test.go
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/missinglink/pbf/streetname"
+	geo "github.com/paulmach/go.geo"
+)
+
+func legacyJoinStreets(streets []*street, normalizer streetname.Normalizer) []*street {
+
+	var nameMap = make(map[string][]*street)
+	var ret []*street
+	// var merged = make(map[*street]bool)
+
+	for _, st := range streets {
+
+		// Normalize the street name, skipping alleys, bridges etc.
+		var normName = normalizer.Normalize(st.Name)
+		if normName == "" {
+			continue
+		}
+		// fmt.Println("debug::street::", normName)
+		// log.Println("debug::street::", normName)
+		st.Name = normName
+
+		if _, ok := nameMap[normName]; !ok {
+			nameMap[normName] = []*street{st}
+		} else {
+			nameMap[normName] = append(nameMap[normName], st)
+		}
+	}
+
+	// var distanceGroup = 0.01 // roughly 1000 meters
+	var distanceGroup = 0.003 // roughly 300 meters
+
+	var groupDistanceNameMap = make(map[string][]*street)
+
+	// Group the streets in distance not exceed 1 kilometers
+	for _, strs := range nameMap {
+		// Sort streets follow the descendant length
+		strs = legacySortStreetsDescLength(strs)
+
+		var normName = strings.ToLower(strs[0].Name)
+
+		var baseStreet *street = nil
+		var group = 1
+
+		var streetGroup []*street = nil
+
+		for i := 0; i < len(strs); i++ {
+			if i == 0 {
+				baseStreet = strs[0]
+				normName = strings.ToLower(baseStreet.Name)
+				streetGroup = []*street{baseStreet}
+
+				// Create a new group street
+				normName += "__" + strconv.Itoa(group)
+				group++
+				groupDistanceNameMap[normName] = []*street{baseStreet}
+				continue
+			}
+
+			var currentStreet = strs[i]
+
+			// Check if distance from the street with street group < range then add street to group
+			if shortestDistanceToOtherStreets(currentStreet, streetGroup) < distanceGroup {
+				streetGroup = append(streetGroup, currentStreet)
+				groupDistanceNameMap[normName] = append(groupDistanceNameMap[normName], currentStreet)
+				strs = legacyRemoveStreet(strs, i)
+				// i--
+				i = 0
+				continue
+			}
+
+			// When to final element then remove first element from array, and loop array again
+			if i == (len(strs) - 1) {
+				strs = legacyRemoveStreet(strs, 0)
+				i = -1
+			}
+		}
+	}
+
+	var groupDirectionNameMap = make(map[string][]*street)
+
+	// Group the street follow same direction together
+	for groupStreetName, strs := range groupDistanceNameMap {
+		// Sort streets follow the descendant length
+		strs = legacySortStreetsDescLength(strs)
+
+		var normName = groupStreetName
+
+		var baseStreet *street = nil
+		var baseVector *Vector = nil
+		var group = 0
+
+		for i := 0; i < len(strs); i++ {
+			if i == 0 {
+				baseStreet = strs[0]
+				baseVector = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
+			}
+
+			// Only group streets when street is oneway
+			if strs[i].Oneway == "yes" {
+				if debugMode {
+					legacyDebugStreets(baseStreet, strs[i], normName, strs)
+				}
+
+				if len(strs) <= 1 {
+					// Create a new group street
+					normName = groupStreetName + "--" + strconv.Itoa(group)
+					group++
+
+					if _, ok := groupDirectionNameMap[normName]; !ok {
+						groupDirectionNameMap[normName] = []*street{baseStreet}
+					} else {
+						groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], baseStreet)
+					}
+					continue
+				}
+
+				if i == 0 {
+					// Create a new group street
+					normName = groupStreetName + "--" + strconv.Itoa(group)
+					group++
+
+					if _, ok := groupDirectionNameMap[normName]; !ok {
+						groupDirectionNameMap[normName] = []*street{baseStreet}
+					} else {
+						groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], baseStreet)
+					}
+					continue
+				}
+
+				var currentStreet = strs[i]
+
+				var isTwoPathsSameDirection = isTwoPathsSameDirection(baseVector, createPathVector(currentStreet.Path.First(), currentStreet.Path.Last()))
+
+				// Check distance between 2 street to divide group
+				if isTwoPathsSameDirection {
+					groupDirectionNameMap[normName] = append(groupDirectionNameMap[normName], currentStreet)
+					strs = legacyRemoveStreet(strs, i)
+					i--
+				}
+
+				// When reach to the last item of list street, then remove the first item and loop again the list street
+				if i == (len(strs) - 1) {
+					strs = legacyRemoveStreet(strs, 0)
+					i = -1
+				}
+
+			} else {
+
+				if _, ok := groupDirectionNameMap[groupStreetName]; !ok {
+					groupDirectionNameMap[groupStreetName] = []*street{strs[i]}
+
+				} else {
+					groupDirectionNameMap[groupStreetName] = append(groupDirectionNameMap[groupStreetName], strs[i])
+
+				}
+				strs = legacyRemoveStreet(strs, i)
+				i--
+
+				// When reach to the last item of list street, then remove the first item and loop again the list street
+				if i == (len(strs) - 1) {
+					strs = legacyRemoveStreet(strs, 0)
+					i = -1
+				}
+			}
+		}
+	}
+
+	// DEBUG
+	var mergedStreetSameDirection = legacyMergeStreetSameDirection(groupDirectionNameMap, false)
+
+	var mergeLaneSameDirection = legacyMergeLaneSameDirection(mergedStreetSameDirection)
+
+	// Merge one way and two way street together
+	var mergedStreet = legacyMergeStreet(mergeLaneSameDirection, false)
+
+	// output lines in consistent order
+	keys := make([]string, len(mergedStreet))
+	for k := range mergedStreet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var strs = mergedStreet[k]
+
+		if strs == nil {
+			continue
+		}
+
+		for _, str := range strs {
+			ret = append(ret, str)
+		}
+	}
+
+	return ret
+}
+
+func legacySortStreetsDescLength(streets []*street) []*street {
+	var sortedStreets = streets
+
+	// for i := 0; i < len(sortedStreets); i++ {
+	// 	fmt.Println("before::length", sortedStreets[i].Path.Distance())
+	// }
+
+	for i := 0; i < len(sortedStreets)-1; i++ {
+		for j := i; j < len(sortedStreets); j++ {
+			if sortedStreets[j].Path.Distance() > sortedStreets[i].Path.Distance() {
+				temp := sortedStreets[i]
+				sortedStreets[i] = sortedStreets[j]
+				sortedStreets[j] = temp
+			}
+		}
+	}
+
+	// for i := 0; i < len(sortedStreets); i++ {
+	// 	fmt.Println("after::length", sortedStreets[i].Path.Distance())
+	// }
+
+	return sortedStreets
+}
+
+func legacyRemoveStreet(s []*street, index int) []*street {
+	var streets []*street
+	for i := 0; i < len(s); i++ {
+		if index == i {
+			continue
+		}
+		streets = append(streets, s[i])
+	}
+	return streets
+}
+
+func legacyRemoveRoundabout(streets []*street) []*street {
+	for i := 0; i < len(streets); i++ {
+		first := streets[i].Path.PointSet.First()
+		last := streets[i].Path.PointSet.Last()
+		if first.DistanceFrom(last) == 0 {
+			streets = legacyRemoveStreet(streets, i)
+			i--
+		}
+	}
+	return streets
+}
+
+func legacyMergeStreetSameDirection(nameMap map[string][]*street, isUseStreetName bool) map[string][]*street {
+	var reversePath = func(path *geo.Path) {
+		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
+			opp := path.PointSet.Length() - 1 - i
+			path.PointSet[i], path.PointSet[opp] = path.PointSet[opp], path.PointSet[i]
+		}
+	}
+
+	var mergedStreetMap = make(map[string][]*street)
+
+	// points do not have to be exact matches
+	// var distanceTolerance = 0.01 // 1000 meters
+	var distanceTolerance = 0.003 // 300 meters
+	var distanceRange = 0.00065   // 65 meters, distance range to merge 2 paths which intersect together
+	var merged = make(map[*street]bool)
+
+	for strName, strs := range nameMap {
+		// Sort streets follow the descendant length
+		strs = legacySortStreetsDescLength(strs)
+
+		var normName = strName
+
+		var str1 *street = nil
+
+		for i := 0; i < len(strs); i++ {
+
+			if len(strs) == 1 {
+				if _, ok := mergedStreetMap[normName]; !ok {
+					mergedStreetMap[normName] = []*street{strs[0]}
+				} else {
+					mergedStreetMap[normName] = append(mergedStreetMap[normName], strs[0])
+				}
+				continue
+			}
+
+			if i == 0 {
+				str1 = strs[0]
+				continue
+			}
+
+			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(str1, legacyRemoveStreet(strs, 0))
+			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(str1, legacyRemoveStreet(strs, 0))
+			var str2 = strs[i]
+
+			var vector1 = createPathVector(str1.Path.First(), str1.Path.Last())
+			var vector2 = createPathVector(str2.Path.First(), str2.Path.Last())
+			var isTwoPathsSameDirection = isTwoPathsSameDirection(vector1, vector2)
+
+			if str2.Oneway == "yes" {
+
+				if debugMode {
+					legacyDebugStreets(str1, str2, normName, strs)
+				}
+
+				shortestDistanceWhenSameDirection := shortestDistanceWhenSameDirection(str1.Path, str2.Path)
+
+				// Not merge streets same direction, intersect together but distance greater than distance range
+				if str1.Path.IntersectsPath(str2.Path) && shortestDistanceWhenSameDirection > distanceRange {
+					if i == (len(strs) - 1) {
+						strs = legacyRemoveStreet(strs, 0)
+						i = -1
+
+						normName = strName
+
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{str1}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], str1)
+						}
+					}
+					continue
+				}
+
+				if isTwoPathsSameDirection &&
+					str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherSameDirectionStreets &&
+					shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
+
+					var match = str1.Path.Last()
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					strs = legacyRemoveStreet(strs, i)
+
+					merged[str2] = true
+					i = 0
+				} else if isTwoPathsSameDirection &&
+					str1.Path.First().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherSameDirectionStreets &&
+					shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
+
+					var match = str1.Path.First()
+
+					// flip str1 & str2 points
+					reversePath(str1.Path)
+					reversePath(str2.Path)
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					strs = legacyRemoveStreet(strs, i)
+
+					// flip str1 points back
+					reversePath(str1.Path)
+					reversePath(str2.Path)
+
+					merged[str2] = true
+					i = 0
+				} else {
+
+					// If distance not shortest
+					if debugMode {
+						legacyDebugStreets(str1, str2, normName, strs)
+					}
+
+					if (str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherSameDirectionStreets ||
+						str1.Path.First().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherSameDirectionStreets) &&
+						shortestDistanceToOtherSameDirectionStreets >= distanceTolerance {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{str2}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], str2)
+						}
+						strs = legacyRemoveStreet(strs, i)
+						i--
+					} else if shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
+						// TODO
+						// fmt.Println("< mergeStreetSameDirection::Street name was not merged::oneway::street name::", str2.Name, "::wayid::", str2.WayId)
+					} else {
+						// TODO
+						// fmt.Println(">= mergeStreetSameDirection::Street name was not merged::oneway::street name::", str2.Name, "::wayid::", str2.WayId)
+					}
+
+				}
+
+			} else {
+				// If two way
+				if debugMode {
+					legacyDebugStreets(str1, str2, normName, strs)
+				}
+
+				shortestDistance := getShortestDistance(str1.Path, str2.Path)
+
+				// Not merge streets same direction, intersect together but distance greater than distance range
+				if str1.Path.IntersectsPath(str2.Path) && shortestDistance > distanceRange {
+					if i == (len(strs) - 1) {
+						strs = legacyRemoveStreet(strs, 0)
+						i = -1
+
+						normName = strName
+
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{str1}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], str1)
+						}
+					}
+					continue
+				}
+
+				if str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = str1.Path.Last()
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					strs = legacyRemoveStreet(strs, i)
+					merged[str2] = true
+					i = 0
+				} else if str1.Path.First().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = str1.Path.First()
+
+					// flip str1 & str2 points
+					reversePath(str1.Path)
+					reversePath(str2.Path)
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					// flip str1 points back
+					reversePath(str1.Path)
+					reversePath(str2.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					merged[str2] = true
+					i = 0
+				} else if str1.Path.Last().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = str1.Path.Last()
+
+					// flip str2 points
+					reversePath(str2.Path)
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					// flip str2 points back
+					reversePath(str2.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					merged[str2] = true
+					i = 0
+				} else if str1.Path.First().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = str1.Path.First()
+
+					// flip str1 points
+					reversePath(str1.Path)
+
+					// merge str2 in to str1
+					for _, point := range str2.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						str1.Path.Push(&point)
+					}
+
+					// flip str1 points back
+					reversePath(str1.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					merged[str2] = true
+					i = 0
+				} else {
+
+					// If two way and distance not shortest
+					if debugMode {
+						legacyDebugStreets(str1, str2, normName, strs)
+					}
+
+					if str1.Path.Last().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets ||
+						str1.Path.First().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets ||
+						str1.Path.Last().DistanceFrom(str2.Path.Last()) == shortestDistanceToOtherStreets ||
+						str1.Path.First().DistanceFrom(str2.Path.First()) == shortestDistanceToOtherStreets {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{str2}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], str2)
+						}
+
+						strs = legacyRemoveStreet(strs, i)
+						i--
+					} else if shortestDistanceToOtherStreets < distanceTolerance {
+						// TODO
+						// fmt.Println("< mergeStreetSameDirection::Street name was not merged::street name::", str2.Name, "::wayid::", str2.WayId)
+					} else {
+						// TODO
+						// fmt.Println(">= mergeStreetSameDirection::Street name was not merged::street name::", str2.Name, "::wayid::", str2.WayId)
+					}
+				}
+			}
+
+			// When reach to the last item of list street, then remove the first item and loop again the list street
+			if i == (len(strs)-1) || len(strs) < 1 {
+				strs = legacyRemoveStreet(strs, 0)
+				i = -1
+				normName = strName
+
+				if _, ok := mergedStreetMap[normName]; !ok {
+					mergedStreetMap[normName] = []*street{str1}
+				} else {
+					mergedStreetMap[normName] = append(mergedStreetMap[normName], str1)
+				}
+			}
+		}
+	}
+
+	return mergedStreetMap
+}
+
+func legacyMergeLaneSameDirection(nameMap map[string][]*street) map[string][]*street {
+	var reversePath = func(path *geo.Path) {
+		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
+			opp := path.PointSet.Length() - 1 - i
+			path.PointSet[i], path.PointSet[opp] = path.PointSet[opp], path.PointSet[i]
+		}
+	}
+
+	var mergedStreetMap = make(map[string][]*street)
+
+	// points do not have to be exact matches
+	// var distanceTolerance = 0.01 // 1000 meters
+	// var distanceTolerance = 0.0075 // 750 meters
+	var distanceTolerance = 0.003 // 300 meters
+	var distanceRange = 0.0005    // 55 meters, distance range to merge 2 paths which intersect together
+
+	for strName, strs := range nameMap {
+		// Sort streets follow the descendant length
+		strs = legacySortStreetsDescLength(strs)
+
+		// Debug
+		var normName = strings.Split(strName, "--")[0]
+		// var normName = strName
+
+		if len(strs) < 1 {
+			continue
+		} else if len(strs) == 1 {
+			if _, ok := mergedStreetMap[normName]; !ok {
+				mergedStreetMap[normName] = []*street{strs[0]}
+			} else {
+				mergedStreetMap[normName] = append(mergedStreetMap[normName], strs[0])
+			}
+			continue
+		}
+
+		var index = getLongestStreetIndex(strs)
+		var baseStreet = strs[index]
+		strs = legacyRemoveStreet(strs, index)
+
+		for i := 0; i < len(strs); i++ {
+			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(baseStreet, strs)
+			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(baseStreet, strs)
+			var currentStreet = strs[i]
+
+			var vector1 = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
+			var vector2 = createPathVector(currentStreet.Path.First(), currentStreet.Path.Last())
+			var isTwoPathsSameDirection = isTwoPathsSameDirection(vector1, vector2)
+
+			if currentStreet.Oneway == "yes" {
+
+				if debugMode {
+					legacyDebugStreets(baseStreet, currentStreet, normName, strs)
+				}
+
+				shortestDistanceWhenSameDirection := shortestDistanceWhenSameDirection(baseStreet.Path, currentStreet.Path)
+
+				// Not merge streets same direction, intersect together but distance greater than distance range
+				if (baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistanceWhenSameDirection > distanceRange) ||
+					shortestDistanceWhenSameDirection > distanceTolerance {
+					strs = legacyRemoveStreet(strs, i)
+					i--
+
+					if i == (len(strs)-1) || len(strs) < 1 {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{baseStreet}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+						}
+					}
+
+					continue
+				}
+
+				if isTwoPathsSameDirection &&
+					baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherSameDirectionStreets &&
+					shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
+
+					var match = baseStreet.Path.Last()
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					// Debug
+					// var merged, _ = baseStreet.Path.PointSet.ToGeoJSON().MarshalJSON()
+					// fmt.Println(baseStreet.Name, " Merged Street :: ", string(merged))
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+				} else if isTwoPathsSameDirection &&
+					baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherSameDirectionStreets &&
+					shortestDistanceToOtherSameDirectionStreets < distanceTolerance {
+
+					var match = baseStreet.Path.First()
+
+					// flip baseStreet & currentStreet points
+					reversePath(baseStreet.Path)
+					reversePath(currentStreet.Path)
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					// Debug
+					// var merged, _ = baseStreet.Path.PointSet.ToGeoJSON().MarshalJSON()
+					// fmt.Println(baseStreet.Name, " Merged Street :: ", string(merged))
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+
+					// flip baseStreet points back
+					reversePath(baseStreet.Path)
+					reversePath(currentStreet.Path)
+				} else {
+
+					// If distance not shortest
+					if debugMode {
+						legacyDebugStreets(baseStreet, currentStreet, normName, strs)
+					}
+
+					if (baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherSameDirectionStreets ||
+						baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherSameDirectionStreets) &&
+						shortestDistanceToOtherSameDirectionStreets >= distanceTolerance {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{currentStreet}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], currentStreet)
+						}
+						strs = legacyRemoveStreet(strs, i)
+						i--
+					}
+
+				}
+
+			} else {
+				// If two way
+				if debugMode {
+					legacyDebugStreets(baseStreet, currentStreet, normName, strs)
+				}
+
+				shortestDistance := getShortestDistance(baseStreet.Path, currentStreet.Path)
+
+				// Not merge streets same direction, intersect together but distance greater than distance range
+				if baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistance > distanceRange {
+					strs = legacyRemoveStreet(strs, i)
+					i--
+
+					if i == (len(strs)-1) || len(strs) < 1 {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{baseStreet}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+						}
+					}
+
+					continue
+				}
+
+				if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = baseStreet.Path.Last()
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+
+				} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = baseStreet.Path.First()
+
+					// flip baseStreet & currentStreet points
+					reversePath(baseStreet.Path)
+					reversePath(currentStreet.Path)
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					// flip baseStreet points back
+					reversePath(baseStreet.Path)
+					reversePath(currentStreet.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+
+				} else if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = baseStreet.Path.Last()
+
+					// flip currentStreet points
+					reversePath(currentStreet.Path)
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					// flip currentStreet points back
+					reversePath(currentStreet.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+
+				} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
+					shortestDistanceToOtherStreets < distanceTolerance {
+
+					var match = baseStreet.Path.First()
+
+					// flip baseStreet points
+					reversePath(baseStreet.Path)
+
+					// merge currentStreet in to baseStreet
+					for _, point := range currentStreet.Path.PointSet {
+						if point.Equals(match) {
+							continue
+						}
+						baseStreet.Path.Push(&point)
+					}
+
+					// flip baseStreet points back
+					reversePath(baseStreet.Path)
+
+					strs = legacyRemoveStreet(strs, i)
+					i = -1
+
+				} else {
+
+					// If two way and distance not shortest
+					if debugMode {
+						legacyDebugStreets(baseStreet, currentStreet, normName, strs)
+					}
+
+					if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets ||
+						baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets ||
+						baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets ||
+						baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets {
+						if _, ok := mergedStreetMap[normName]; !ok {
+							mergedStreetMap[normName] = []*street{currentStreet}
+						} else {
+							mergedStreetMap[normName] = append(mergedStreetMap[normName], currentStreet)
+						}
+
+						strs = legacyRemoveStreet(strs, i)
+						i--
+					}
+				}
+			}
+
+			// When reach to the last item of list street, then remove the first item and loop again the list street
+			if i == (len(strs)-1) || len(strs) < 1 {
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+
+				if _, ok := mergedStreetMap[normName]; !ok {
+					mergedStreetMap[normName] = []*street{baseStreet}
+				} else {
+					mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+				}
+
+			}
+			continue
+		}
+	}
+
+	return mergedStreetMap
+}
+
+func legacyMergeStreet(nameMap map[string][]*street, isUseStreetName bool) map[string][]*street {
+	var reversePath = func(path *geo.Path) {
+		for i := path.PointSet.Length()/2 - 1; i >= 0; i-- {
+			opp := path.PointSet.Length() - 1 - i
+			path.PointSet[i], path.PointSet[opp] = path.PointSet[opp], path.PointSet[i]
+		}
+	}
+
+	var mergedStreetMap = make(map[string][]*street)
+
+	// points do not have to be exact matches
+	// var distanceTolerance = 0.01 // 1000 meters
+	var distanceTolerance = 0.003 // 300 meters
+	var distanceRange = 0.0003    // roughly 30 meters
+
+	for strName, strs := range nameMap {
+		// Sort streets follow the descendant length
+		strs = legacySortStreetsDescLength(strs)
+		strs = legacyRemoveRoundabout(strs)
+
+		var normName = strings.Split(strName, "__")[0]
+
+		if len(strs) < 1 {
+			continue
+		} else if len(strs) == 1 {
+			if _, ok := mergedStreetMap[normName]; !ok {
+				mergedStreetMap[normName] = []*street{strs[0]}
+			} else {
+				mergedStreetMap[normName] = append(mergedStreetMap[normName], strs[0])
+			}
+			continue
+		}
+
+		var index = getLongestStreetIndex(strs)
+		var baseStreet = strs[index]
+		strs = legacyRemoveStreet(strs, index)
+
+		for i := 0; i < len(strs); i++ {
+
+			var shortestDistanceToOtherStreets = shortestDistanceToOtherStreets(baseStreet, strs)
+			var shortestDistanceToOtherSameDirectionStreets = shortestDistanceToOtherSameDirectionStreets(baseStreet, strs)
+			var currentStreet = strs[i]
+
+			if debugMode {
+				legacyDebugStreets(baseStreet, currentStreet, normName, strs)
+			}
+
+			// In the case the street is duplicated, then ignore the one
+			if baseStreet.Path == currentStreet.Path {
+				strs = legacyRemoveStreet(strs, i)
+				i--
+
+				if i == (len(strs)-1) || len(strs) < 1 {
+					if _, ok := mergedStreetMap[normName]; !ok {
+						mergedStreetMap[normName] = []*street{baseStreet}
+					} else {
+						mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+					}
+				}
+
+				continue
+			}
+
+			var vector1 = createPathVector(baseStreet.Path.First(), baseStreet.Path.Last())
+			var vector2 = createPathVector(currentStreet.Path.First(), currentStreet.Path.Last())
+			var isTwoStreetsSameDirection = isTwoPathsSameDirection(vector1, vector2)
+
+			// In the case shortest distance to other same direction streets = 0,
+			// or = shortest distance to other streets
+			// but 2 streets don't intersect, then add street to last of list streets and continue loop
+			// if ((shortestDistanceToOtherSameDirectionStreets == 0 ||
+			if (shortestDistanceToOtherSameDirectionStreets == 0 &&
+				shortestDistanceToOtherSameDirectionStreets == shortestDistanceToOtherStreets) &&
+				!isTwoStreetsSameDirection &&
+				i < (len(strs)-1) {
+				// strs = append(strs, strs[i])
+				continue
+			}
+
+			shortestDistance := getShortestDistance(baseStreet.Path, currentStreet.Path)
+
+			// Not merge streets same direction, intersect together but distance greater than distance range
+			if baseStreet.Path.IntersectsPath(currentStreet.Path) && shortestDistance > distanceRange {
+				strs = legacyRemoveStreet(strs, i)
+				i--
+
+				if i == (len(strs)-1) || len(strs) < 1 {
+					if _, ok := mergedStreetMap[normName]; !ok {
+						mergedStreetMap[normName] = []*street{baseStreet}
+					} else {
+						mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+					}
+				}
+
+				continue
+			}
+
+			if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
+				shortestDistanceToOtherStreets < distanceTolerance {
+
+				var match = baseStreet.Path.Last()
+
+				// merge currentStreet in to baseStreet
+				for _, point := range currentStreet.Path.PointSet {
+					if point.Equals(match) {
+						continue
+					}
+					baseStreet.Path.Push(&point)
+				}
+
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+			} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
+				shortestDistanceToOtherStreets < distanceTolerance {
+
+				var match = baseStreet.Path.First()
+
+				// flip baseStreet & currentStreet points
+				reversePath(baseStreet.Path)
+				reversePath(currentStreet.Path)
+
+				// merge currentStreet in to baseStreet
+				for _, point := range currentStreet.Path.PointSet {
+					if point.Equals(match) {
+						continue
+					}
+					baseStreet.Path.Push(&point)
+				}
+
+				// flip baseStreet points back
+				reversePath(baseStreet.Path)
+				reversePath(currentStreet.Path)
+
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+			} else if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets &&
+				shortestDistanceToOtherStreets < distanceTolerance {
+
+				var match = baseStreet.Path.Last()
+
+				// flip currentStreet points
+				reversePath(currentStreet.Path)
+
+				// merge currentStreet in to baseStreet
+				for _, point := range currentStreet.Path.PointSet {
+					if point.Equals(match) {
+						continue
+					}
+					baseStreet.Path.Push(&point)
+				}
+
+				// flip currentStreet points back
+				reversePath(currentStreet.Path)
+
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+			} else if baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets &&
+				shortestDistanceToOtherStreets < distanceTolerance {
+
+				var match = baseStreet.Path.First()
+
+				// flip baseStreet points
+				reversePath(baseStreet.Path)
+
+				// merge currentStreet in to baseStreet
+				for _, point := range currentStreet.Path.PointSet {
+					if point.Equals(match) {
+						continue
+					}
+					baseStreet.Path.Push(&point)
+				}
+
+				// flip baseStreet points back
+				reversePath(baseStreet.Path)
+
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+			} else {
+
+				if baseStreet.Path.Last().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets ||
+					baseStreet.Path.First().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets ||
+					baseStreet.Path.Last().DistanceFrom(currentStreet.Path.Last()) == shortestDistanceToOtherStreets ||
+					baseStreet.Path.First().DistanceFrom(currentStreet.Path.First()) == shortestDistanceToOtherStreets {
+					if _, ok := mergedStreetMap[normName]; !ok {
+						mergedStreetMap[normName] = []*street{currentStreet}
+					} else {
+						mergedStreetMap[normName] = append(mergedStreetMap[normName], currentStreet)
+					}
+				}
+			}
+
+			// When reach to the last item of list street, then remove the first item and loop again the list street
+			if i == (len(strs)-1) || len(strs) < 1 {
+				strs = legacyRemoveStreet(strs, i)
+				i = -1
+
+				if _, ok := mergedStreetMap[normName]; !ok {
+					mergedStreetMap[normName] = []*street{baseStreet}
+				} else {
+					mergedStreetMap[normName] = append(mergedStreetMap[normName], baseStreet)
+				}
+			}
+		}
+	}
+
+	return mergedStreetMap
+}
+
+// legacyDebugStreets - debugStreets with the distances measured by scanning
+// every street, as the original implementation did
+func legacyDebugStreets(baseStreet *street, currentStreet *street, normName string, streets []*street) {
+	debugStreets(baseStreet, currentStreet, normName, shortestDistanceToOtherStreets(baseStreet, streets), shortestDistanceToOtherSameDirectionStreets(baseStreet, streets))
+}
+
+func shortestDistanceToOtherSameDirectionStreets(current *street, streets []*street) float64 {
+	if len(streets) < 1 {
+		return 0.0
+	}
+
+	shortestDistance := shortestDistanceWhenSameDirection(current.Path, streets[0].Path)
+	for i := 1; i < len(streets); i++ {
+		distance := shortestDistanceWhenSameDirection(current.Path, streets[i].Path)
+		if shortestDistance > distance {
+			shortestDistance = distance
+		}
+	}
+
+	return shortestDistance
+}
+
+func shortestDistanceToOtherStreets(current *street, streets []*street) float64 {
+	if len(streets) < 1 {
+		return 0.0
+	}
+
+	shortestDistance := getShortestDistance(current.Path, streets[0].Path)
+	for i := 1; i < len(streets); i++ {
+		distance := getShortestDistance(current.Path, streets[i].Path)
+		if shortestDistance > distance {
+			shortestDistance = distance
+		}
+	}
+	return shortestDistance
+}
@@ -0,0 +1,297 @@
+package command
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/missinglink/pbf/streetname"
+	geo "github.com/paulmach/go.geo"
+	"github.com/stretchr/testify/assert"
+)
+
+// lowercaseNormalizer - groups streets by their lowercase name
+type lowercaseNormalizer struct{}
+
+func (n lowercaseNormalizer) Normalize(name string) string {
+	return strings.ToLower(name)
+}
+
+// randomRoad - a random walk split in to ways which share their end nodes
+func randomRoad(r *rand.Rand, name string, lon, lat float64) []*street {
+	var points []geo.Point
+	var heading = r.Float64() * 2 * math.Pi
+	for i := 0; i < 4+r.Intn(40); i++ {
+		points = append(points, geo.Point{lon, lat})
+		heading += (r.Float64() - 0.5) * 0.6
+		var step = 0.0003 + r.Float64()*0.0015
+		lon += math.Cos(heading) * step
+		lat += math.Sin(heading) * step
+	}
+
+	var oneway = ""
+	if r.Intn(4) == 0 {
+		oneway = "yes"
+	}
+
+	var ways []*street
+	for start := 0; start < len(points)-1; {
+		var end = start + 1 + r.Intn(6)
+		if end > len(points)-1 {
+			end = len(points) - 1
+		}
+		var way = append([]geo.Point{}, points[start:end+1]...)
+		if "" == oneway && r.Intn(4) == 0 {
+			for i, j := 0, len(way)-1; i < j; i, j = i+1, j-1 {
+				way[i], way[j] = way[j], way[i]
+			}
+		}
+		var path = geo.NewPath()
+		for i := range way {
+			path.Push(&way[i])
+		}
+		ways = append(ways, &street{Name: name, Path: path, Oneway: oneway})
+
+		// leave gaps and shift endpoints so that ways don't always touch
+		if r.Intn(8) == 0 {
+			end++
+		}
+		start = end
+	}
+
+	// the other carriageway of a dual carriageway
+	if "yes" == oneway && r.Intn(2) == 0 {
+		for _, way := range ways {
+			var path = geo.NewPath()
+			for i := way.Path.Length() - 1; i >= 0; i-- {
+				var point = way.Path.GetAt(i)
+				path.Push(&geo.Point{point[0] + 0.0002 + r.Float64()*0.00005, point[1] + 0.0002 + r.Float64()*0.00005})
+			}
+			ways = append(ways, &street{Name: name, Path: path, Oneway: oneway})
+		}
+	}
+
+	// duplicated ways, roundabouts and crossing ways
+	if r.Intn(4) == 0 {
+		var way = ways[r.Intn(len(ways))]
+		ways = append(ways, &street{Name: name, Path: way.Path.Clone(), Oneway: way.Oneway})
+	}
+	if r.Intn(4) == 0 {
+		var centre = points[r.Intn(len(points))]
+		var radius = 0.0001 + r.Float64()*0.0002
+		var path = geo.NewPath()
+		for i := 0; i <= 6; i++ {
+			var angle = float64(i%6) * math.Pi / 3
+			path.Push(&geo.Point{centre[0] + math.Cos(angle)*radius, centre[1] + math.Sin(angle)*radius})
+		}
+		ways = append(ways, &street{Name: name, Path: path, Oneway: "yes"})
+	}
+	if r.Intn(3) == 0 {
+		var centre = points[r.Intn(len(points))]
+		var path = geo.NewPath()
+		path.Push(&geo.Point{centre[0] - 0.001*r.Float64(), centre[1] + 0.0012*r.Float64()})
+		path.Push(&geo.Point{centre[0] + 0.0011*r.Float64(), centre[1] - 0.001*r.Float64()})
+		ways = append(ways, &street{Name: name, Path: path})
+	}
+
+	return ways
+}
+
+// randomStreets - a few roads for each of a few names
+func randomStreets(r *rand.Rand) []*street {
+	var streets []*street
+	for _, name := range []string{"Nguyen Trai", "Le Loi", "Tran Hung Dao"}[:1+r.Intn(3)] {
+		for i := 0; i < 1+r.Intn(4); i++ {
+			var lon, lat = 105.8 + r.Float64()*0.01, 21.0 + r.Float64()*0.01
+			streets = append(streets, randomRoad(r, name, lon, lat)...)
+		}
+	}
+	r.Shuffle(len(streets), func(i, j int) { streets[i], streets[j] = streets[j], streets[i] })
+	for i, s := range streets {
+		s.WayId = i + 1
+	}
+	return streets
+}
+
+// denseStreets - roads of a single name spread over an area which grows
+// with n so that the density of ways is constant
+func denseStreets(r *rand.Rand, n int) []*street {
+	var size = 0.01 * math.Sqrt(float64(n)/100)
+	var streets []*street
+	for len(streets) < n {
+		var lon, lat = 105.8 + r.Float64()*size, 21.0 + r.Float64()*size
+		streets = append(streets, randomRoad(r, "Nguyen Trai", lon, lat)...)
+	}
+	for i, s := range streets {
+		s.WayId = i + 1
+	}
+	return streets
+}
+
+func cloneStreets(streets []*street) []*street {
+	var clone []*street
+	for _, s := range streets {
+		clone = append(clone, &street{Name: s.Name, Path: s.Path.Clone(), Oneway: s.Oneway, WayId: s.WayId})
+	}
+	return clone
+}
+
+// formatStreets - one line per street, sorted as the old implementation
+// merged the streets of a name in random order
+func formatStreets(streets []*street) []string {
+	var lines []string
+	for _, s := range streets {
+		lines = append(lines, s.Name+" "+s.Path.ToWKT())
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestJoinStreetsMatchesLegacy(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		var streets = randomStreets(r)
+		var expected = formatStreets(legacyJoinStreets(cloneStreets(streets), lowercaseNormalizer{}))
+		var actual = formatStreets(joinStreets(cloneStreets(streets), lowercaseNormalizer{}, defaultStreetRules()))
+		if !assert.Equal(t, expected, actual, "seed 1, set %d", i) {
+			return
+		}
+	}
+}
+
+func BenchmarkJoinStreets(b *testing.B) {
+	for _, n := range []int{1000, 4000, 16000} {
+		var streets = denseStreets(rand.New(rand.NewSource(1)), n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				joinStreets(cloneStreets(streets), lowercaseNormalizer{}, defaultStreetRules())
+			}
+		})
+	}
+}
+
+func TestJoinStreetsChain(t *testing.T) {
+	var streets = []*street{
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(0, 0)).Push(geo.NewPoint(0.001, 0)), WayId: 1},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(0.002, 0)).Push(geo.NewPoint(0.001, 0)), WayId: 2},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(0.002, 0)).Push(geo.NewPoint(0.0035, 0)), WayId: 3},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(1, 1)).Push(geo.NewPoint(1.001, 1)), WayId: 4},
+	}
+	var joined = joinStreets(streets, lowercaseNormalizer{}, defaultStreetRules())
+	assert.Len(t, joined, 2)
+	assert.Equal(t, geo.NewPoint(0, 0), joined[0].Path.First())
+	assert.Equal(t, geo.NewPoint(0.0035, 0), joined[0].Path.Last())
+	assert.Equal(t, geo.NewPoint(1, 1), joined[1].Path.First())
+}
+
+func TestJoinStreetsProvenance(t *testing.T) {
+	var streets = []*street{
+		{Name: "Đường A", Path: geo.NewPath().Push(geo.NewPoint(0, 0)).Push(geo.NewPoint(0.001, 0)), Highway: "primary", WayId: 1},
+		{Name: "a", Path: geo.NewPath().Push(geo.NewPoint(0.002, 0)).Push(geo.NewPoint(0.001, 0)), Highway: "secondary", Oneway: "no", WayId: 2},
+		{Name: "a", Path: geo.NewPath().Push(geo.NewPoint(0.002, 0)).Push(geo.NewPoint(0.0035, 0)), Highway: "primary", WayId: 3},
+	}
+	var joined = joinStreets(streets, streetname.NewVietnamese(), defaultStreetRules())
+	assert.Len(t, joined, 1)
+
+	var ways = joined[0].Ways
+	sort.Slice(ways, func(i, j int) bool { return ways[i].ID < ways[j].ID })
+	assert.Equal(t, []streetWay{
+		{ID: 1, Name: "Đường A", Highway: "primary"},
+		{ID: 2, Name: "a", Highway: "secondary", Oneway: "no"},
+		{ID: 3, Name: "a", Highway: "primary"},
+	}, ways)
+}
+
+func TestStreetAbsorb(t *testing.T) {
+	var s = &street{Ways: []streetWay{{ID: 1}}}
+	s.absorb(&street{Ways: []streetWay{{ID: 2}, {ID: 1}}})
+	s.absorb(&street{Ways: []streetWay{{ID: 2}, {ID: 3}}})
+	assert.Equal(t, []streetWay{{ID: 1}, {ID: 2}, {ID: 3}}, s.Ways)
+}
+
+func TestJoinProvenance(t *testing.T) {
+	assert.Equal(t, "", joinProvenance(nil))
+	assert.Equal(t, `a;b\;c;d\\e`, joinProvenance([]string{"a", "b;c", `d\e`}))
+}
+
+func TestPositionSet(t *testing.T) {
+	var s = newPositionSet(10, true)
+	s.Remove(0)
+	s.Remove(4)
+	s.Remove(9)
+	assert.Equal(t, 7, s.Len())
+	assert.Equal(t, 1, s.Select(0))
+	assert.Equal(t, 5, s.Select(3))
+	assert.Equal(t, 3, s.Rank(5))
+	assert.Equal(t, 5, s.Next(4))
+	assert.Equal(t, -1, s.Next(9))
+	s.Add(4)
+	assert.Equal(t, 4, s.Next(4))
+}
+
+func TestStreetIndexNearest(t *testing.T) {
+	var r = rand.New(rand.NewSource(2))
+	var streets []*street
+	for i := 0; i < 200; i++ {
+		var lon, lat = r.Float64() * 0.1, r.Float64() * 0.1
+		streets = append(streets, &street{Path: geo.NewPath().Push(geo.NewPoint(lon, lat)).Push(geo.NewPoint(lon+0.001, lat))})
+	}
+	var index = newStreetIndex(streets, false)
+	for i := 0; i < 150; i++ {
+		index.remove(i)
+	}
+	for i := 0; i < 50; i++ {
+		var p = geo.NewPoint(r.Float64()*0.2-0.05, r.Float64()*0.2-0.05)
+		assert.Equal(t, index.scan(p, bothPoints), index.nearest(p, bothPoints))
+		assert.Equal(t, index.scan(p, lastPoints), index.nearest(p, lastPoints))
+	}
+}
+
+func TestSegmentGridIntersects(t *testing.T) {
+	var r = rand.New(rand.NewSource(3))
+	var random = func(n int) *geo.Path {
+		var path = geo.NewPath()
+		for i := 0; i < n; i++ {
+			path.Push(geo.NewPoint(r.Float64()*0.02, r.Float64()*0.02))
+		}
+		return path
+	}
+
+	// the base grows at either end and is reversed between tests
+	var grid = &segmentGrid{}
+	var base = random(3)
+	for i := 0; i < 100; i++ {
+		switch r.Intn(3) {
+		case 0:
+			base.Push(geo.NewPoint(r.Float64()*0.02, r.Float64()*0.02))
+		case 1:
+			base.PointSet = append(geo.PointSet{*geo.NewPoint(r.Float64()*0.02, r.Float64()*0.02)}, base.PointSet...)
+		case 2:
+			var points = base.PointSet
+			for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+				points[i], points[j] = points[j], points[i]
+			}
+		}
+		grid.sync(base)
+		var other = random(2)
+		assert.Equal(t, base.IntersectsPath(other), grid.intersects(other))
+	}
+}
+
+func TestStreetLocalName(t *testing.T) {
+	var s = &street{Name: "nguyen trai", Ways: []streetWay{
+		{ID: 1, Names: map[string]string{"name": "Đường Nguyễn Trãi"}},
+		{ID: 2, Names: map[string]string{"name": "Nguyễn Trãi", "name:en": "Nguyen Trai Street"}},
+	}}
+	assert.Equal(t, map[string][]string{
+		"name":    {"Đường Nguyễn Trãi", "Nguyễn Trãi"},
+		"name:en": {"Nguyen Trai Street"},
+	}, s.variants())
+
+	assert.Equal(t, "nguyen trai", s.localName(nil))
+	assert.Equal(t, "Nguyen Trai Street", s.localName([]string{"fr", "en"}))
+	assert.Equal(t, "Đường Nguyễn Trãi", s.localName([]string{"fr"}))
+}
@@ -0,0 +1,357 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+
+	"github.com/missinglink/pbf/streetname"
+	geo "github.com/paulmach/go.geo"
+)
+
+// metres in a degree of latitude
+var metresPerDegree = geo.EarthRadius * math.Pi / 180
+
+// streetDistances - the distances used to merge the ways of a street
+type streetDistances struct {
+	Group           float64 `json:"group"`            // ways further apart are separate streets
+	Tolerance       float64 `json:"tolerance"`        // the largest gap joined between two ways
+	CrossingWays    float64 `json:"crossing_ways"`    // the largest gap joined between two ways which cross
+	CrossingLanes   float64 `json:"crossing_lanes"`   // the same for the lanes of a dual carriageway
+	CrossingStreets float64 `json:"crossing_streets"` // the same for oneway and two way streets
+}
+
+// streetDistancesConfig - distances as they appear in a config file, unset
+// distances are nil so that 0 can be configured
+type streetDistancesConfig struct {
+	Group           *float64 `json:"group"`
+	Tolerance       *float64 `json:"tolerance"`
+	CrossingWays    *float64 `json:"crossing_ways"`
+	CrossingLanes   *float64 `json:"crossing_lanes"`
+	CrossingStreets *float64 `json:"crossing_streets"`
+}
+
+// streetHighway - the rules for a highway class
+type streetHighway struct {
+	streetDistancesConfig
+	Skip bool `json:"skip"`
+}
+
+// streetOverrides - explicit lists of normalized names
+type streetOverrides struct {
+	Keep   []string          `json:"keep"`   // never excluded by a pattern
+	Skip   []string          `json:"skip"`   // always excluded
+	Rename map[string]string `json:"rename"` // merged with the street of another name
+}
+
+// streetConfig - the streets config file format, distances are in metres,
+// distances which are not set use the defaults
+type streetConfig struct {
+	Distances streetDistancesConfig    `json:"distances"`
+	Highways  map[string]streetHighway `json:"highways"`
+	Prefixes  []string                 `json:"prefixes"`
+	Ignore    []string                 `json:"ignore"`
+	Exclude   []string                 `json:"exclude"`
+	Overrides streetOverrides          `json:"overrides"`
+}
+
+// defaultSkip - the default overrides.skip list, the address parser drops
+// 'ngõ' which merges this alley with the street of the same name
+var defaultSkip = []string{"ngo chu huy man"}
+
+// defaultStreetDistances - the default distances of a config file
+var defaultStreetDistances = streetDistances{
+	Group:           300,
+	Tolerance:       300,
+	CrossingWays:    65,
+	CrossingLanes:   55,
+	CrossingStreets: 30,
+}
+
+// defaultStreetConfig - the defaults for a config file, distances which are
+// not set use defaultStreetDistances
+func defaultStreetConfig() *streetConfig {
+	return &streetConfig{
+		Overrides: streetOverrides{Skip: defaultSkip},
+	}
+}
+
+// streetRules - the rules used to select and merge streets. the defaults
+// use distances in degrees, rules loaded from a config file project the
+// streets of each name so that distances are the same in all directions.
+type streetRules struct {
+	distances streetDistances
+	highways  map[string]streetDistances
+	skip      map[string]bool // highway classes
+	project   bool
+
+	prefixes, ignore []string
+	exclude          []*regexp.Regexp
+	overrides        streetOverrides
+	keepNames        map[string]bool
+	skipNames        map[string]bool
+}
+
+// defaultStreetRules - the rules used without a config file, the names
+// are the same as a config file which only sets distances
+func defaultStreetRules() *streetRules {
+	rules, _ := newStreetRules(defaultStreetConfig())
+	rules.distances = streetDistances{
+		Group:           0.003,   // roughly 300 meters
+		Tolerance:       0.003,   // roughly 300 meters
+		CrossingWays:    0.00065, // roughly 65 meters
+		CrossingLanes:   0.0005,  // roughly 55 meters
+		CrossingStreets: 0.0003,  // roughly 30 meters
+	}
+	rules.project = false
+	return rules
+}
+
+// newStreetRulesFromJSON - load the rules from a config file
+func newStreetRulesFromJSON(path string) (*streetRules, error) {
+	file, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	var conf = defaultStreetConfig()
+	if err := json.Unmarshal(file, conf); nil != err {
+		return nil, err
+	}
+
+	return newStreetRules(conf)
+}
+
+// newStreetRules - create rules from a decoded config
+func newStreetRules(conf *streetConfig) (*streetRules, error) {
+	var distances = withDefaults(conf.Distances, defaultStreetDistances)
+	var rules = &streetRules{
+		distances: metresToDegrees(distances),
+		highways:  make(map[string]streetDistances),
+		skip:      make(map[string]bool),
+		project:   true,
+		prefixes:  conf.Prefixes,
+		ignore:    conf.Ignore,
+		overrides: conf.Overrides,
+		keepNames: make(map[string]bool),
+		skipNames: make(map[string]bool),
+	}
+
+	for highway, rule := range conf.Highways {
+		if rule.Skip {
+			rules.skip[highway] = true
+			continue
+		}
+		rules.highways[highway] = metresToDegrees(withDefaults(rule.streetDistancesConfig, distances))
+	}
+
+	for _, pattern := range conf.Exclude {
+		exp, err := regexp.Compile(pattern)
+		if nil != err {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %s", pattern, err)
+		}
+		rules.exclude = append(rules.exclude, exp)
+	}
+	for _, name := range conf.Overrides.Keep {
+		rules.keepNames[name] = true
+	}
+	for _, name := range conf.Overrides.Skip {
+		rules.skipNames[name] = true
+	}
+
+	return rules, nil
+}
+
+// withDefaults - replace the unset distances of d
+func withDefaults(d streetDistancesConfig, defaults streetDistances) streetDistances {
+	var or = func(v *float64, def float64) float64 {
+		if nil != v {
+			return *v
+		}
+		return def
+	}
+	return streetDistances{
+		Group:           or(d.Group, defaults.Group),
+		Tolerance:       or(d.Tolerance, defaults.Tolerance),
+		CrossingWays:    or(d.CrossingWays, defaults.CrossingWays),
+		CrossingLanes:   or(d.CrossingLanes, defaults.CrossingLanes),
+		CrossingStreets: or(d.CrossingStreets, defaults.CrossingStreets),
+	}
+}
+
+// metresToDegrees - convert distances to degrees of latitude, which are
+// the units of projected streets
+func metresToDegrees(d streetDistances) streetDistances {
+	return streetDistances{
+		Group:           d.Group / metresPerDegree,
+		Tolerance:       d.Tolerance / metresPerDegree,
+		CrossingWays:    d.CrossingWays / metresPerDegree,
+		CrossingLanes:   d.CrossingLanes / metresPerDegree,
+		CrossingStreets: d.CrossingStreets / metresPerDegree,
+	}
+}
+
+// configure - apply the name rules to the normalizer
+func (r *streetRules) configure(normalizer streetname.Normalizer) {
+	var local *streetname.Vietnamese
+	switch n := normalizer.(type) {
+	case *streetname.Vietnamese:
+		local = n
+	case *streetname.HTTP:
+		local = n.Fallback
+		n.Skip = append(n.Skip, r.overrides.Skip...)
+	default:
+		return
+	}
+	if nil != r.prefixes {
+		local.Prefixes = r.prefixes
+	}
+	if nil != r.ignore {
+		local.Ignore = r.ignore
+	}
+}
+
+// name - the name a street of the highway class with the normalized name
+// is merged under, returns "" for streets which are skipped
+func (r *streetRules) name(highway, name string) string {
+	if "" == name || r.skip[highway] || r.skipNames[name] {
+		return ""
+	}
+	if !r.keepNames[name] {
+		for _, exp := range r.exclude {
+			if exp.MatchString(name) {
+				return ""
+			}
+		}
+	}
+	if rename, ok := r.overrides.Rename[name]; ok {
+		return rename
+	}
+	return name
+}
+
+// distancesOf - the distances for the highway class
+func (r *streetRules) distancesOf(highway string) streetDistances {
+	if d, ok := r.highways[highway]; ok {
+		return d
+	}
+	return r.distances
+}
+
+// between - the distances used to merge two streets, the larger of the
+// distances of their highway classes
+func (r *streetRules) between(a, b *street) streetDistances {
+	var da, db = r.distancesOf(a.Highway), r.distancesOf(b.Highway)
+	return streetDistances{
+		Group:           math.Max(da.Group, db.Group),
+		Tolerance:       math.Max(da.Tolerance, db.Tolerance),
+		CrossingWays:    math.Max(da.CrossingWays, db.CrossingWays),
+		CrossingLanes:   math.Max(da.CrossingLanes, db.CrossingLanes),
+		CrossingStreets: math.Max(da.CrossingStreets, db.CrossingStreets),
+	}
+}
+
+// maxGroup - the largest group distance of the streets
+func (r *streetRules) maxGroup(streets []*street) float64 {
+	var max = 0.0
+	for _, s := range streets {
+		max = math.Max(max, r.distancesOf(s.Highway).Group)
+	}
+	return max
+}
+
+// tolerance - the tolerance used to merge two streets
+func (r *streetRules) tolerance(a, b *street) float64 {
+	return r.between(a, b).Tolerance
+}
+
+// streetProjection - scales the longitudes of streets by the cosine of
+// their latitude so that a unit is a degree of latitude in every direction,
+// the original points are restored after merging
+type streetProjection struct {
+	scales   map[*street]float64
+	original map[geo.Point]geo.Point
+}
+
+// newStreetProjection - constructor
+func newStreetProjection() *streetProjection {
+	return &streetProjection{
+		scales:   make(map[*street]float64),
+		original: make(map[geo.Point]geo.Point),
+	}
+}
+
+// project - project the streets of a name in place. streets which may be
+// within distance of each other are projected together by the cosine of
+// their mean latitude, so names spread over a large area are not projected
+// by a single scale
+func (p *streetProjection) project(streets []*street, distance float64) {
+	var cos = func(lat float64) float64 { return math.Cos(lat * math.Pi / 180) }
+
+	// paths may be shared by several streets
+	var paths = make(map[*geo.Path]geo.PointSet)
+	for _, s := range streets {
+		if _, ok := paths[s.Path]; !ok {
+			paths[s.Path] = append(geo.PointSet{}, s.Path.PointSet...)
+		}
+	}
+
+	// every group scale is at least the smallest scale, streets which are
+	// further apart than distance at that scale are further apart at any
+	// group scale so they can't belong to the same distance group
+	var min = 1.0
+	for _, s := range streets {
+		min = math.Min(min, cos(s.Path.First().Lat()))
+	}
+	var scale = func(path *geo.Path, by float64) {
+		for i, point := range paths[path] {
+			path.PointSet[i] = geo.Point{point[0] * by, point[1]}
+		}
+	}
+	for path := range paths {
+		scale(path, min)
+	}
+	var find = unionStreets(streets, distance, func(a, b *street) bool {
+		return getShortestDistance(a.Path, b.Path) < distance
+	})
+
+	var components = make(map[int][]*street)
+	for i, s := range streets {
+		components[find(i)] = append(components[find(i)], s)
+	}
+	for _, component := range components {
+		var lat float64
+		for _, s := range component {
+			lat += paths[s.Path].First().Lat()
+		}
+		var by = cos(lat / float64(len(component)))
+		for _, s := range component {
+			p.scales[s] = by
+			scale(s.Path, by)
+			for i, point := range s.Path.PointSet {
+				p.original[point] = paths[s.Path][i]
+			}
+		}
+	}
+}
+
+// restore - restore the original points of projected streets
+func (p *streetProjection) restore(streets []*street) {
+	var done = make(map[*geo.Path]bool)
+	for _, s := range streets {
+		var scale, ok = p.scales[s]
+		if !ok || done[s.Path] {
+			continue
+		}
+		done[s.Path] = true
+		for i, point := range s.Path.PointSet {
+			if original, ok := p.original[point]; ok {
+				s.Path.PointSet[i] = original
+				continue
+			}
+			s.Path.PointSet[i] = geo.Point{point[0] / scale, point[1]}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/missinglink/pbf/streetname"
+	geo "github.com/paulmach/go.geo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreetRulesName(t *testing.T) {
+	var conf = defaultStreetConfig()
+	conf.Highways = map[string]streetHighway{"service": {Skip: true}}
+	conf.Exclude = []string{`^ngo `}
+	conf.Overrides = streetOverrides{
+		Keep:   []string{"ngo quyen"},
+		Skip:   []string{"le loi"},
+		Rename: map[string]string{"nguyen trai cu": "nguyen trai"},
+	}
+	rules, err := newStreetRules(conf)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "hang bac", rules.name("residential", "hang bac"))
+	assert.Equal(t, "", rules.name("service", "hang bac"))
+	assert.Equal(t, "", rules.name("residential", ""))
+	assert.Equal(t, "", rules.name("residential", "ngo chu huy man"))
+	assert.Equal(t, "ngo quyen", rules.name("residential", "ngo quyen"))
+	assert.Equal(t, "", rules.name("residential", "le loi"))
+	assert.Equal(t, "nguyen trai", rules.name("residential", "nguyen trai cu"))
+
+	// the defaults skip the same names as a config file which only sets
+	// distances, and pass the skip list to the address parser
+	var distancesOnly = defaultStreetConfig()
+	distancesOnly.Distances.Group = metres(500)
+	configured, err := newStreetRules(distancesOnly)
+	assert.Nil(t, err)
+	for _, name := range []string{"ngo chu huy man", "hang bac"} {
+		assert.Equal(t, configured.name("residential", name), defaultStreetRules().name("residential", name), name)
+	}
+	assert.Equal(t, "", defaultStreetRules().name("residential", "ngo chu huy man"))
+	var parser = streetname.NewHTTP("", 0)
+	defaultStreetRules().configure(parser)
+	assert.Equal(t, defaultSkip, parser.Skip)
+}
+
+func TestStreetRulesDistances(t *testing.T) {
+	var conf = defaultStreetConfig()
+	conf.Distances.Tolerance = metres(100)
+	conf.Highways = map[string]streetHighway{
+		"trunk":   {streetDistancesConfig: streetDistancesConfig{Group: metres(1000)}},
+		"service": {streetDistancesConfig: streetDistancesConfig{CrossingWays: metres(0)}},
+	}
+	rules, err := newStreetRules(conf)
+	assert.Nil(t, err)
+
+	var residential = rules.distancesOf("residential")
+	assert.InDelta(t, 300, residential.Group*metresPerDegree, 1e-9)
+	assert.InDelta(t, 100, residential.Tolerance*metresPerDegree, 1e-9)
+	assert.InDelta(t, 65, residential.CrossingWays*metresPerDegree, 1e-9)
+
+	// highway classes fall back to the configured distances
+	var trunk = rules.distancesOf("trunk")
+	assert.InDelta(t, 1000, trunk.Group*metresPerDegree, 1e-9)
+	assert.InDelta(t, 100, trunk.Tolerance*metresPerDegree, 1e-9)
+
+	// zero is a distance rather than unset
+	assert.Equal(t, 0.0, rules.distancesOf("service").CrossingWays)
+	assert.InDelta(t, 100, rules.distancesOf("service").Tolerance*metresPerDegree, 1e-9)
+
+	// two streets use the larger distances
+	var between = rules.between(&street{Highway: "residential"}, &street{Highway: "trunk"})
+	assert.Equal(t, trunk.Group, between.Group)
+
+	_, err = newStreetRules(&streetConfig{Exclude: []string{"("}})
+	assert.NotNil(t, err)
+}
+
+// metres - a configured distance
+func metres(v float64) *float64 {
+	return &v
+}
+
+func TestJoinStreetsProjected(t *testing.T) {
+	var conf = defaultStreetConfig()
+	conf.Distances.Tolerance = metres(100)
+	rules, err := newStreetRules(conf)
+	assert.Nil(t, err)
+
+	// at 60 degrees north a degree of longitude is half a degree of latitude,
+	// the east west gap is 150 metres and the north south gap 90 metres
+	var eastWest = []*street{
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 60)).Push(geo.NewPoint(10.01, 60)), WayId: 1},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10.0127, 60)).Push(geo.NewPoint(10.02, 60)), WayId: 2},
+	}
+	assert.Len(t, joinStreets(eastWest, lowercaseNormalizer{}, rules), 2)
+
+	var northSouth = []*street{
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 60)).Push(geo.NewPoint(10, 60.01)), WayId: 1},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 60.0108)).Push(geo.NewPoint(10, 60.02)), WayId: 2},
+	}
+	var joined = joinStreets(northSouth, lowercaseNormalizer{}, rules)
+	assert.Len(t, joined, 1)
+
+	// the original points are restored
+	assert.Equal(t, geo.NewPoint(10, 60), joined[0].Path.First())
+	assert.Equal(t, geo.NewPoint(10, 60.02), joined[0].Path.Last())
+}
+
+func TestStreetProjectionGroups(t *testing.T) {
+	var conf = defaultStreetConfig()
+	conf.Distances.Tolerance = metres(100)
+	rules, err := newStreetRules(conf)
+	assert.Nil(t, err)
+
+	// streets of the same name at the equator don't change the scale of the
+	// streets at 60 degrees north, the 83 metre gap between which is joined
+	var streets = []*street{
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 60)).Push(geo.NewPoint(10.01, 60)), WayId: 1},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10.0115, 60)).Push(geo.NewPoint(10.02, 60)), WayId: 2},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 0)).Push(geo.NewPoint(10.01, 0)), WayId: 3},
+		{Name: "A", Path: geo.NewPath().Push(geo.NewPoint(10, 0.1)).Push(geo.NewPoint(10.01, 0.1)), WayId: 4},
+	}
+	var joined = joinStreets(streets, lowercaseNormalizer{}, rules)
+	assert.Len(t, joined, 3)
+	for _, s := range joined {
+		if 60 == s.Path.First().Lat() {
+			assert.Equal(t, geo.NewPoint(10, 60), s.Path.First())
+			assert.Equal(t, geo.NewPoint(10.02, 60), s.Path.Last())
+		}
+	}
+}
@@ -13,12 +13,13 @@ require (
 	github.com/missinglink/gosmparse v0.0.0-20170628200928-01884c3f2f75
 	github.com/mmcloughlin/geohash v0.10.0
 	github.com/paulmach/go.geo v0.0.0-20180829195134-22b514266d33
-	github.com/paulmach/go.geojson v1.4.0 // indirect
+	github.com/paulmach/go.geojson v1.4.0
 	github.com/shaxbee/go-spatialite v0.0.0-20180425212100-9b4c81899e0e
 	github.com/stretchr/testify v1.6.1
 	github.com/syndtr/goleveldb v1.0.0
 	github.com/tmthrgd/go-popcount v0.0.0-20190904054823-afb1ace8b04f
 	github.com/urfave/cli v1.22.4
 	github.com/vmihailenco/msgpack v4.0.4+incompatible
+	go.etcd.io/bbolt v1.3.6
 	google.golang.org/appengine v1.6.6 // indirect
 )
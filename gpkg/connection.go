@@ -0,0 +1,89 @@
+package gpkg
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3" // required database driver
+)
+
+// see: http://www.geopackage.org/spec120/
+
+// Connection - Connection
+type Connection struct {
+	DB *sql.DB
+}
+
+// Open - open connection and create the mandatory metadata tables
+func (c *Connection) Open(path string) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		panic(err)
+	}
+	c.DB = db
+
+	// https://github.com/mattn/go-sqlite3/issues/274
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		PRAGMA application_id=1196444487;
+		PRAGMA user_version=10200;
+		PRAGMA main.synchronous=OFF;
+		PRAGMA main.journal_mode=MEMORY;
+
+		CREATE TABLE IF NOT EXISTS gpkg_spatial_ref_sys (
+		    srs_name TEXT NOT NULL,
+		    srs_id INTEGER NOT NULL PRIMARY KEY,
+		    organization TEXT NOT NULL,
+		    organization_coordsys_id INTEGER NOT NULL,
+		    definition TEXT NOT NULL,
+		    description TEXT
+		);
+		INSERT OR IGNORE INTO gpkg_spatial_ref_sys VALUES
+		    ('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+		    ('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+		    ('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AXIS["Latitude",NORTH],AXIS["Longitude",EAST],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid');
+
+		CREATE TABLE IF NOT EXISTS gpkg_contents (
+		    table_name TEXT NOT NULL PRIMARY KEY,
+		    data_type TEXT NOT NULL,
+		    identifier TEXT UNIQUE,
+		    description TEXT DEFAULT '',
+		    last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+		    min_x DOUBLE,
+		    min_y DOUBLE,
+		    max_x DOUBLE,
+		    max_y DOUBLE,
+		    srs_id INTEGER,
+		    CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS gpkg_geometry_columns (
+		    table_name TEXT NOT NULL,
+		    column_name TEXT NOT NULL,
+		    geometry_type_name TEXT NOT NULL,
+		    srs_id INTEGER NOT NULL,
+		    z TINYINT NOT NULL,
+		    m TINYINT NOT NULL,
+		    CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+		    CONSTRAINT uk_gc_table_name UNIQUE (table_name),
+		    CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+		    CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS gpkg_extensions (
+		    table_name TEXT,
+		    column_name TEXT,
+		    extension_name TEXT NOT NULL,
+		    definition TEXT NOT NULL,
+		    scope TEXT NOT NULL,
+		    CONSTRAINT ge_tce UNIQUE (table_name, column_name, extension_name)
+		);`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Close - close connection and clean up
+func (c *Connection) Close() {
+	defer c.DB.Close()
+}
@@ -0,0 +1,44 @@
+package gpkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/missinglink/pbf/lib"
+)
+
+// srs used for all geometries
+const srsID = 4326
+
+// encodeGeometry - GeoPackage binary: header, optional envelope and WKB
+func encodeGeometry(geometry lib.Geometry) []byte {
+	var buf bytes.Buffer
+
+	// magic and version
+	buf.Write([]byte{'G', 'P', 0})
+
+	// flags: little endian, envelope [minx, maxx, miny, maxy] for
+	// everything except points which don't benefit from one
+	var envelope = "POINT" != geometry.GeometryType()
+	var flags byte = 0x01
+	if envelope {
+		flags |= 0x01 << 1
+	}
+	buf.WriteByte(flags)
+
+	var b [8]byte
+	binary.LittleEndian.PutUint32(b[:4], uint32(srsID))
+	buf.Write(b[:4])
+
+	if envelope {
+		var bounds = geometry.Bounds()
+		for _, v := range []float64{bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat} {
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+			buf.Write(b[:])
+		}
+	}
+
+	buf.Write(geometry.WKB())
+	return buf.Bytes()
+}
@@ -0,0 +1,55 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// decodeHeader - split a GeoPackage binary in to its header fields,
+// envelope and WKB
+func decodeHeader(t *testing.T, data []byte) (byte, uint32, []float64, []byte) {
+	assert.Equal(t, []byte{'G', 'P', 0}, data[0:3])
+	var flags = data[3]
+	var srs = binary.LittleEndian.Uint32(data[4:8])
+	var envelope []float64
+	var offset = 8
+	if 0x01 == (flags>>1)&0x07 {
+		for i := 0; i < 4; i++ {
+			envelope = append(envelope, math.Float64frombits(binary.LittleEndian.Uint64(data[offset:offset+8])))
+			offset += 8
+		}
+	}
+	return flags, srs, envelope, data[offset:]
+}
+
+func TestEncodeGeometryPoint(t *testing.T) {
+	var point = lib.Point{1.5, -2.5}
+	flags, srs, envelope, wkb := decodeHeader(t, encodeGeometry(point))
+
+	// little endian without an envelope
+	assert.Equal(t, byte(0x01), flags)
+	assert.Equal(t, uint32(4326), srs)
+	assert.Nil(t, envelope)
+	assert.Equal(t, point.WKB(), wkb)
+}
+
+func TestEncodeGeometryEnvelope(t *testing.T) {
+	var line = lib.LineString{{1, 2}, {3, -4}, {-5, 6}}
+	flags, srs, envelope, wkb := decodeHeader(t, encodeGeometry(line))
+
+	// little endian with a [minx, maxx, miny, maxy] envelope
+	assert.Equal(t, byte(0x03), flags)
+	assert.Equal(t, uint32(4326), srs)
+	assert.Equal(t, []float64{-5, 3, -4, 6}, envelope)
+	assert.Equal(t, line.WKB(), wkb)
+
+	var mp = lib.MultiPolygon{{{{0, 0}, {2, 0}, {2, 1}, {0, 0}}}}
+	flags, _, envelope, wkb = decodeHeader(t, encodeGeometry(mp))
+	assert.Equal(t, byte(0x03), flags)
+	assert.Equal(t, []float64{0, 2, 0, 1}, envelope)
+	assert.Equal(t, mp.WKB(), wkb)
+}
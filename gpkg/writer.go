@@ -0,0 +1,257 @@
+package gpkg
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/missinglink/pbf/lib"
+)
+
+// number of features written per transaction
+var batchSize = 50000
+
+// Layer - a feature table and the geometry type it contains
+type Layer struct {
+	Name     string
+	Geometry string
+}
+
+// DefaultLayers - feature tables written by NewWriter
+var DefaultLayers = []Layer{
+	{"points", "POINT"},
+	{"lines", "LINESTRING"},
+	{"polygons", "MULTIPOLYGON"},
+}
+
+// Writer - write features to GeoPackage feature tables with one
+// column per selected tag key
+type Writer struct {
+	Conn    *Connection
+	Layers  []Layer
+	Columns []string
+
+	mutex   sync.Mutex
+	stmts   map[string]*sql.Stmt
+	rtrees  map[string]*sql.Stmt
+	bounds  map[string]*lib.BBox
+	pending int
+}
+
+// NewWriter - create the default feature tables and prepare statements
+func NewWriter(conn *Connection, columns []string) *Writer {
+	return NewLayerWriter(conn, DefaultLayers, columns)
+}
+
+// NewLayerWriter - create feature tables for layers and prepare statements,
+// features are written to the layer matching their geometry type
+func NewLayerWriter(conn *Connection, layers []Layer, columns []string) *Writer {
+	var w = &Writer{
+		Conn:    conn,
+		Layers:  layers,
+		Columns: columns,
+		stmts:   make(map[string]*sql.Stmt),
+		rtrees:  make(map[string]*sql.Stmt),
+		bounds:  make(map[string]*lib.BBox),
+	}
+
+	// tag columns
+	var defs, names, params []string
+	for _, col := range columns {
+		defs = append(defs, quote(col)+" TEXT")
+		names = append(names, quote(col))
+		params = append(params, "?")
+	}
+
+	for _, t := range layers {
+		var rtree = "rtree_" + t.Name + "_geom"
+
+		_, err := conn.DB.Exec(fmt.Sprintf(`
+			CREATE TABLE %s (
+			    fid INTEGER PRIMARY KEY AUTOINCREMENT,
+			    geom %s,
+			    osm_type TEXT NOT NULL,
+			    osm_id INTEGER NOT NULL%s
+			);
+			CREATE VIRTUAL TABLE %s USING rtree(id, minx, maxx, miny, maxy);
+			INSERT INTO gpkg_contents (table_name, data_type, identifier, srs_id) VALUES (?, 'features', ?, %d);
+			INSERT INTO gpkg_geometry_columns VALUES (?, 'geom', ?, %d, 0, 0);
+			INSERT INTO gpkg_extensions VALUES (?, 'geom', 'gpkg_rtree_index', 'http://www.geopackage.org/spec120/#extension_rtree', 'write-only');`,
+			t.Name, t.Geometry, prefixJoin(",\n", defs), rtree, srsID, srsID),
+			t.Name, t.Name, t.Name, t.Geometry, t.Name)
+		if err != nil {
+			panic(err)
+		}
+
+		stmt, err := conn.DB.Prepare(fmt.Sprintf("INSERT INTO %s (geom, osm_type, osm_id%s) VALUES (?, ?, ?%s)",
+			t.Name, prefixJoin(", ", names), prefixJoin(", ", params)))
+		if err != nil {
+			panic(err)
+		}
+		w.stmts[t.Geometry] = stmt
+
+		stmt, err = conn.DB.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?)", rtree))
+		if err != nil {
+			panic(err)
+		}
+		w.rtrees[t.Geometry] = stmt
+		w.bounds[t.Geometry] = lib.NewEmptyBBox()
+	}
+
+	// start transaction
+	_, err := conn.DB.Exec("BEGIN TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	return w
+}
+
+// WriteFeature - insert feature in to the table matching its geometry type
+func (w *Writer) WriteFeature(feature *lib.Feature) {
+
+	// polygons are always written as multipolygons
+	var geometry = feature.Geometry
+	if polygon, ok := geometry.(lib.Polygon); ok {
+		geometry = lib.MultiPolygon{polygon}
+	}
+	var typ = geometry.GeometryType()
+	var bounds = geometry.Bounds()
+
+	// tag values, missing tags are NULL
+	var args = []interface{}{encodeGeometry(geometry), feature.Type, feature.ID}
+	for _, col := range w.Columns {
+		if value, ok := feature.Tags[col]; ok {
+			args = append(args, value)
+		} else {
+			args = append(args, nil)
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// no layer for this geometry type
+	if nil == w.stmts[typ] {
+		return
+	}
+
+	res, err := w.stmts[typ].Exec(args...)
+	if err != nil {
+		log.Printf("failed to write %s %d: %s\n", feature.Type, feature.ID, err)
+		return
+	}
+
+	// spatial index
+	fid, err := res.LastInsertId()
+	if err == nil {
+		_, err = w.rtrees[typ].Exec(fid, bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	w.bounds[typ].Extend(bounds.MinLon, bounds.MinLat)
+	w.bounds[typ].Extend(bounds.MaxLon, bounds.MaxLat)
+
+	// commit periodically
+	w.pending++
+	if w.pending >= batchSize {
+		w.pending = 0
+		if _, err := w.Conn.DB.Exec("END TRANSACTION; BEGIN TRANSACTION"); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Close - commit, record extents and install the rtree triggers
+func (w *Writer) Close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for typ := range w.stmts {
+		w.stmts[typ].Close()
+		w.rtrees[typ].Close()
+	}
+
+	_, err := w.Conn.DB.Exec("END TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, t := range w.Layers {
+		var b = w.bounds[t.Geometry]
+		if b.MinLon <= b.MaxLon {
+			_, err := w.Conn.DB.Exec("UPDATE gpkg_contents SET min_x = ?, min_y = ?, max_x = ?, max_y = ? WHERE table_name = ?",
+				b.MinLon, b.MinLat, b.MaxLon, b.MaxLat, t.Name)
+			if err != nil {
+				log.Println(err)
+			}
+		}
+
+		// lookup by element id
+		_, err := w.Conn.DB.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (osm_type, osm_id)", quote(t.Name+"_osm_idx"), t.Name))
+		if err != nil {
+			log.Println(err)
+		}
+
+		// the triggers require the ST_* functions provided by GeoPackage
+		// aware clients so they are only installed once the import is done
+		_, err = w.Conn.DB.Exec(rtreeTriggers(t.Name, "geom", "fid"))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// rtreeTriggers - triggers which keep the rtree in sync with the feature table
+// see: http://www.geopackage.org/spec120/#extension_rtree
+func rtreeTriggers(t string, c string, i string) string {
+	var r = "rtree_" + t + "_" + c
+	var bbox = func(row string) string {
+		return fmt.Sprintf("ST_MinX(%[1]s.%[2]s), ST_MaxX(%[1]s.%[2]s), ST_MinY(%[1]s.%[2]s), ST_MaxY(%[1]s.%[2]s)", row, c)
+	}
+	var empty = func(row string) string {
+		return fmt.Sprintf("(%[1]s.%[2]s NOT NULL AND NOT ST_IsEmpty(%[1]s.%[2]s))", row, c)
+	}
+	return fmt.Sprintf(`
+		CREATE TRIGGER %[1]s_insert AFTER INSERT ON %[2]s WHEN %[4]s
+		BEGIN
+		    INSERT OR REPLACE INTO %[1]s VALUES (NEW.%[3]s, %[5]s);
+		END;
+		CREATE TRIGGER %[1]s_update1 AFTER UPDATE OF %[6]s ON %[2]s WHEN OLD.%[3]s = NEW.%[3]s AND %[4]s
+		BEGIN
+		    INSERT OR REPLACE INTO %[1]s VALUES (NEW.%[3]s, %[5]s);
+		END;
+		CREATE TRIGGER %[1]s_update2 AFTER UPDATE OF %[6]s ON %[2]s WHEN OLD.%[3]s = NEW.%[3]s AND (NEW.%[6]s IS NULL OR ST_IsEmpty(NEW.%[6]s))
+		BEGIN
+		    DELETE FROM %[1]s WHERE id = OLD.%[3]s;
+		END;
+		CREATE TRIGGER %[1]s_update3 AFTER UPDATE ON %[2]s WHEN OLD.%[3]s != NEW.%[3]s AND %[4]s
+		BEGIN
+		    DELETE FROM %[1]s WHERE id = OLD.%[3]s;
+		    INSERT OR REPLACE INTO %[1]s VALUES (NEW.%[3]s, %[5]s);
+		END;
+		CREATE TRIGGER %[1]s_update4 AFTER UPDATE ON %[2]s WHEN OLD.%[3]s != NEW.%[3]s AND (NEW.%[6]s IS NULL OR ST_IsEmpty(NEW.%[6]s))
+		BEGIN
+		    DELETE FROM %[1]s WHERE id IN (OLD.%[3]s, NEW.%[3]s);
+		END;
+		CREATE TRIGGER %[1]s_delete AFTER DELETE ON %[2]s WHEN OLD.%[6]s NOT NULL
+		BEGIN
+		    DELETE FROM %[1]s WHERE id = OLD.%[3]s;
+		END;`, r, t, i, empty("NEW"), bbox("NEW"), c)
+}
+
+// quote - quote an identifier, tag keys often contain ':'
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// prefixJoin - join values, prefixing the result with sep when not empty
+func prefixJoin(sep string, values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return sep + strings.Join(values, sep)
+}
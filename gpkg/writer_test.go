@@ -0,0 +1,98 @@
+package gpkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpkg")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "test.gpkg")
+
+	var conn = &Connection{}
+	conn.Open(path)
+	var w = NewWriter(conn, []string{"name", "addr:street"})
+	w.WriteFeature(&lib.Feature{Type: "node", ID: 1, Tags: map[string]string{"name": "cafe"}, Geometry: lib.Point{1, 2}})
+	w.WriteFeature(&lib.Feature{Type: "node", ID: 2, Tags: map[string]string{"addr:street": "Main Street"}, Geometry: lib.Point{3, 4}})
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 3, Geometry: lib.LineString{{0, 0}, {1, 1}}})
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 4, Geometry: lib.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}})
+	w.Close()
+	conn.Close()
+
+	// reopen the file
+	conn = &Connection{}
+	conn.Open(path)
+	defer conn.Close()
+
+	var applicationID int
+	assert.Nil(t, conn.DB.QueryRow("PRAGMA application_id").Scan(&applicationID))
+	assert.Equal(t, 0x47504B47, applicationID)
+
+	// contents with the extent of each table
+	var minX, minY, maxX, maxY float64
+	var dataType string
+	var srs int
+	assert.Nil(t, conn.DB.QueryRow("SELECT data_type, srs_id, min_x, min_y, max_x, max_y FROM gpkg_contents WHERE table_name = 'points'").
+		Scan(&dataType, &srs, &minX, &minY, &maxX, &maxY))
+	assert.Equal(t, "features", dataType)
+	assert.Equal(t, 4326, srs)
+	assert.Equal(t, []float64{1, 2, 3, 4}, []float64{minX, minY, maxX, maxY})
+
+	// geometry columns
+	var types = make(map[string]string)
+	rows, err := conn.DB.Query("SELECT table_name, geometry_type_name FROM gpkg_geometry_columns WHERE column_name = 'geom' AND srs_id = 4326 AND z = 0 AND m = 0")
+	assert.Nil(t, err)
+	for rows.Next() {
+		var table, typ string
+		assert.Nil(t, rows.Scan(&table, &typ))
+		types[table] = typ
+	}
+	rows.Close()
+	assert.Equal(t, map[string]string{"points": "POINT", "lines": "LINESTRING", "polygons": "MULTIPOLYGON"}, types)
+
+	// tag columns, polygons are written as multipolygons
+	var street *string
+	var geom []byte
+	assert.Nil(t, conn.DB.QueryRow(`SELECT "addr:street", geom FROM points WHERE osm_id = 2`).Scan(&street, &geom))
+	assert.Equal(t, "Main Street", *street)
+	assert.Equal(t, encodeGeometry(lib.Point{3, 4}), geom)
+	assert.Nil(t, conn.DB.QueryRow(`SELECT geom FROM polygons WHERE osm_type = 'way' AND osm_id = 4`).Scan(&geom))
+	assert.Equal(t, encodeGeometry(lib.MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}), geom)
+
+	// rtree rows match the features
+	var id int64
+	assert.Nil(t, conn.DB.QueryRow("SELECT id, minx, maxx, miny, maxy FROM rtree_lines_geom").Scan(&id, &minX, &maxX, &minY, &maxY))
+	var fid int64
+	assert.Nil(t, conn.DB.QueryRow("SELECT fid FROM lines WHERE osm_id = 3").Scan(&fid))
+	assert.Equal(t, fid, id)
+	assert.Equal(t, []float64{0, 1, 0, 1}, []float64{minX, maxX, minY, maxY})
+
+	// rtree extension and triggers
+	var extensions int
+	assert.Nil(t, conn.DB.QueryRow("SELECT COUNT(*) FROM gpkg_extensions WHERE extension_name = 'gpkg_rtree_index' AND column_name = 'geom'").Scan(&extensions))
+	assert.Equal(t, 3, extensions)
+	var triggers []string
+	rows, err = conn.DB.Query("SELECT name FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'points' ORDER BY name")
+	assert.Nil(t, err)
+	for rows.Next() {
+		var name string
+		assert.Nil(t, rows.Scan(&name))
+		triggers = append(triggers, name)
+	}
+	rows.Close()
+	assert.Equal(t, []string{
+		"rtree_points_geom_delete",
+		"rtree_points_geom_insert",
+		"rtree_points_geom_update1",
+		"rtree_points_geom_update2",
+		"rtree_points_geom_update3",
+		"rtree_points_geom_update4",
+	}, triggers)
+}
@@ -0,0 +1,366 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+
+	geo "github.com/paulmach/go.geo"
+)
+
+// Address - a normalized address and its position, the centroid for ways
+// and relations
+type Address struct {
+	Type         string  `json:"type"`
+	ID           int64   `json:"id"`
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	HouseNumber  string  `json:"housenumber"`
+	Unit         string  `json:"unit,omitempty"`
+	Street       string  `json:"street,omitempty"`
+	StreetSource string  `json:"street_source,omitempty"` // addr:street, associatedStreet or nearest
+	Place        string  `json:"place,omitempty"`
+	Suburb       string  `json:"suburb,omitempty"`
+	City         string  `json:"city,omitempty"`
+	Postcode     string  `json:"postcode,omitempty"`
+	State        string  `json:"state,omitempty"`
+	Country      string  `json:"country,omitempty"`
+
+	// the admin areas containing the address keyed by placetype, when an
+	// admin lookup is used
+	Admin map[string]*lib.AdminArea `json:"admin,omitempty"`
+}
+
+// AddressWriter - destination for addresses, called concurrently
+type AddressWriter interface {
+	WriteAddress(address *Address)
+}
+
+// Addresses - export addresses from nodes, ways and relations tagged with
+// addr:housenumber, when addr:street is missing the street is taken from
+// an associatedStreet relation or else the nearest named highway, unless
+// the address is part of an addr:place.
+// note: requires four passes over the file:
+// 0: relations - store associatedStreet relations and mark address members
+// 1: ways      - store refs of address members and named highways
+// 2: nodes     - store required locations
+// 3: all       - write addresses
+type Addresses struct {
+	Pass           int
+	Writer         AddressWriter
+	Locations      location.Store
+	StreetDistance float64          // metres, the nearest highway is not used when 0
+	Admin          *lib.AdminLookup // optional, adds the admin areas containing each address
+
+	mutex         sync.Mutex
+	houses        map[string]*associatedStreet // keyed by element type and id
+	streetMembers map[int64][]*associatedStreet
+	memberWays    *lib.Bitmask
+	wayRefs       map[int64][]int64
+	relationWays  map[int64][]lib.AreaMember
+	highways      map[int64]string
+	nodeRefs      *lib.Bitmask
+	indexOnce     sync.Once
+	index         *lib.RTree
+}
+
+// associatedStreet - the street name of an associatedStreet relation,
+// taken from the relation or else its street members
+type associatedStreet struct {
+	Name string
+}
+
+// highwaySegment - a segment of a named highway
+type highwaySegment struct {
+	Name string
+	A, B *geo.Point
+}
+
+// NewAddresses - constructor
+func NewAddresses(writer AddressWriter, locations location.Store) *Addresses {
+	return &Addresses{
+		Writer:        writer,
+		Locations:     locations,
+		houses:        make(map[string]*associatedStreet),
+		streetMembers: make(map[int64][]*associatedStreet),
+		memberWays:    lib.NewBitMask(),
+		wayRefs:       make(map[int64][]int64),
+		relationWays:  make(map[int64][]lib.AreaMember),
+		highways:      make(map[int64]string),
+		nodeRefs:      lib.NewBitMask(),
+	}
+}
+
+// ReadNode - called once per node
+func (a *Addresses) ReadNode(item gosmparse.Node) {
+	switch a.Pass {
+	case 2:
+		if a.nodeRefs.Has(item.ID) {
+			if err := a.Locations.WriteCoord(item); err != nil {
+				log.Println(err)
+			}
+		}
+	case 3:
+		if _, ok := item.Tags["addr:housenumber"]; ok {
+			a.write("node", item.ID, item.Tags, item.Lat, item.Lon)
+		}
+	}
+}
+
+// ReadWay - called once per way
+func (a *Addresses) ReadWay(item gosmparse.Way) {
+	_, address := item.Tags["addr:housenumber"]
+	if _, ok := item.Tags["addr:interpolation"]; ok {
+		address = false
+	}
+
+	switch a.Pass {
+	case 1:
+		var name = normalizeAddressValue(item.Tags["name"])
+		var highway = "" != item.Tags["highway"] && "" != name
+		var member = a.memberWays.Has(item.ID)
+
+		a.mutex.Lock()
+		for _, street := range a.streetMembers[item.ID] {
+			if "" == street.Name {
+				street.Name = name
+			}
+		}
+		if highway || member {
+			a.wayRefs[item.ID] = item.NodeIDs
+		}
+		if highway {
+			a.highways[item.ID] = name
+		}
+		a.mutex.Unlock()
+
+		if highway || member || address {
+			for _, nodeid := range item.NodeIDs {
+				a.nodeRefs.Insert(nodeid)
+			}
+		}
+	case 3:
+		if address {
+			if lat, lon, ok := a.centroid(item.NodeIDs); ok {
+				a.write("way", item.ID, item.Tags, lat, lon)
+			}
+		}
+	}
+}
+
+// ReadRelation - called once per relation
+func (a *Addresses) ReadRelation(item gosmparse.Relation) {
+	_, address := item.Tags["addr:housenumber"]
+
+	switch a.Pass {
+	case 0:
+		if "associatedStreet" == item.Tags["type"] {
+			var street = &associatedStreet{Name: normalizeAddressValue(item.Tags["name"])}
+			a.mutex.Lock()
+			for _, member := range item.Members {
+				switch member.Role {
+				case "house":
+					a.houses[elementKey(lib.MemberType(member.Type), member.ID)] = street
+				case "street":
+					if gosmparse.WayType == member.Type {
+						a.streetMembers[member.ID] = append(a.streetMembers[member.ID], street)
+					}
+				}
+			}
+			a.mutex.Unlock()
+		}
+
+		// the member ways of address relations, such as building multipolygons
+		if address {
+			var ways []lib.AreaMember
+			for _, member := range item.Members {
+				if gosmparse.WayType == member.Type {
+					a.memberWays.Insert(member.ID)
+					ways = append(ways, lib.AreaMember{ID: member.ID, Role: member.Role})
+				}
+			}
+			a.mutex.Lock()
+			a.relationWays[item.ID] = ways
+			a.mutex.Unlock()
+		}
+	case 3:
+		if address {
+			if lat, lon, ok := a.relationCentroid(a.relationWays[item.ID]); ok {
+				a.write("relation", item.ID, item.Tags, lat, lon)
+			}
+		}
+	}
+}
+
+// write - normalize the address tags of an element and write the address
+func (a *Addresses) write(elementType string, id int64, tags map[string]string, lat, lon float64) {
+	var tag = func(key string) string {
+		return normalizeAddressValue(tags["addr:"+key])
+	}
+
+	var address = &Address{
+		Type:        elementType,
+		ID:          id,
+		Lat:         lat,
+		Lon:         lon,
+		HouseNumber: tag("housenumber"),
+		Unit:        tag("unit"),
+		Street:      tag("street"),
+		Place:       tag("place"),
+		Suburb:      tag("suburb"),
+		City:        tag("city"),
+		Postcode:    strings.ToUpper(tag("postcode")),
+		State:       tag("state"),
+		Country:     strings.ToUpper(tag("country")),
+	}
+	if "" == address.HouseNumber {
+		return
+	}
+
+	// fall back to the associated street or the nearest named highway,
+	// addresses of an addr:place are numbered within the place so they
+	// are not given the nearest street
+	if "" != address.Street {
+		address.StreetSource = "addr:street"
+	} else if street, ok := a.houses[elementKey(elementType, id)]; ok && "" != street.Name {
+		address.Street, address.StreetSource = street.Name, "associatedStreet"
+	} else if "" == address.Place {
+		if name := a.nearestHighway(lon, lat); "" != name {
+			address.Street, address.StreetSource = name, "nearest"
+		}
+	}
+
+	if nil != a.Admin {
+		address.Admin = a.Admin.Hierarchy(lon, lat)
+	}
+
+	a.Writer.WriteAddress(address)
+}
+
+// centroid - the centroid of a way, false if a location is missing
+func (a *Addresses) centroid(refs []int64) (float64, float64, bool) {
+	if 0 == len(refs) {
+		return 0, 0, false
+	}
+	var nodes = make([]*gosmparse.Node, len(refs))
+	for i, ref := range refs {
+		node, err := a.Locations.ReadCoord(ref)
+		if nil != err || nil == node {
+			return 0, 0, false
+		}
+		nodes[i] = node
+	}
+	lon, lat := lib.WayCentroid(nodes)
+	return lat, lon, true
+}
+
+// relationCentroid - the centroid of the largest polygon assembled from
+// the member ways, relations which are not areas, such as sites, use the
+// centroid of their first member way. false if a location is missing
+func (a *Addresses) relationCentroid(members []lib.AreaMember) (float64, float64, bool) {
+	var ways = make([]lib.AreaMember, len(members))
+	for i, member := range members {
+		ways[i] = lib.AreaMember{ID: member.ID, Role: member.Role, Refs: a.wayRefs[member.ID]}
+	}
+
+	mp, err := lib.AssembleMultiPolygon(ways, func(id int64) ([]float64, bool) {
+		node, err := a.Locations.ReadCoord(id)
+		if nil != err || nil == node {
+			return nil, false
+		}
+		return []float64{node.Lon, node.Lat}, true
+	})
+	if nil != err {
+		for _, way := range ways {
+			if len(way.Refs) > 0 {
+				return a.centroid(way.Refs)
+			}
+		}
+		return 0, 0, false
+	}
+
+	var outer lib.Ring
+	var largest float64
+	for _, polygon := range mp {
+		if area := math.Abs(polygon[0].SignedArea()); nil == outer || area > largest {
+			outer, largest = polygon[0], area
+		}
+	}
+	var points = geo.NewPointSet()
+	for _, pos := range outer {
+		points.Push(geo.NewPoint(pos[0], pos[1]))
+	}
+	var point = lib.GetPolygonCentroid(points)
+	return point.Lat(), point.Lng(), true
+}
+
+// nearestHighway - the name of the nearest named highway within
+// StreetDistance of the point, empty if there is none
+func (a *Addresses) nearestHighway(lon, lat float64) string {
+	if a.StreetDistance <= 0 {
+		return ""
+	}
+	a.indexOnce.Do(a.indexHighways)
+
+	// distances are measured with longitudes scaled to the latitude
+	var metresPerDegree = geo.EarthRadius * math.Pi / 180
+	var scale = math.Cos(lat * math.Pi / 180)
+	var project = func(p *geo.Point) *geo.Point {
+		return geo.NewPoint(p.X()*scale*metresPerDegree, p.Y()*metresPerDegree)
+	}
+
+	var deg = a.StreetDistance / metresPerDegree
+	var bbox = &lib.BBox{MinLon: lon - deg/math.Max(scale, 0.01), MinLat: lat - deg, MaxLon: lon + deg/math.Max(scale, 0.01), MaxLat: lat + deg}
+	var point = project(geo.NewPoint(lon, lat))
+	var nearest string
+	var min = a.StreetDistance
+	a.index.Search(bbox, func(item lib.RTreeItem) bool {
+		var segment = item.Value.(*highwaySegment)
+		var distance = geo.NewLine(project(segment.A), project(segment.B)).DistanceFrom(point)
+		if distance <= min {
+			nearest, min = segment.Name, distance
+		}
+		return true
+	})
+	return nearest
+}
+
+// indexHighways - build a spatial index of the named highway segments
+func (a *Addresses) indexHighways() {
+	var items []lib.RTreeItem
+	for wayid, name := range a.highways {
+		var prev *geo.Point
+		for _, ref := range a.wayRefs[wayid] {
+			node, err := a.Locations.ReadCoord(ref)
+			if nil != err || nil == node {
+				prev = nil
+				continue
+			}
+			var point = geo.NewPoint(node.Lon, node.Lat)
+			if nil != prev {
+				var bounds = lib.NewEmptyBBox()
+				bounds.Extend(prev.X(), prev.Y())
+				bounds.Extend(point.X(), point.Y())
+				items = append(items, lib.RTreeItem{Bounds: bounds, Value: &highwaySegment{Name: name, A: prev, B: point}})
+			}
+			prev = point
+		}
+	}
+	a.index = lib.NewRTree(items)
+}
+
+// normalizeAddressValue - trim and collapse whitespace
+func normalizeAddressValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// elementKey - a key unique to an element, eg. 'node/1'
+func elementKey(elementType string, id int64) string {
+	return fmt.Sprintf("%s/%d", elementType, id)
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"github.com/missinglink/pbf/lib"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// BitmaskExpand - grow a mask by one 'hop', adding ways which share a node
+// with the previous mask and relations which have a member in it.
+// note: Prev is never written to, so the result does not depend on the
+// order in which elements are read.
+type BitmaskExpand struct {
+	Prev  *lib.BitmaskMap
+	Masks *lib.BitmaskMap
+}
+
+// ReadNode - called once per node
+func (b *BitmaskExpand) ReadNode(item gosmparse.Node) { /* noop */ }
+
+// ReadWay - called once per way
+func (b *BitmaskExpand) ReadWay(item gosmparse.Way) {
+
+	// already in mask
+	if b.Prev.Ways.Has(item.ID) {
+		return
+	}
+
+	// way must share at least one node with the previous mask
+	for _, ref := range item.NodeIDs {
+		if b.Prev.Nodes.Has(ref) || b.Prev.WayRefs.Has(ref) {
+			b.Masks.Ways.Insert(item.ID)
+
+			// insert dependents in mask
+			for _, ref := range item.NodeIDs {
+				b.Masks.WayRefs.Insert(ref)
+			}
+			return
+		}
+	}
+}
+
+// ReadRelation - called once per relation
+func (b *BitmaskExpand) ReadRelation(item gosmparse.Relation) {
+
+	// already in mask
+	if b.Prev.Relations.Has(item.ID) {
+		return
+	}
+
+	// relation must have at least one member in the previous mask
+	for _, member := range item.Members {
+		var found = false
+		switch member.Type {
+		case gosmparse.NodeType:
+			found = b.Prev.Nodes.Has(member.ID) || b.Prev.WayRefs.Has(member.ID)
+		case gosmparse.WayType:
+			found = b.Prev.Ways.Has(member.ID)
+		case gosmparse.RelationType:
+			found = b.Prev.Relations.Has(member.ID)
+		}
+		if found {
+			b.Masks.Relations.Insert(item.ID)
+			return
+		}
+	}
+}
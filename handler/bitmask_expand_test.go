@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitmaskExpand(t *testing.T) {
+	var prev = lib.NewBitmaskMap()
+	prev.Nodes.Insert(1)
+	prev.Relations.Insert(100)
+
+	var b = &BitmaskExpand{Prev: prev, Masks: prev.Clone()}
+	var ways = []gosmparse.Way{
+		{ID: 10, NodeIDs: []int64{1, 2}},
+		{ID: 11, NodeIDs: []int64{2, 3}},
+	}
+	var relations = []gosmparse.Relation{
+		{ID: 200, Members: []gosmparse.RelationMember{{ID: 1, Type: gosmparse.NodeType}}},
+		{ID: 201, Members: []gosmparse.RelationMember{{ID: 100, Type: gosmparse.RelationType}}},
+		{ID: 202, Members: []gosmparse.RelationMember{{ID: 10, Type: gosmparse.WayType}}},
+		{ID: 203, Members: []gosmparse.RelationMember{{ID: 2, Type: gosmparse.NodeType}}},
+	}
+	for _, way := range ways {
+		b.ReadWay(way)
+	}
+	for _, relation := range relations {
+		b.ReadRelation(relation)
+	}
+
+	// one hop from the previous mask
+	assert.True(t, b.Masks.Ways.Has(10))
+	assert.True(t, b.Masks.WayRefs.Has(2))
+	assert.False(t, b.Masks.Ways.Has(11))
+	assert.True(t, b.Masks.Relations.Has(200))
+	assert.True(t, b.Masks.Relations.Has(201))
+
+	// the way added by this hop is not used until the next hop
+	assert.False(t, b.Masks.Relations.Has(202))
+	assert.False(t, b.Masks.Relations.Has(203))
+	assert.False(t, prev.Ways.Has(10))
+
+	// the next hop
+	b = &BitmaskExpand{Prev: b.Masks.Clone(), Masks: b.Masks}
+	for _, way := range ways {
+		b.ReadWay(way)
+	}
+	for _, relation := range relations {
+		b.ReadRelation(relation)
+	}
+	assert.True(t, b.Masks.Ways.Has(11))
+	assert.True(t, b.Masks.Relations.Has(202))
+	assert.True(t, b.Masks.Relations.Has(203))
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/missinglink/pbf/lib"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// BitmaskSpatial - select elements which fall within a region,
+// optionally also requiring that they match a feature set.
+// note: requires three passes over the file; one per element type
+type BitmaskSpatial struct {
+	Pass       int
+	Masks      *lib.BitmaskMap
+	Features   *lib.FeatureSet
+	Region     lib.Region
+	InsideNode *lib.Bitmask
+	InsideWay  *lib.Bitmask
+
+	// relations are inside when a member relation is inside, parents read
+	// before their members wait for them to be found inside
+	mutex          sync.Mutex
+	insideRelation map[int64]bool
+	waiting        map[int64][]int64 // member relation -> parents
+	matched        map[int64]bool    // waiting parents which match Features
+}
+
+// ReadNode - called once per node
+func (b *BitmaskSpatial) ReadNode(item gosmparse.Node) {
+
+	// only run on first pass
+	if b.Pass != 0 {
+		return
+	}
+
+	// coordinate must be within the region
+	if !b.Region.Contains(item.Lon, item.Lat) {
+		return
+	}
+	b.InsideNode.Insert(item.ID)
+
+	if nil == b.Features || b.Features.MatchNode(item) {
+		b.Masks.Nodes.Insert(item.ID)
+	}
+}
+
+// ReadWay - called once per way
+func (b *BitmaskSpatial) ReadWay(item gosmparse.Way) {
+
+	// only run on second pass
+	if b.Pass != 1 {
+		return
+	}
+
+	// at least one vertex must be within the region
+	var inside = false
+	for _, ref := range item.NodeIDs {
+		if b.InsideNode.Has(ref) {
+			inside = true
+			break
+		}
+	}
+	if !inside {
+		return
+	}
+	b.InsideWay.Insert(item.ID)
+
+	if nil == b.Features || b.Features.MatchWay(item) {
+		b.Masks.Ways.Insert(item.ID)
+
+		// insert dependents in mask
+		for _, ref := range item.NodeIDs {
+			b.Masks.WayRefs.Insert(ref)
+		}
+	}
+}
+
+// ReadRelation - called once per relation
+func (b *BitmaskSpatial) ReadRelation(item gosmparse.Relation) {
+
+	// only run on third pass
+	if b.Pass != 2 {
+		return
+	}
+
+	var matched = nil == b.Features || b.Features.MatchRelation(item)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if nil == b.insideRelation {
+		b.insideRelation = make(map[int64]bool)
+		b.waiting = make(map[int64][]int64)
+		b.matched = make(map[int64]bool)
+	}
+
+	// at least one member must be within the region, member relations are
+	// inside when one of their own members is
+	var members []int64
+	for _, member := range item.Members {
+		switch member.Type {
+		case gosmparse.NodeType:
+			if b.InsideNode.Has(member.ID) {
+				b.inside(item.ID, matched)
+				return
+			}
+		case gosmparse.WayType:
+			if b.InsideWay.Has(member.ID) {
+				b.inside(item.ID, matched)
+				return
+			}
+		case gosmparse.RelationType:
+			if b.insideRelation[member.ID] {
+				b.inside(item.ID, matched)
+				return
+			}
+			members = append(members, member.ID)
+		}
+	}
+
+	// wait for a member relation which has not been read yet
+	for _, id := range members {
+		b.waiting[id] = append(b.waiting[id], item.ID)
+	}
+	if len(members) > 0 {
+		b.matched[item.ID] = matched
+	}
+}
+
+// inside - mark a relation as inside the region along with the parents
+// waiting on it, the caller must hold the mutex
+func (b *BitmaskSpatial) inside(id int64, matched bool) {
+	if b.insideRelation[id] {
+		return
+	}
+	b.insideRelation[id] = true
+	if matched {
+		b.Masks.Relations.Insert(id)
+	}
+
+	var parents = b.waiting[id]
+	delete(b.waiting, id)
+	for _, parent := range parents {
+		b.inside(parent, b.matched[parent])
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// spatialFixture - a way crossing the region edge, a way outside it and
+// relations of relations, some read before their members
+var spatialFixture = struct {
+	nodes     []gosmparse.Node
+	ways      []gosmparse.Way
+	relations []gosmparse.Relation
+}{
+	nodes: []gosmparse.Node{
+		{ID: 1, Lat: 0.5, Lon: 0.5},
+		{ID: 2, Lat: 5, Lon: 5},
+		{ID: 3, Lat: 6, Lon: 6},
+	},
+	ways: []gosmparse.Way{
+		{ID: 10, NodeIDs: []int64{1, 2}, Tags: map[string]string{"highway": "primary"}},
+		{ID: 11, NodeIDs: []int64{2, 3}, Tags: map[string]string{"highway": "primary"}},
+	},
+	relations: []gosmparse.Relation{
+		{ID: 202, Members: []gosmparse.RelationMember{{ID: 200, Type: gosmparse.RelationType}}},
+		{ID: 200, Tags: map[string]string{"type": "boundary"}, Members: []gosmparse.RelationMember{
+			{ID: 101, Type: gosmparse.RelationType, Role: "subarea"},
+			{ID: 100, Type: gosmparse.RelationType, Role: "subarea"},
+		}},
+		{ID: 201, Members: []gosmparse.RelationMember{{ID: 101, Type: gosmparse.RelationType}}},
+		{ID: 100, Tags: map[string]string{"type": "boundary"}, Members: []gosmparse.RelationMember{{ID: 10, Type: gosmparse.WayType}}},
+		{ID: 101, Members: []gosmparse.RelationMember{{ID: 2, Type: gosmparse.NodeType}}},
+		{ID: 203, Members: []gosmparse.RelationMember{{ID: 100, Type: gosmparse.RelationType}}},
+	},
+}
+
+// runSpatial - run the three passes of the handler over the fixture
+func runSpatial(features *lib.FeatureSet) *lib.BitmaskMap {
+	var b = &BitmaskSpatial{
+		Masks:      lib.NewBitmaskMap(),
+		Features:   features,
+		Region:     &lib.BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1},
+		InsideNode: lib.NewBitMask(),
+		InsideWay:  lib.NewBitMask(),
+	}
+	for b.Pass = 0; b.Pass < 3; b.Pass++ {
+		for _, node := range spatialFixture.nodes {
+			b.ReadNode(node)
+		}
+		for _, way := range spatialFixture.ways {
+			b.ReadWay(way)
+		}
+		for _, relation := range spatialFixture.relations {
+			b.ReadRelation(relation)
+		}
+	}
+	return b.Masks
+}
+
+func TestBitmaskSpatial(t *testing.T) {
+	var masks = runSpatial(nil)
+
+	assert.True(t, masks.Nodes.Has(1))
+	assert.False(t, masks.Nodes.Has(2))
+	assert.True(t, masks.Ways.Has(10))
+	assert.False(t, masks.Ways.Has(11))
+	assert.True(t, masks.WayRefs.Has(2))
+
+	// direct members
+	assert.True(t, masks.Relations.Has(100))
+	assert.False(t, masks.Relations.Has(101))
+
+	// member relations, read before and after their parents
+	assert.True(t, masks.Relations.Has(200))
+	assert.True(t, masks.Relations.Has(202))
+	assert.True(t, masks.Relations.Has(203))
+	assert.False(t, masks.Relations.Has(201))
+}
+
+func TestBitmaskSpatialFeatures(t *testing.T) {
+	var features = lib.NewFeatureSetFromConfig(lib.Config{"relation": lib.Group{{"type=boundary"}}})
+	var masks = runSpatial(features)
+
+	// relations which don't match are still used to find their parents
+	assert.True(t, masks.Relations.Has(100))
+	assert.True(t, masks.Relations.Has(200))
+	assert.False(t, masks.Relations.Has(202))
+	assert.False(t, masks.Relations.Has(203))
+}
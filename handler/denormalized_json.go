@@ -5,8 +5,8 @@ import (
 
 	"github.com/missinglink/gosmparse"
 	"github.com/missinglink/pbf/json"
-	"github.com/missinglink/pbf/leveldb"
 	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
 	"github.com/missinglink/pbf/tags"
 	"github.com/mmcloughlin/geohash"
 )
@@ -14,7 +14,7 @@ import (
 // DenormalizedJSON - JSON
 type DenormalizedJSON struct {
 	Writer          *lib.BufferedWriter
-	Conn            *leveldb.Connection
+	Locations       location.Store
 	ComputeCentroid bool
 	ComputeGeohash  bool
 	ExportLatLons   bool
@@ -50,7 +50,7 @@ func (d *DenormalizedJSON) ReadWay(item gosmparse.Way) {
 	// collect dependant node refs from store
 	var refs = make([]*gosmparse.Node, 0, len(item.NodeIDs))
 	for _, ref := range item.NodeIDs {
-		var node, readError = d.Conn.ReadCoord(ref)
+		var node, readError = d.Locations.ReadCoord(ref)
 		if nil != readError {
 			// skip ways which fail to denormalize
 			log.Printf("skipping way %d. failed to load ref %d\n", item.ID, ref)
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"log"
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+	"github.com/missinglink/pbf/tags"
+)
+
+// Geometry - build point, linestring and (multi)polygon geometries for
+// tagged nodes, ways and multipolygon/boundary relations.
+// note: requires four passes over the file:
+// 0: relations - remember areas and mark their member ways
+// 1: ways      - mark refs required and store refs of member ways
+// 2: nodes     - store required locations and write points
+// 3: ways      - write linestrings and polygons
+// relations are written by calling Flush after the final pass.
+type Geometry struct {
+	Pass      int
+	Writer    lib.FeatureWriter
+	Locations location.Store
+	Features  *lib.FeatureSet // optional, only write matching elements
+
+	mutex      sync.Mutex
+	relations  []gosmparse.Relation
+	memberWays *lib.Bitmask
+	wayRefs    map[int64][]int64
+	nodeRefs   *lib.Bitmask
+}
+
+// NewGeometry - constructor
+func NewGeometry(writer lib.FeatureWriter, locations location.Store) *Geometry {
+	return &Geometry{
+		Writer:     writer,
+		Locations:  locations,
+		memberWays: lib.NewBitMask(),
+		wayRefs:    make(map[int64][]int64),
+		nodeRefs:   lib.NewBitMask(),
+	}
+}
+
+// ReadNode - called once per node
+func (g *Geometry) ReadNode(item gosmparse.Node) {
+	if g.Pass != 2 {
+		return
+	}
+
+	// store locations required by ways
+	if g.nodeRefs.Has(item.ID) {
+		if err := g.Locations.WriteCoord(item); err != nil {
+			log.Println(err)
+		}
+	}
+
+	// write points
+	item.Tags = cleanTags(item.Tags)
+	if len(item.Tags) == 0 || (nil != g.Features && !g.Features.MatchNode(item)) {
+		return
+	}
+	g.Writer.WriteFeature(&lib.Feature{
+		Type:     "node",
+		ID:       item.ID,
+		Tags:     item.Tags,
+		Geometry: lib.Point{item.Lon, item.Lat},
+	})
+}
+
+// ReadWay - called once per way
+func (g *Geometry) ReadWay(item gosmparse.Way) {
+	switch g.Pass {
+	case 1:
+		var member = g.memberWays.Has(item.ID)
+		if member {
+			g.mutex.Lock()
+			g.wayRefs[item.ID] = item.NodeIDs
+			g.mutex.Unlock()
+		}
+		if member || g.wantWay(&item) {
+			for _, ref := range item.NodeIDs {
+				g.nodeRefs.Insert(ref)
+			}
+		}
+
+	case 3:
+		if !g.wantWay(&item) {
+			return
+		}
+		coords, ok := g.coords(item.NodeIDs)
+		if !ok {
+			log.Printf("skipping way %d. missing node locations\n", item.ID)
+			return
+		}
+
+		var tags = cleanTags(item.Tags)
+		var geometry lib.Geometry = lib.LineString(coords)
+		if len(coords) >= 4 && item.NodeIDs[0] == item.NodeIDs[len(item.NodeIDs)-1] && lib.IsArea(tags) {
+			geometry = lib.Polygon{lib.Ring(coords)}
+		}
+
+		g.Writer.WriteFeature(&lib.Feature{
+			Type:     "way",
+			ID:       item.ID,
+			Tags:     tags,
+			Geometry: geometry,
+		})
+	}
+}
+
+// ReadRelation - called once per relation
+func (g *Geometry) ReadRelation(item gosmparse.Relation) {
+	if g.Pass != 0 {
+		return
+	}
+
+	// only areas are supported
+	if "multipolygon" != item.Tags["type"] && "boundary" != item.Tags["type"] {
+		return
+	}
+	if nil != g.Features && !g.Features.MatchRelation(item) {
+		return
+	}
+
+	for _, member := range item.Members {
+		if gosmparse.WayType == member.Type {
+			g.memberWays.Insert(member.ID)
+		}
+	}
+
+	g.mutex.Lock()
+	g.relations = append(g.relations, item)
+	g.mutex.Unlock()
+}
+
+// Flush - write relation geometries, call after the final pass
+func (g *Geometry) Flush() {
+	for _, item := range g.relations {
+		geometry, err := g.multiPolygon(&item)
+		if nil != err {
+			log.Printf("skipping relation %d. %s\n", item.ID, err)
+			continue
+		}
+
+		g.Writer.WriteFeature(&lib.Feature{
+			Type:     "relation",
+			ID:       item.ID,
+			Tags:     cleanTags(item.Tags),
+			Geometry: geometry,
+		})
+	}
+}
+
+// multiPolygon - assemble the member ways of a relation in to a multipolygon
+func (g *Geometry) multiPolygon(item *gosmparse.Relation) (lib.MultiPolygon, error) {
+	var members []lib.AreaMember
+	for _, member := range item.Members {
+		if gosmparse.WayType != member.Type {
+			continue
+		}
+		members = append(members, lib.AreaMember{
+			ID:   member.ID,
+			Role: member.Role,
+			Refs: g.wayRefs[member.ID],
+		})
+	}
+
+	return lib.AssembleMultiPolygon(members, func(id int64) ([]float64, bool) {
+		node, err := g.Locations.ReadCoord(id)
+		if nil != err {
+			return nil, false
+		}
+		return []float64{node.Lon, node.Lat}, true
+	})
+}
+
+// coords - load locations for refs
+func (g *Geometry) coords(refs []int64) ([][]float64, bool) {
+	var coords = make([][]float64, 0, len(refs))
+	for _, ref := range refs {
+		node, err := g.Locations.ReadCoord(ref)
+		if nil != err {
+			return nil, false
+		}
+		coords = append(coords, []float64{node.Lon, node.Lat})
+	}
+	return coords, len(coords) >= 2
+}
+
+// wantWay - yes/no if the way should be written
+func (g *Geometry) wantWay(item *gosmparse.Way) bool {
+	if len(item.NodeIDs) < 2 || len(cleanTags(item.Tags)) == 0 {
+		return false
+	}
+	return nil == g.Features || g.Features.MatchWay(*item)
+}
+
+// cleanTags - trim and discard tags which are not useful in geometry exports
+func cleanTags(t map[string]string) map[string]string {
+	t = tags.Trim(t)
+	DeleteTags(t, discardableTags)
+	return t
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+	"github.com/stretchr/testify/assert"
+)
+
+// featureCollector - collect written features, counting repeats
+type featureCollector struct {
+	mutex    sync.Mutex
+	features map[string]*lib.Feature
+	counts   map[string]int
+}
+
+func (c *featureCollector) WriteFeature(feature *lib.Feature) {
+	var key = fmt.Sprintf("%s/%d", feature.Type, feature.ID)
+	c.mutex.Lock()
+	c.features[key] = feature
+	c.counts[key]++
+	c.mutex.Unlock()
+}
+
+func TestGeometry(t *testing.T) {
+	var nodes = []gosmparse.Node{
+		{ID: 1, Lat: 0.5, Lon: 0.5, Tags: map[string]string{"amenity": "cafe"}},
+		{ID: 10, Lat: 0, Lon: 0}, {ID: 11, Lat: 0, Lon: 1}, {ID: 12, Lat: 1, Lon: 1}, {ID: 13, Lat: 1, Lon: 0},
+		{ID: 20, Lat: 2, Lon: 2}, {ID: 21, Lat: 3, Lon: 3},
+		{ID: 30, Lat: 10, Lon: 10}, {ID: 31, Lat: 10, Lon: 20}, {ID: 32, Lat: 20, Lon: 20}, {ID: 33, Lat: 20, Lon: 10},
+		{ID: 34, Lat: 14, Lon: 14}, {ID: 35, Lat: 14, Lon: 16}, {ID: 36, Lat: 16, Lon: 16}, {ID: 37, Lat: 16, Lon: 14},
+	}
+	var ways = []gosmparse.Way{
+		{ID: 100, NodeIDs: []int64{20, 21}, Tags: map[string]string{"highway": "residential"}},
+		{ID: 101, NodeIDs: []int64{10, 11, 12, 13, 10}, Tags: map[string]string{"building": "yes"}},
+
+		// multipolygon members, an outer ring split in two and an inner ring
+		// with only a discardable tag
+		{ID: 200, NodeIDs: []int64{30, 31, 32}},
+		{ID: 201, NodeIDs: []int64{32, 33, 30}},
+		{ID: 202, NodeIDs: []int64{34, 35, 36, 37, 34}, Tags: map[string]string{"created_by": "JOSM"}},
+	}
+	var relations = []gosmparse.Relation{
+		{ID: 300, Tags: map[string]string{"type": "multipolygon", "landuse": "forest"}, Members: []gosmparse.RelationMember{
+			{ID: 200, Type: gosmparse.WayType, Role: "outer"},
+			{ID: 201, Type: gosmparse.WayType, Role: "outer"},
+			{ID: 202, Type: gosmparse.WayType, Role: "inner"},
+		}},
+		{ID: 301, Tags: map[string]string{"type": "route"}, Members: []gosmparse.RelationMember{
+			{ID: 100, Type: gosmparse.WayType},
+		}},
+	}
+
+	var writer = &featureCollector{features: make(map[string]*lib.Feature), counts: make(map[string]int)}
+	var g = NewGeometry(writer, location.NewMemory())
+	for g.Pass = 0; g.Pass < 4; g.Pass++ {
+		for _, node := range nodes {
+			g.ReadNode(node)
+		}
+		for _, way := range ways {
+			g.ReadWay(way)
+		}
+		for _, relation := range relations {
+			g.ReadRelation(relation)
+		}
+	}
+	g.Flush()
+
+	// every feature is written once, member ways are only written as
+	// part of their relation
+	assert.Len(t, writer.features, 4)
+	for key, count := range writer.counts {
+		assert.Equal(t, 1, count, key)
+	}
+
+	assert.Equal(t, lib.Point{0.5, 0.5}, writer.features["node/1"].Geometry)
+	assert.Equal(t, lib.LineString{{2, 2}, {3, 3}}, writer.features["way/100"].Geometry)
+	assert.IsType(t, lib.Polygon{}, writer.features["way/101"].Geometry)
+
+	var relation = writer.features["relation/300"]
+	assert.Equal(t, "forest", relation.Tags["landuse"])
+	mp, ok := relation.Geometry.(lib.MultiPolygon)
+	assert.True(t, ok)
+	assert.Len(t, mp, 1)
+	assert.Len(t, mp[0], 2)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
+)
+
+// Interpolation - collect addr:interpolation ways, the locations of their
+// nodes and the address tags of the nodes which have a house number.
+// note: requires two passes over the file:
+// 0: ways  - store interpolation ways and mark their refs
+// 1: nodes - store the locations and addresses of marked refs
+type Interpolation struct {
+	Pass      int
+	Locations location.Store
+	Ways      []gosmparse.Way
+	Addresses map[int64]map[string]string // addr:* tags of nodes with a house number
+
+	mutex    sync.Mutex
+	nodeRefs *lib.Bitmask
+}
+
+// NewInterpolation - constructor
+func NewInterpolation(locations location.Store) *Interpolation {
+	return &Interpolation{
+		Locations: locations,
+		Addresses: make(map[int64]map[string]string),
+		nodeRefs:  lib.NewBitMask(),
+	}
+}
+
+// ReadNode - called once per node
+func (i *Interpolation) ReadNode(item gosmparse.Node) {
+	if i.Pass != 1 || !i.nodeRefs.Has(item.ID) {
+		return
+	}
+
+	if err := i.Locations.WriteCoord(item); err != nil {
+		log.Println(err)
+	}
+
+	if _, ok := item.Tags["addr:housenumber"]; ok {
+		var address = AddressTags(item.Tags)
+		i.mutex.Lock()
+		i.Addresses[item.ID] = address
+		i.mutex.Unlock()
+	}
+}
+
+// ReadWay - called once per way
+func (i *Interpolation) ReadWay(item gosmparse.Way) {
+	if i.Pass != 0 || "" == strings.TrimSpace(item.Tags["addr:interpolation"]) || len(item.NodeIDs) < 2 {
+		return
+	}
+
+	for _, nodeid := range item.NodeIDs {
+		i.nodeRefs.Insert(nodeid)
+	}
+
+	i.mutex.Lock()
+	i.Ways = append(i.Ways, item)
+	i.mutex.Unlock()
+}
+
+// ReadRelation - called once per relation
+func (i *Interpolation) ReadRelation(item gosmparse.Relation) {
+	// noop
+}
+
+// AddressTags - the addr:* tags of an element, values are trimmed and
+// empty values are removed
+func AddressTags(tags map[string]string) map[string]string {
+	var address = make(map[string]string)
+	for k, v := range tags {
+		if v = strings.TrimSpace(v); strings.HasPrefix(k, "addr:") && "" != v {
+			address[k] = v
+		}
+	}
+	return address
+}
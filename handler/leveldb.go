@@ -3,7 +3,7 @@ package handler
 import (
 	"log"
 
-	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/tags"
 
 	"github.com/missinglink/gosmparse"
@@ -11,7 +11,8 @@ import (
 
 // LevelDB - LevelDB
 type LevelDB struct {
-	Conn *leveldb.Connection
+	Conn  lib.ElementStore
+	Index lib.TagIndex // optional
 }
 
 // ReadNode - called once per node
@@ -27,6 +28,13 @@ func (s *LevelDB) ReadNode(item gosmparse.Node) {
 	if err != nil {
 		log.Println(err)
 	}
+
+	// update tag index
+	if nil != s.Index {
+		if err := s.Index.IndexTags("node", item.ID, item.Tags); err != nil {
+			log.Println(err)
+		}
+	}
 }
 
 // ReadWay - called once per way
@@ -42,6 +50,13 @@ func (s *LevelDB) ReadWay(item gosmparse.Way) {
 	if err != nil {
 		log.Println(err)
 	}
+
+	// update tag index
+	if nil != s.Index {
+		if err := s.Index.IndexTags("way", item.ID, item.Tags); err != nil {
+			log.Println(err)
+		}
+	}
 }
 
 // ReadRelation - called once per relation
@@ -57,4 +72,11 @@ func (s *LevelDB) ReadRelation(item gosmparse.Relation) {
 	if err != nil {
 		log.Println(err)
 	}
+
+	// update tag index
+	if nil != s.Index {
+		if err := s.Index.IndexTags("relation", item.ID, item.Tags); err != nil {
+			log.Println(err)
+		}
+	}
 }
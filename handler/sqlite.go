@@ -3,6 +3,7 @@ package handler
 import (
 	"log"
 
+	"github.com/missinglink/pbf/lib"
 	"github.com/missinglink/pbf/sqlite"
 	"github.com/missinglink/pbf/tags"
 
@@ -35,6 +36,9 @@ func (s *Sqlite3) ReadNode(item gosmparse.Node) {
 			log.Println(err)
 		}
 	}
+
+	// commit periodically
+	s.Conn.Checkpoint()
 }
 
 // ReadWay - called once per way
@@ -66,6 +70,9 @@ func (s *Sqlite3) ReadWay(item gosmparse.Way) {
 			log.Println(err)
 		}
 	}
+
+	// commit periodically
+	s.Conn.Checkpoint()
 }
 
 // ReadRelation - called once per relation
@@ -90,11 +97,14 @@ func (s *Sqlite3) ReadRelation(item gosmparse.Relation) {
 		}
 	}
 
-	// relation, type, ref, role
-	for _, member := range item.Members {
-		_, err := s.Conn.Stmt.Member.Exec(item.ID, member.Type, member.ID, member.Role)
+	// relation, num, type, ref, role
+	for num, member := range item.Members {
+		_, err := s.Conn.Stmt.Member.Exec(item.ID, num, lib.MemberType(member.Type), member.ID, member.Role)
 		if err != nil {
 			log.Println(err)
 		}
 	}
+
+	// commit periodically
+	s.Conn.Checkpoint()
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/tags"
 
 	"github.com/missinglink/gosmparse"
 )
@@ -31,8 +32,13 @@ func (s *Streets) ReadWay(item gosmparse.Way) {
 		return
 	}
 
-	// remove all tags except for 'name' to conserve storage space
-	item.Tags = map[string]string{"name": item.Tags["name"], "oneway": item.Tags["oneway"]}
+	// remove all tags except for names, 'oneway' and 'highway' to conserve
+	// storage space
+	var kept = tags.Names(item.Tags)
+	kept["name"] = item.Tags["name"]
+	kept["oneway"] = item.Tags["oneway"]
+	kept["highway"] = item.Tags["highway"]
+	item.Tags = kept
 
 	// add way to database
 	s.DBHandler.ReadWay(item)
@@ -1,75 +1,171 @@
 package handler
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"math"
 	"strings"
 	"sync"
 
 	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
 	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/tags"
 )
 
-// Xroads - Xroads
+// the number of lock shards used when counting node references
+const xroadsShards = 64
+
+// key prefixes used in the xroads store
+const (
+	xroadsLegPrefix   = 'L' // node + way + neighbour node, one key per leg
+	xroadsWayPrefix   = 'W' // way -> names and highway
+	xroadsCoordPrefix = 'C' // node -> lat/lon
+)
+
+// Xroads - compute street intersections within a fixed memory budget.
+// node references are counted with a pair of bitmasks per shard, the legs
+// of each intersection and the metadata required to output them are
+// spilled to an ordered key/value store which is read back in node order.
 type Xroads struct {
-	Pass                 int64
-	TagWhiteList         map[string]bool
-	IntersectionWaysMask *lib.Bitmask
-	WayNames             map[int64]string
-	NodeMap              map[int64][]int64
-	Coords               map[int64]*gosmparse.Node
-	Mutex                *sync.Mutex
+	Pass          int64
+	TagWhiteList  map[string]bool
+	Features      *lib.FeatureSet // when set, used instead of TagWhiteList
+	NeighbourMask *lib.Bitmask    // nodes next to an intersection
+	Store         kv.Backend
+	shards        []*xroadsShard
+	errMutex      sync.Mutex
+	err           error // the first store error
+}
+
+// xroadsShard - the nodes seen once and seen more than once, a node is
+// always counted by the same shard so the test-and-set is atomic
+type xroadsShard struct {
+	mutex sync.Mutex
+	once  *lib.Bitmask
+	twice *lib.Bitmask
+}
+
+// Leg - a way leaving an intersection, towards the next node of the way
+type Leg struct {
+	WayID  int64
+	NodeID int64
+}
+
+// Junction - a node shared by two or more ways, with everything required
+// to output the intersection
+type Junction struct {
+	NodeID      int64
+	Coords      *gosmparse.Node
+	WayIDs      []int64 // ascending
+	Legs        []Leg
+	Nodes       map[int64]*gosmparse.Node // coords of the neighbouring nodes
+	Names       map[int64]map[string]string
+	Highways    map[int64]string
+	Roundabouts []int64 // the ways tagged junction=roundabout
+}
+
+// xroadsWay - the way metadata persisted to the store
+type xroadsWay struct {
+	Highway    string            `json:"highway"`
+	Names      map[string]string `json:"names,omitempty"`
+	Roundabout bool              `json:"roundabout,omitempty"`
+}
+
+// NewXroads - constructor
+func NewXroads(store kv.Backend) *Xroads {
+	var x = &Xroads{
+		NeighbourMask: lib.NewBitMask(),
+		Store:         store,
+		shards:        make([]*xroadsShard, xroadsShards),
+	}
+	for i := range x.shards {
+		x.shards[i] = &xroadsShard{once: lib.NewBitMask(), twice: lib.NewBitMask()}
+	}
+	return x
 }
 
 // ReadNode - called once per node
 func (x *Xroads) ReadNode(item gosmparse.Node) {
-	// skip unless this is the second pass over the file
-	if x.Pass != 1 {
+	// skip unless this is the final pass over the file
+	if x.Pass != 2 {
 		return
 	}
 
-	if _, ok := x.NodeMap[item.ID]; ok {
-		x.Mutex.Lock()
-		x.Coords[item.ID] = &gosmparse.Node{
-			Lat: item.Lat,
-			Lon: item.Lon,
+	if x.IsIntersection(item.ID) || x.NeighbourMask.Has(item.ID) {
+		var value = make([]byte, 16)
+		binary.BigEndian.PutUint64(value[0:8], math.Float64bits(item.Lat))
+		binary.BigEndian.PutUint64(value[8:16], math.Float64bits(item.Lon))
+		if err := x.Store.Put(xroadsKey(xroadsCoordPrefix, item.ID), value); nil != err {
+			x.fail(err)
 		}
-		x.Mutex.Unlock()
 	}
 }
 
 // ReadWay - called once per way
 func (x *Xroads) ReadWay(item gosmparse.Way) {
-	// compute intersections on first pass
-	if x.Pass == 0 {
-
-		// must be valid highway tag
-		if _, ok := x.TagWhiteList[item.Tags["highway"]]; !ok {
-			return
-		}
+	// must be a selected road
+	if (x.Pass != 0 && x.Pass != 1) || !x.selected(item) {
+		return
+	}
 
-		// store the way ids in an array with the nodeid as key
+	// count node references on first pass, a way which visits
+	// a node more than once is only counted once
+	if x.Pass == 0 {
+		var seen = make(map[int64]bool, len(item.NodeIDs))
 		for _, nodeid := range item.NodeIDs {
-			x.Mutex.Lock()
-			x.NodeMap[nodeid] = appendUnique(x.NodeMap[nodeid], item.ID)
-			x.Mutex.Unlock()
+			if !seen[nodeid] {
+				seen[nodeid] = true
+				x.count(nodeid)
+			}
 		}
+		return
 	}
 
-	// only store names on second pass to save memory
-	// (after $IntersectionWaysMask has been populated with matches)
-	if x.Pass == 1 && x.IntersectionWaysMask.Has(item.ID) {
-		// get the best name from the tags
-		if val, ok := item.Tags["addr:street"]; ok {
-			x.Mutex.Lock()
-			x.WayNames[item.ID] = strings.TrimSpace(val)
-			x.Mutex.Unlock()
-		} else if val, ok := item.Tags["name"]; ok {
-			x.Mutex.Lock()
-			x.WayNames[item.ID] = strings.TrimSpace(val)
-			x.Mutex.Unlock()
-		} else {
-			return
+	// spill the legs leaving each intersection along this way
+	// on the second pass, once all the intersections are known.
+	// note: ways which visit a node more than once may repeat a leg,
+	// the duplicate writes share a key so they are stored once.
+	var batch = &kv.Batch{}
+	var addLeg = func(nodeid, neighbour int64) {
+		batch.Put(xroadsLegKey(nodeid, item.ID, neighbour), nil)
+		x.NeighbourMask.Insert(neighbour)
+	}
+	for i, nodeid := range item.NodeIDs {
+		if !x.IsIntersection(nodeid) {
+			continue
+		}
+		if i > 0 {
+			addLeg(nodeid, item.NodeIDs[i-1])
+		}
+		if i < len(item.NodeIDs)-1 {
+			addLeg(nodeid, item.NodeIDs[i+1])
 		}
 	}
+	if batch.Len() == 0 {
+		return
+	}
+
+	// get the name variants from the tags, 'addr:street' is kept as a
+	// variant of its own as it is the best name when present
+	var way = xroadsWay{
+		Highway:    item.Tags["highway"],
+		Names:      tags.Names(item.Tags),
+		Roundabout: "roundabout" == item.Tags["junction"],
+	}
+	if val := strings.TrimSpace(item.Tags["addr:street"]); "" != val {
+		way.Names["addr:street"] = val
+	}
+	value, err := json.Marshal(way)
+	if nil != err {
+		x.fail(err)
+		return
+	}
+	batch.Put(xroadsKey(xroadsWayPrefix, item.ID), value)
+	if err := x.Store.Write(batch); nil != err {
+		x.fail(err)
+	}
 }
 
 // ReadRelation - called once per relation
@@ -77,28 +173,147 @@ func (x *Xroads) ReadRelation(item gosmparse.Relation) {
 	// noop
 }
 
-// TrimNonIntersections - remove any nodes which have less than two ways
-func (x *Xroads) TrimNonIntersections() {
-	x.Mutex.Lock()
-	for nodeID, wayIDs := range x.NodeMap {
-		if len(wayIDs) < 2 {
-			delete(x.NodeMap, nodeID)
-		} else {
-			// insert all intersection way IDs in mask
-			for _, wayID := range wayIDs {
-				x.IntersectionWaysMask.Insert(wayID)
-			}
+// Err - the first error encountered writing to the store, the
+// intersections are incomplete when this is not nil
+func (x *Xroads) Err() error {
+	x.errMutex.Lock()
+	defer x.errMutex.Unlock()
+	return x.err
+}
+
+// fail - log a store error and keep the first one
+func (x *Xroads) fail(err error) {
+	log.Println("failed to write intersections", err)
+	x.errMutex.Lock()
+	defer x.errMutex.Unlock()
+	if nil == x.err {
+		x.err = err
+	}
+}
+
+// IsIntersection - yes/no if the node is referenced by two or more ways
+func (x *Xroads) IsIntersection(nodeid int64) bool {
+	return x.shard(nodeid).twice.Has(nodeid)
+}
+
+// Junctions - visit every intersection in ascending node id order
+func (x *Xroads) Junctions(fn func(junction *Junction)) error {
+	var junction *Junction
+	var flush = func() {
+		if nil != junction {
+			x.load(junction)
+			fn(junction)
 		}
 	}
-	x.Mutex.Unlock()
+
+	var prefix = []byte{xroadsLegPrefix}
+	err := x.Store.Iterate(prefix, kv.PrefixLimit(prefix), func(key []byte, value []byte) bool {
+		var nodeid = int64(binary.BigEndian.Uint64(key[1:9]))
+		var leg = Leg{
+			WayID:  int64(binary.BigEndian.Uint64(key[9:17])),
+			NodeID: int64(binary.BigEndian.Uint64(key[17:25])),
+		}
+		if nil == junction || junction.NodeID != nodeid {
+			flush()
+			junction = &Junction{NodeID: nodeid}
+		}
+		if l := len(junction.WayIDs); l == 0 || junction.WayIDs[l-1] != leg.WayID {
+			junction.WayIDs = append(junction.WayIDs, leg.WayID)
+		}
+		junction.Legs = append(junction.Legs, leg)
+		return true
+	})
+	if nil != err {
+		return err
+	}
+	flush()
+	return nil
 }
 
-// https://stackoverflow.com/a/9561388
-func appendUnique(slice []int64, i int64) []int64 {
-	for _, ele := range slice {
-		if ele == i {
-			return slice
+// load - read the coords of the junction and its neighbours and the
+// metadata of its ways from the store
+func (x *Xroads) load(junction *Junction) {
+	junction.Coords = x.coords(junction.NodeID)
+	junction.Nodes = make(map[int64]*gosmparse.Node)
+	for _, leg := range junction.Legs {
+		if coords := x.coords(leg.NodeID); nil != coords {
+			junction.Nodes[leg.NodeID] = coords
+		}
+	}
+	junction.Names = make(map[int64]map[string]string)
+	junction.Highways = make(map[int64]string)
+	for _, wayid := range junction.WayIDs {
+		value, err := x.Store.Get(xroadsKey(xroadsWayPrefix, wayid))
+		if nil != err {
+			continue
+		}
+		var way xroadsWay
+		if nil == json.Unmarshal(value, &way) {
+			junction.Highways[wayid] = way.Highway
+			if way.Roundabout {
+				junction.Roundabouts = append(junction.Roundabouts, wayid)
+			}
+			if len(way.Names) > 0 {
+				junction.Names[wayid] = way.Names
+			}
 		}
 	}
-	return append(slice, i)
+}
+
+// coords - the stored location of a node, nil if it was not in the file
+func (x *Xroads) coords(nodeid int64) *gosmparse.Node {
+	value, err := x.Store.Get(xroadsKey(xroadsCoordPrefix, nodeid))
+	if nil != err || len(value) != 16 {
+		return nil
+	}
+	return &gosmparse.Node{
+		ID:  nodeid,
+		Lat: math.Float64frombits(binary.BigEndian.Uint64(value[0:8])),
+		Lon: math.Float64frombits(binary.BigEndian.Uint64(value[8:16])),
+	}
+}
+
+// selected - yes/no if the way is one of the selected roads
+func (x *Xroads) selected(item gosmparse.Way) bool {
+	if nil != x.Features {
+		return x.Features.MatchWay(item)
+	}
+	_, ok := x.TagWhiteList[item.Tags["highway"]]
+	return ok
+}
+
+// count - mark a node as seen once, or seen twice if it was already seen
+func (x *Xroads) count(nodeid int64) {
+	var shard = x.shard(nodeid)
+	shard.mutex.Lock()
+	if shard.once.Has(nodeid) {
+		shard.twice.Insert(nodeid)
+	} else {
+		shard.once.Insert(nodeid)
+	}
+	shard.mutex.Unlock()
+}
+
+// shard - the shard responsible for a node, consecutive ids share a
+// shard so that they share bitmask words
+func (x *Xroads) shard(nodeid int64) *xroadsShard {
+	return x.shards[(uint64(nodeid)/64)%xroadsShards]
+}
+
+// xroadsKey - a prefixed big endian id, keys sort in id order
+func xroadsKey(prefix byte, id int64) []byte {
+	var key = make([]byte, 9)
+	key[0] = prefix
+	binary.BigEndian.PutUint64(key[1:], uint64(id))
+	return key
+}
+
+// xroadsLegKey - a leg key, sorted by node, way then neighbour
+func xroadsLegKey(nodeid, wayid, neighbour int64) []byte {
+	var key = make([]byte, 25)
+	key[0] = xroadsLegPrefix
+	binary.BigEndian.PutUint64(key[1:9], uint64(nodeid))
+	binary.BigEndian.PutUint64(key[9:17], uint64(wayid))
+	binary.BigEndian.PutUint64(key[17:25], uint64(neighbour))
+	return key
 }
@@ -0,0 +1,75 @@
+package kv
+
+import "errors"
+
+// ErrNotFound is returned by Get when the key does not exist
+var ErrNotFound = errors.New("key not found")
+
+// Backend - an ordered key/value store
+type Backend interface {
+
+	// Get - read the value for key, returns ErrNotFound if missing
+	Get(key []byte) ([]byte, error)
+
+	// Put - write a single key/value pair
+	Put(key []byte, value []byte) error
+
+	// Write - apply all operations in the batch
+	Write(batch *Batch) error
+
+	// Iterate - visit keys in the range [start, limit) in ascending order,
+	// a nil start/limit is unbounded. iteration stops when fn returns false.
+	// note: key and value are only valid until fn returns and fn must not
+	// write to the backend.
+	Iterate(start []byte, limit []byte, fn func(key []byte, value []byte) bool) error
+
+	// Compact - reclaim space after large updates (may be a no-op)
+	Compact() error
+
+	// Close - flush and release resources
+	Close() error
+}
+
+// Batch - a set of writes to be applied together
+type Batch struct {
+	ops []op
+}
+
+type op struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// Put - add a write to the batch
+func (b *Batch) Put(key []byte, value []byte) {
+	b.ops = append(b.ops, op{key: key, value: value})
+}
+
+// Delete - add a deletion to the batch
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, op{key: key, delete: true})
+}
+
+// Len - number of operations in the batch
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Reset - empty the batch so it can be reused
+func (b *Batch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// PrefixLimit - the smallest key greater than all keys starting with prefix,
+// for use as the limit when iterating a prefix (nil if there is none)
+func PrefixLimit(prefix []byte) []byte {
+	var limit = append([]byte{}, prefix...)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
+}
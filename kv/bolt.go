@@ -0,0 +1,179 @@
+package kv
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// all records are stored in a single bucket
+var boltBucket = []byte("pbf")
+
+// the number of buffered puts written per transaction
+const boltPutBuffer = 10000
+
+// Bolt - pure-Go B+tree backend using a single file.
+// note: a transaction per Put is too slow for imports which write each
+// element separately, so puts are buffered and written together, reads
+// see the buffered values.
+type Bolt struct {
+	DB      *bolt.DB
+	mutex   sync.Mutex
+	pending map[string][]byte
+}
+
+// OpenBolt - open (or create) a bolt database file
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	// bulk imports don't need durability for every transaction
+	db.NoSync = true
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Bolt{DB: db, pending: make(map[string][]byte)}, nil
+}
+
+// Get - read value
+func (b *Bolt) Get(key []byte) ([]byte, error) {
+	b.mutex.Lock()
+	if data, ok := b.pending[string(key)]; ok {
+		b.mutex.Unlock()
+		return append([]byte{}, data...), nil
+	}
+	b.mutex.Unlock()
+
+	var value []byte
+	err := b.DB.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get(key)
+		if nil == data {
+			return ErrNotFound
+		}
+
+		// data is only valid for the life of the transaction
+		value = append([]byte{}, data...)
+		return nil
+	})
+	return value, err
+}
+
+// Put - buffer value, the buffer is written in a single transaction
+// once it is full
+func (b *Bolt) Put(key []byte, value []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending[string(key)] = append([]byte{}, value...)
+	if len(b.pending) < boltPutBuffer {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush - write the buffered puts in key order, the caller must hold the mutex
+func (b *Bolt) flush() error {
+	if 0 == len(b.pending) {
+		return nil
+	}
+	var keys = make([]string, 0, len(b.pending))
+	for key := range b.pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	err := b.DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, key := range keys {
+			if err := bucket.Put([]byte(key), b.pending[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	b.pending = make(map[string][]byte)
+	return err
+}
+
+// Write - apply batch in a single transaction, after the buffered puts
+func (b *Bolt) Write(batch *Batch) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err := b.flush(); err != nil {
+		return err
+	}
+	return b.DB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, op := range batch.ops {
+			var err error
+			if op.delete {
+				err = bucket.Delete(op.key)
+			} else {
+				err = bucket.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Iterate - visit range in key order, the buffered puts are written first
+func (b *Bolt) Iterate(start []byte, limit []byte, fn func(key []byte, value []byte) bool) error {
+	b.mutex.Lock()
+	err := b.flush()
+	b.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return b.DB.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltBucket).Cursor()
+
+		var k, v []byte
+		if nil == start {
+			k, v = cursor.First()
+		} else {
+			k, v = cursor.Seek(start)
+		}
+
+		for ; nil != k; k, v = cursor.Next() {
+			if nil != limit && string(k) >= string(limit) {
+				break
+			}
+			if !fn(k, v) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Compact - no-op, bolt reuses freed pages
+func (b *Bolt) Compact() error {
+	return nil
+}
+
+// Close - write the buffered puts, sync and close the file
+func (b *Bolt) Close() error {
+	b.mutex.Lock()
+	err := b.flush()
+	b.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := b.DB.Sync(); err != nil {
+		return err
+	}
+	return b.DB.Close()
+}
@@ -0,0 +1,147 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// run the same assertions against every backend
+func testBackend(t *testing.T, db Backend) {
+
+	_, err := db.Get([]byte("missing"))
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.Nil(t, db.Put([]byte("b1"), []byte("one")))
+	value, err := db.Get([]byte("b1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("one"), value)
+
+	// modifying a value which was read does not modify the stored value
+	value[0] = 'x'
+	value, err = db.Get([]byte("b1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("one"), value)
+
+	var batch = new(Batch)
+	batch.Put([]byte("a1"), []byte("x"))
+	batch.Put([]byte("b2"), []byte("two"))
+	batch.Put([]byte("b3"), []byte("three"))
+	batch.Put([]byte("c1"), []byte("y"))
+	batch.Delete([]byte("b3"))
+	assert.Equal(t, 5, batch.Len())
+	assert.Nil(t, db.Write(batch))
+
+	_, err = db.Get([]byte("b3"))
+	assert.Equal(t, ErrNotFound, err)
+
+	// iterate prefix
+	var keys []string
+	assert.Nil(t, db.Iterate([]byte("b"), PrefixLimit([]byte("b")), func(k []byte, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	}))
+	assert.Equal(t, []string{"b1", "b2"}, keys)
+
+	// iterate everything, stopping early
+	keys = nil
+	assert.Nil(t, db.Iterate(nil, nil, func(k []byte, v []byte) bool {
+		keys = append(keys, string(k))
+		return len(keys) < 3
+	}))
+	assert.Equal(t, []string{"a1", "b1", "b2"}, keys)
+
+	assert.Nil(t, db.Compact())
+	assert.Nil(t, db.Close())
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestLevelDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenLevelDB(dir, nil)
+	assert.Nil(t, err)
+	testBackend(t, db)
+}
+
+func TestBolt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenBolt(filepath.Join(dir, "test.bolt"))
+	assert.Nil(t, err)
+	testBackend(t, db)
+}
+
+func TestBoltBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "test.bolt")
+	db, err := OpenBolt(path)
+	assert.Nil(t, err)
+
+	// more puts than fit in the buffer
+	for i := 0; i < boltPutBuffer+10; i++ {
+		assert.Nil(t, db.Put([]byte(fmt.Sprintf("k%06d", i)), []byte("v")))
+	}
+	assert.Len(t, db.pending, 10)
+
+	// buffered puts can be read and iterated
+	value, err := db.Get([]byte("k010009"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v"), value)
+	var count int
+	assert.Nil(t, db.Iterate(nil, nil, func(k []byte, v []byte) bool {
+		count++
+		return true
+	}))
+	assert.Equal(t, boltPutBuffer+10, count)
+
+	// buffered puts are written on close
+	assert.Nil(t, db.Put([]byte("last"), []byte("v")))
+	assert.Nil(t, db.Close())
+	db, err = OpenBolt(path)
+	assert.Nil(t, err)
+	_, err = db.Get([]byte("last"))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+}
+
+// benchmark one put per element, as written by the leveldb import
+func BenchmarkBoltPut(b *testing.B) {
+	dir, err := ioutil.TempDir("", "kv")
+	assert.Nil(b, err)
+	defer os.RemoveAll(dir)
+
+	db, err := OpenBolt(filepath.Join(dir, "bench.bolt"))
+	assert.Nil(b, err)
+	defer db.Close()
+
+	var key = make([]byte, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.BigEndian.PutUint64(key, uint64(i))
+		if err := db.Put(key, key); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPrefixLimit(t *testing.T) {
+	assert.Equal(t, []byte("b"), PrefixLimit([]byte("a")))
+	assert.Equal(t, []byte{'b'}, PrefixLimit([]byte{'a', 0xff}))
+	assert.Nil(t, PrefixLimit([]byte{0xff, 0xff}))
+}
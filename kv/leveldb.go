@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// DefaultLevelDBOptions - tuned for bulk imports of OSM data
+var DefaultLevelDBOptions = &opt.Options{
+	Compression:        opt.NoCompression,
+	WriteBuffer:        120 * opt.MiB,
+	BlockCacheCapacity: 120 * opt.MiB,
+}
+
+// LevelDB - backend using goleveldb
+type LevelDB struct {
+	DB *leveldb.DB
+}
+
+// OpenLevelDB - open (or create) a leveldb database directory
+// note: uses DefaultLevelDBOptions when options is nil
+func OpenLevelDB(path string, options *opt.Options) (*LevelDB, error) {
+	if nil == options {
+		options = DefaultLevelDBOptions
+	}
+	db, err := leveldb.OpenFile(path, options)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDB{DB: db}, nil
+}
+
+// Get - read value
+func (l *LevelDB) Get(key []byte) ([]byte, error) {
+	data, err := l.DB.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put - write value
+func (l *LevelDB) Put(key []byte, value []byte) error {
+	return l.DB.Put(key, value, nil)
+}
+
+// Write - apply batch
+func (l *LevelDB) Write(batch *Batch) error {
+	var b = new(leveldb.Batch)
+	for _, op := range batch.ops {
+		if op.delete {
+			b.Delete(op.key)
+		} else {
+			b.Put(op.key, op.value)
+		}
+	}
+	return l.DB.Write(b, nil)
+}
+
+// Iterate - visit range in key order
+// note: leveldb iterators read from an implicit snapshot
+func (l *LevelDB) Iterate(start []byte, limit []byte, fn func(key []byte, value []byte) bool) error {
+	iter := l.DB.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+// Compact - compact the whole keyspace
+func (l *LevelDB) Compact() error {
+	return l.DB.CompactRange(util.Range{})
+}
+
+// Close - close database
+func (l *LevelDB) Close() error {
+	return l.DB.Close()
+}
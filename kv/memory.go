@@ -0,0 +1,99 @@
+package kv
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+// Memory - backend using a map, for tests and small extracts
+type Memory struct {
+	mutex sync.RWMutex
+	data  map[string][]byte
+}
+
+// NewMemory - constructor
+func NewMemory() *Memory {
+	return &Memory{data: make(map[string][]byte)}
+}
+
+// Get - read value
+func (m *Memory) Get(key []byte) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	value, ok := m.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// callers may modify the value, the stored value must not change
+	return append([]byte{}, value...), nil
+}
+
+// Put - write value
+func (m *Memory) Put(key []byte, value []byte) error {
+	m.mutex.Lock()
+	m.data[string(key)] = append([]byte{}, value...)
+	m.mutex.Unlock()
+	return nil
+}
+
+// Write - apply batch
+func (m *Memory) Write(batch *Batch) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, op := range batch.ops {
+		if op.delete {
+			delete(m.data, string(op.key))
+		} else {
+			m.data[string(op.key)] = append([]byte{}, op.value...)
+		}
+	}
+	return nil
+}
+
+// Iterate - visit range in key order
+// note: the matching keys are sorted on each call so this is only
+// suitable for infrequent iteration.
+func (m *Memory) Iterate(start []byte, limit []byte, fn func(key []byte, value []byte) bool) error {
+	m.mutex.RLock()
+	var keys = make([]string, 0, len(m.data))
+	for k := range m.data {
+		if nil != start && bytes.Compare([]byte(k), start) < 0 {
+			continue
+		}
+		if nil != limit && bytes.Compare([]byte(k), limit) >= 0 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	m.mutex.RUnlock()
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		m.mutex.RLock()
+		value, ok := m.data[k]
+		m.mutex.RUnlock()
+		if !ok {
+			continue
+		}
+		if !fn([]byte(k), value) {
+			break
+		}
+	}
+	return nil
+}
+
+// Compact - no-op
+func (m *Memory) Compact() error {
+	return nil
+}
+
+// Close - release memory
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	m.data = make(map[string][]byte)
+	m.mutex.Unlock()
+	return nil
+}
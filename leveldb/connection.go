@@ -1,8 +1,9 @@
 package leveldb
 
 import (
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/opt"
+	"log"
+
+	"github.com/missinglink/pbf/kv"
 )
 
 // key prefixes for each element type
@@ -11,28 +12,41 @@ var prefix = func() map[string][]byte {
 		"node":     []byte{'N'},
 		"way":      []byte{'W'},
 		"relation": []byte{'R'},
+		"coord":    []byte{'C'},
+		"meta":     []byte{'M'},
+		"index":    []byte{'I'},
 	}
 }()
 
 // Connection - Connection
+// note: despite the package name any kv.Backend may be used for storage
 type Connection struct {
-	DB *leveldb.DB
+	DB      kv.Backend
+	Version int
+
+	// tag keys to maintain secondary indexes for
+	indexKeys []string
 }
 
 // Open - open connection and set up
 func (c *Connection) Open(path string) {
-	db, err := leveldb.OpenFile(path, &opt.Options{
-		Compression:        opt.NoCompression,
-		WriteBuffer:        120 * opt.MiB,
-		BlockCacheCapacity: 120 * opt.MiB,
-	})
+	db, err := kv.OpenLevelDB(path, nil)
 	if err != nil {
 		panic(err)
 	}
+	c.OpenBackend(db)
+}
+
+// OpenBackend - set up connection using an already opened backend
+func (c *Connection) OpenBackend(db kv.Backend) {
 	c.DB = db
+	c.loadVersion()
+	c.indexKeys = c.IndexKeys()
 }
 
 // Close - close connection and clean up
 func (c *Connection) Close() {
-	c.DB.Close()
+	if err := c.DB.Close(); err != nil {
+		log.Println(err)
+	}
 }
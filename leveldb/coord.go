@@ -1,9 +1,6 @@
 package leveldb
 
 import (
-	"encoding/binary"
-	"math"
-
 	"github.com/missinglink/gosmparse"
 )
 
@@ -11,22 +8,13 @@ import (
 func (c *Connection) WriteCoord(item gosmparse.Node) error {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(item.ID))
-
-	// encode lat
-	lat := make([]byte, 8)
-	binary.BigEndian.PutUint64(lat, math.Float64bits(item.Lat))
+	key := c.key("coord", item.ID)
 
-	// encode lon
-	lon := make([]byte, 8)
-	binary.BigEndian.PutUint64(lon, math.Float64bits(item.Lon))
-
-	// value
-	value := append(lat, lon...)
+	// encode lat/lon
+	value := c.encodeCoordValue(&item)
 
 	// write to db
-	err := c.DB.Put(key, value, nil)
+	err := c.DB.Put(key, value)
 	if err != nil {
 		return err
 	}
@@ -38,19 +26,23 @@ func (c *Connection) WriteCoord(item gosmparse.Node) error {
 func (c *Connection) ReadCoord(id int64) (*gosmparse.Node, error) {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(id))
+	key := c.key("coord", id)
 
 	// read from db
-	data, err := c.DB.Get(key, nil)
+	data, err := c.DB.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
 	// decode item
+	lat, lon, err := c.decodeCoordValue(data)
+	if err != nil {
+		return nil, err
+	}
+
 	return &gosmparse.Node{
 		ID:  id,
-		Lat: math.Float64frombits(binary.BigEndian.Uint64(data[:8])),
-		Lon: math.Float64frombits(binary.BigEndian.Uint64(data[8:])),
+		Lat: lat,
+		Lon: lon,
 	}, nil
 }
@@ -0,0 +1,228 @@
+package leveldb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// coordinates are stored as int32 fixed-point values in units of
+// 100 nanodegrees (1e-7°), which is the native precision of OSM data.
+const coordPrecision = 1e7
+
+// errCorrupt is returned when a value cannot be decoded
+var errCorrupt = errors.New("corrupt value")
+
+// encodeCoord - encode lat/lon as two big-endian int32 values (8 bytes)
+func encodeCoord(lat float64, lon float64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint32(value[:4], uint32(toFixed(lat)))
+	binary.BigEndian.PutUint32(value[4:], uint32(toFixed(lon)))
+	return value
+}
+
+// decodeCoord - decode lat/lon from an 8 byte value
+func decodeCoord(data []byte) (float64, float64, error) {
+	if len(data) < 8 {
+		return 0, 0, errCorrupt
+	}
+	lat := fromFixed(int32(binary.BigEndian.Uint32(data[:4])))
+	lon := fromFixed(int32(binary.BigEndian.Uint32(data[4:8])))
+	return lat, lon, nil
+}
+
+// toFixed - convert degrees to fixed-point
+func toFixed(deg float64) int32 {
+	return int32(math.Round(deg * coordPrecision))
+}
+
+// fromFixed - convert fixed-point to degrees
+func fromFixed(val int32) float64 {
+	return float64(val) / coordPrecision
+}
+
+// encodeNode - coord followed by tags
+func encodeNode(item gosmparse.Node) []byte {
+	buf := encodeCoord(item.Lat, item.Lon)
+	return appendTags(buf, item.Tags)
+}
+
+// decodeNode - inverse of encodeNode
+func decodeNode(id int64, data []byte) (*gosmparse.Node, error) {
+	lat, lon, err := decodeCoord(data)
+	if err != nil {
+		return nil, err
+	}
+	tags, _, err := readTags(data[8:])
+	if err != nil {
+		return nil, err
+	}
+	return &gosmparse.Node{ID: id, Lat: lat, Lon: lon, Tags: tags}, nil
+}
+
+// encodeWay - delta-encoded refs followed by tags
+func encodeWay(item gosmparse.Way) []byte {
+	buf := make([]byte, 0, 2+len(item.NodeIDs)*3)
+	buf = appendUvarint(buf, uint64(len(item.NodeIDs)))
+	var prev int64
+	for _, ref := range item.NodeIDs {
+		buf = appendVarint(buf, ref-prev)
+		prev = ref
+	}
+	return appendTags(buf, item.Tags)
+}
+
+// decodeWay - inverse of encodeWay
+func decodeWay(id int64, data []byte) (*gosmparse.Way, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, errCorrupt
+	}
+	data = data[n:]
+
+	refs := make([]int64, 0, count)
+	var prev int64
+	for i := uint64(0); i < count; i++ {
+		delta, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errCorrupt
+		}
+		data = data[n:]
+		prev += delta
+		refs = append(refs, prev)
+	}
+
+	tags, _, err := readTags(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gosmparse.Way{ID: id, NodeIDs: refs, Tags: tags}, nil
+}
+
+// encodeRelation - members (type, delta-encoded id, role) followed by tags
+func encodeRelation(item gosmparse.Relation) []byte {
+	buf := make([]byte, 0, 2+len(item.Members)*8)
+	buf = appendUvarint(buf, uint64(len(item.Members)))
+	var prev int64
+	for _, member := range item.Members {
+		buf = append(buf, byte(member.Type))
+		buf = appendVarint(buf, member.ID-prev)
+		buf = appendString(buf, member.Role)
+		prev = member.ID
+	}
+	return appendTags(buf, item.Tags)
+}
+
+// decodeRelation - inverse of encodeRelation
+func decodeRelation(id int64, data []byte) (*gosmparse.Relation, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, errCorrupt
+	}
+	data = data[n:]
+
+	members := make([]gosmparse.RelationMember, 0, count)
+	var prev int64
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 1 {
+			return nil, errCorrupt
+		}
+		typ := gosmparse.MemberType(data[0])
+		data = data[1:]
+
+		delta, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errCorrupt
+		}
+		data = data[n:]
+		prev += delta
+
+		role, rest, err := readString(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		members = append(members, gosmparse.RelationMember{ID: prev, Type: typ, Role: role})
+	}
+
+	tags, _, err := readTags(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gosmparse.Relation{ID: id, Members: members, Tags: tags}, nil
+}
+
+// appendTags - tag count followed by key/value strings, sorted by key
+// so that identical tag sets always produce identical bytes
+func appendTags(buf []byte, tags map[string]string) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = appendString(buf, tags[k])
+	}
+	return buf
+}
+
+// readTags - inverse of appendTags
+func readTags(data []byte) (map[string]string, []byte, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, nil, errCorrupt
+	}
+	data = data[n:]
+
+	tags := make(map[string]string, count)
+	for i := uint64(0); i < count; i++ {
+		key, rest, err := readString(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		val, rest, err := readString(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		tags[key] = val
+		data = rest
+	}
+	return tags, data, nil
+}
+
+// appendString - length prefixed string
+func appendString(buf []byte, str string) []byte {
+	buf = appendUvarint(buf, uint64(len(str)))
+	return append(buf, str...)
+}
+
+// readString - inverse of appendString
+func readString(data []byte) (string, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < l {
+		return "", nil, errCorrupt
+	}
+	end := n + int(l)
+	return string(data[n:end]), data[end:], nil
+}
+
+func appendUvarint(buf []byte, val uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], val)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, val int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], val)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,46 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeCoord(t *testing.T) {
+	lat, lon, err := decodeCoord(encodeCoord(-33.8688197, 151.2092955))
+	assert.Nil(t, err)
+	assert.Equal(t, -33.8688197, lat)
+	assert.Equal(t, 151.2092955, lon)
+
+	_, _, err = decodeCoord([]byte{0x1})
+	assert.Equal(t, errCorrupt, err)
+}
+
+func TestEncodeNode(t *testing.T) {
+	var node = gosmparse.Node{ID: 1, Lat: 1.5, Lon: -2.5, Tags: map[string]string{"a": "b"}}
+	decoded, err := decodeNode(1, encodeNode(node))
+	assert.Nil(t, err)
+	assert.Equal(t, &node, decoded)
+}
+
+func TestEncodeWay(t *testing.T) {
+	var way = gosmparse.Way{ID: 2, NodeIDs: []int64{100, 99, 5000000000, 1}, Tags: map[string]string{"highway": "primary", "name": "Lê Lợi"}}
+	decoded, err := decodeWay(2, encodeWay(way))
+	assert.Nil(t, err)
+	assert.Equal(t, &way, decoded)
+
+	_, err = decodeWay(2, []byte{0x5, 0x2})
+	assert.Equal(t, errCorrupt, err)
+}
+
+func TestEncodeRelation(t *testing.T) {
+	var relation = gosmparse.Relation{ID: 3, Members: []gosmparse.RelationMember{
+		{ID: 10, Type: gosmparse.WayType, Role: "outer"},
+		{ID: 2, Type: gosmparse.NodeType, Role: "admin_centre"},
+		{ID: 7, Type: gosmparse.RelationType, Role: ""},
+	}, Tags: map[string]string{"type": "boundary"}}
+	decoded, err := decodeRelation(3, encodeRelation(relation))
+	assert.Nil(t, err)
+	assert.Equal(t, &relation, decoded)
+}
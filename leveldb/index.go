@@ -0,0 +1,197 @@
+package leveldb
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+)
+
+// key under which the list of indexed tag keys is stored
+var indexKeysKey = append(append([]byte{}, prefix["meta"]...), "index_keys"...)
+
+// element type byte used in index keys
+var indexType = map[string]byte{
+	"node":     byte(gosmparse.NodeType),
+	"way":      byte(gosmparse.WayType),
+	"relation": byte(gosmparse.RelationType),
+}
+
+// IndexKeys - the tag keys which are indexed in this db
+func (c *Connection) IndexKeys() []string {
+	data, err := c.DB.Get(indexKeysKey)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), ",")
+}
+
+// AddIndexKeys - start indexing additional tag keys
+// note: elements already in the db are indexed for the new keys before the
+// keys are recorded, so a recorded key is always fully indexed.
+func (c *Connection) AddIndexKeys(keys []string) error {
+	var existing = c.IndexKeys()
+	var set = make(map[string]bool)
+	for _, k := range existing {
+		set[k] = true
+	}
+
+	var added []string
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); "" != k && !set[k] {
+			set[k] = true
+			added = append(added, k)
+		}
+	}
+
+	var all = make([]string, 0, len(set))
+	for k := range set {
+		all = append(all, k)
+	}
+	sort.Strings(all)
+
+	if err := c.backfillIndex(added); err != nil {
+		return err
+	}
+
+	c.indexKeys = all
+	return c.DB.Put(indexKeysKey, []byte(strings.Join(all, ",")))
+}
+
+// backfillIndex - write index entries for keys to the elements already
+// in the db, visited in chunks as the db may not be written while iterating
+func (c *Connection) backfillIndex(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var batch = new(kv.Batch)
+	for _, typ := range []string{"node", "way", "relation"} {
+		var opts = &IterateOptions{}
+		for {
+			var failed error
+			var last int64
+			var full bool
+			var visit = func(id int64, tags map[string]string, err error) bool {
+				if err != nil {
+					failed = err
+					return false
+				}
+				last = id
+				indexEntries(batch, keys, typ, id, tags)
+				full = batch.Len() >= batchSize
+				return !full
+			}
+
+			switch typ {
+			case "node":
+				c.IterateNodes(opts, func(node *gosmparse.Node, err error) bool {
+					if nil == node {
+						return visit(0, nil, err)
+					}
+					return visit(node.ID, node.Tags, err)
+				})
+			case "way":
+				c.IterateWays(opts, func(way *gosmparse.Way, err error) bool {
+					if nil == way {
+						return visit(0, nil, err)
+					}
+					return visit(way.ID, way.Tags, err)
+				})
+			case "relation":
+				c.IterateRelations(opts, func(relation *gosmparse.Relation, err error) bool {
+					if nil == relation {
+						return visit(0, nil, err)
+					}
+					return visit(relation.ID, relation.Tags, err)
+				})
+			}
+			if failed != nil {
+				return failed
+			}
+
+			if err := c.DB.Write(batch); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			if !full {
+				break
+			}
+			opts.From = last + 1
+		}
+	}
+	return nil
+}
+
+// IndexTags - write index entries for each indexed key present in tags
+func (c *Connection) IndexTags(typ string, id int64, tags map[string]string) error {
+	if len(c.indexKeys) == 0 {
+		return nil
+	}
+
+	var batch = new(kv.Batch)
+	indexEntries(batch, c.indexKeys, typ, id, tags)
+	if batch.Len() == 0 {
+		return nil
+	}
+	return c.DB.Write(batch)
+}
+
+// QueryIndex - ids of elements of type typ with tag key (and value, when not nil)
+// note: returns false if key is not indexed
+func (c *Connection) QueryIndex(typ string, key string, value *string) ([]int64, bool) {
+	var indexed = false
+	for _, k := range c.indexKeys {
+		if k == key {
+			indexed = true
+		}
+	}
+	if !indexed {
+		return nil, false
+	}
+
+	var start = indexKey(key, value, "", 0)
+	var ids []int64
+	c.DB.Iterate(start, kv.PrefixLimit(start), func(k []byte, v []byte) bool {
+		if len(k) < 9 || k[len(k)-9] != indexType[typ] {
+			return true
+		}
+		ids = append(ids, int64(binary.BigEndian.Uint64(k[len(k)-8:])))
+		return true
+	})
+
+	// values are visited in order so ids may be out of order
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, true
+}
+
+// indexEntries - add an index entry to batch for each of keys present in tags
+func indexEntries(batch *kv.Batch, keys []string, typ string, id int64, tags map[string]string) {
+	for _, k := range keys {
+		if v, ok := tags[k]; ok {
+			batch.Put(indexKey(k, &v, typ, id), nil)
+		}
+	}
+}
+
+// indexKey - 'I' key 0x00 value 0x00 type id
+// when typ is empty only the key (and value, when not nil) prefix is returned
+func indexKey(key string, value *string, typ string, id int64) []byte {
+	var buf = append(append([]byte{}, prefix["index"]...), key...)
+	buf = append(buf, 0x00)
+	if nil == value {
+		return buf
+	}
+	buf = append(append(buf, *value...), 0x00)
+	if "" == typ {
+		return buf
+	}
+	buf = append(buf, indexType[typ])
+	var b = make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return append(buf, b...)
+}
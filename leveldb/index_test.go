@@ -0,0 +1,69 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	var conn = &Connection{}
+	conn.OpenBackend(kv.NewMemory())
+	assert.Nil(t, conn.AddIndexKeys([]string{"boundary", " admin_level"}))
+	assert.Equal(t, []string{"admin_level", "boundary"}, conn.IndexKeys())
+
+	conn.IndexTags("relation", 3, map[string]string{"boundary": "administrative", "admin_level": "4"})
+	conn.IndexTags("relation", 1, map[string]string{"boundary": "administrative", "admin_level": "40"})
+	conn.IndexTags("way", 2, map[string]string{"boundary": "administrative", "name": "x"})
+
+	var four = "4"
+	ids, ok := conn.QueryIndex("relation", "admin_level", &four)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{3}, ids)
+
+	ids, ok = conn.QueryIndex("relation", "boundary", nil)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{1, 3}, ids)
+
+	ids, ok = conn.QueryIndex("way", "boundary", nil)
+	assert.True(t, ok)
+	assert.Equal(t, []int64{2}, ids)
+
+	_, ok = conn.QueryIndex("way", "name", nil)
+	assert.False(t, ok)
+}
+
+func TestIndexBackfill(t *testing.T) {
+	var conn = &Connection{}
+	conn.OpenBackend(kv.NewMemory())
+	assert.Nil(t, conn.AddIndexKeys([]string{"boundary"}))
+
+	// elements written before the key is indexed
+	for id := int64(1); id <= 10; id++ {
+		var tags = map[string]string{"boundary": "administrative", "admin_level": "4"}
+		assert.Nil(t, conn.WriteNode(gosmparse.Node{ID: id, Tags: tags}))
+		assert.Nil(t, conn.WriteWay(gosmparse.Way{ID: id, Tags: tags}))
+		assert.Nil(t, conn.WriteRelation(gosmparse.Relation{ID: id, Tags: tags}))
+	}
+	assert.Nil(t, conn.WriteWay(gosmparse.Way{ID: 11, Tags: map[string]string{"admin_level": "2"}}))
+
+	// force several chunks
+	defer func(size int) { batchSize = size }(batchSize)
+	batchSize = 3
+
+	assert.Nil(t, conn.AddIndexKeys([]string{"admin_level"}))
+	assert.Equal(t, []string{"admin_level", "boundary"}, conn.IndexKeys())
+
+	var four = "4"
+	for _, typ := range []string{"node", "way", "relation"} {
+		ids, ok := conn.QueryIndex(typ, "admin_level", &four)
+		assert.True(t, ok)
+		assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, ids)
+	}
+
+	var two = "2"
+	ids, _ := conn.QueryIndex("way", "admin_level", &two)
+	assert.Equal(t, []int64{11}, ids)
+}
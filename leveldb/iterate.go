@@ -0,0 +1,104 @@
+package leveldb
+
+import (
+	"encoding/binary"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+)
+
+// IterateOptions - bounds and filtering applied when iterating elements
+type IterateOptions struct {
+	From   int64                        // first id (inclusive)
+	To     int64                        // last id (inclusive), 0 for no upper bound
+	Filter func(map[string]string) bool // optional, skip elements whose tags are rejected (ignored for coords)
+}
+
+// accept - yes/no if the tags pass the filter
+func (o *IterateOptions) accept(tags map[string]string) bool {
+	return nil == o || nil == o.Filter || o.Filter(tags)
+}
+
+// IterateNodes - read nodes from db in id order, return false from cb to stop
+func (c *Connection) IterateNodes(opts *IterateOptions, cb func(*gosmparse.Node, error) bool) {
+	c.iterate("node", opts, func(id int64, data []byte) bool {
+		node, err := c.decodeNode(id, data)
+		if err != nil {
+			return cb(nil, err)
+		}
+		if !opts.accept(node.Tags) {
+			return true
+		}
+		return cb(node, nil)
+	}, func(err error) { cb(nil, err) })
+}
+
+// IterateWays - read ways from db in id order, return false from cb to stop
+func (c *Connection) IterateWays(opts *IterateOptions, cb func(*gosmparse.Way, error) bool) {
+	c.iterate("way", opts, func(id int64, data []byte) bool {
+		way, err := c.decodeWay(id, data)
+		if err != nil {
+			return cb(nil, err)
+		}
+		if !opts.accept(way.Tags) {
+			return true
+		}
+		return cb(way, nil)
+	}, func(err error) { cb(nil, err) })
+}
+
+// IterateRelations - read relations from db in id order, return false from cb to stop
+func (c *Connection) IterateRelations(opts *IterateOptions, cb func(*gosmparse.Relation, error) bool) {
+	c.iterate("relation", opts, func(id int64, data []byte) bool {
+		relation, err := c.decodeRelation(id, data)
+		if err != nil {
+			return cb(nil, err)
+		}
+		if !opts.accept(relation.Tags) {
+			return true
+		}
+		return cb(relation, nil)
+	}, func(err error) { cb(nil, err) })
+}
+
+// IterateCoords - read node locations from db in id order, return false from cb to stop
+func (c *Connection) IterateCoords(opts *IterateOptions, cb func(*gosmparse.Node, error) bool) {
+	c.iterate("coord", opts, func(id int64, data []byte) bool {
+		lat, lon, err := c.decodeCoordValue(data)
+		if err != nil {
+			return cb(nil, err)
+		}
+		return cb(&gosmparse.Node{ID: id, Lat: lat, Lon: lon}, nil)
+	}, func(err error) { cb(nil, err) })
+}
+
+// iterate - visit all raw values of type typ within the id range
+func (c *Connection) iterate(typ string, opts *IterateOptions, fn func(int64, []byte) bool, onError func(error)) {
+
+	var from, to int64
+	if nil != opts {
+		from, to = opts.From, opts.To
+	}
+
+	// compute key range
+	var start = c.key(typ, from)
+	var limit []byte
+	if to > 0 && to < 1<<63-1 {
+		limit = c.key(typ, to+1)
+	} else if len(start) > 8 {
+		limit = kv.PrefixLimit(start[:len(start)-8])
+	}
+
+	// v1 coords are unprefixed so their keys must be distinguished by length
+	var prefixLen = len(start) - 8
+
+	err := c.DB.Iterate(start, limit, func(key []byte, data []byte) bool {
+		if len(key) != prefixLen+8 {
+			return true
+		}
+		return fn(int64(binary.BigEndian.Uint64(key[prefixLen:])), data)
+	})
+	if err != nil {
+		onError(err)
+	}
+}
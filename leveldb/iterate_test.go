@@ -0,0 +1,71 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConnection(version int) *Connection {
+	var conn = &Connection{}
+	conn.OpenBackend(kv.NewMemory())
+	conn.Version = version
+	for id := int64(1); id <= 10; id++ {
+		var tags = map[string]string{}
+		if id%2 == 0 {
+			tags["even"] = "yes"
+		}
+		conn.WriteNode(gosmparse.Node{ID: id, Lat: 1, Lon: 2, Tags: tags})
+		conn.WriteWay(gosmparse.Way{ID: id, NodeIDs: []int64{id}, Tags: tags})
+		conn.WriteCoord(gosmparse.Node{ID: id, Lat: float64(id), Lon: 2})
+	}
+	return conn
+}
+
+func TestIterateRange(t *testing.T) {
+	var conn = newTestConnection(SchemaVersion)
+
+	var ids []int64
+	conn.IterateNodes(&IterateOptions{From: 3, To: 6}, func(node *gosmparse.Node, err error) bool {
+		assert.Nil(t, err)
+		ids = append(ids, node.ID)
+		return true
+	})
+	assert.Equal(t, []int64{3, 4, 5, 6}, ids)
+
+	// relations share the keyspace but none were written
+	conn.IterateRelations(nil, func(relation *gosmparse.Relation, err error) bool {
+		t.Fail()
+		return true
+	})
+}
+
+func TestIterateFilter(t *testing.T) {
+	var conn = newTestConnection(SchemaVersion)
+
+	var ids []int64
+	var even = func(tags map[string]string) bool { return tags["even"] == "yes" }
+	conn.IterateWays(&IterateOptions{Filter: even}, func(way *gosmparse.Way, err error) bool {
+		assert.Nil(t, err)
+		ids = append(ids, way.ID)
+		return len(ids) < 3
+	})
+	assert.Equal(t, []int64{2, 4, 6}, ids)
+}
+
+func TestIterateCoords(t *testing.T) {
+	for _, version := range []int{1, SchemaVersion} {
+		var conn = newTestConnection(version)
+
+		var ids []int64
+		conn.IterateCoords(&IterateOptions{From: 8}, func(node *gosmparse.Node, err error) bool {
+			assert.Nil(t, err)
+			assert.Equal(t, float64(node.ID), node.Lat)
+			ids = append(ids, node.ID)
+			return true
+		})
+		assert.Equal(t, []int64{8, 9, 10}, ids)
+	}
+}
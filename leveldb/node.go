@@ -1,7 +1,6 @@
 package leveldb
 
 import (
-	"encoding/binary"
 	"log"
 
 	"github.com/missinglink/gosmparse"
@@ -12,21 +11,23 @@ import (
 func (c *Connection) WriteNode(item gosmparse.Node) error {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(item.ID))
-
-	// prepend node identifier
-	key = append(prefix["node"], key...)
+	key := c.key("node", item.ID)
 
 	// encode item
-	value, err := msgpack.Marshal(item)
-	if err != nil {
-		log.Println("encode failed", err)
-		return err
+	var value []byte
+	if c.Version < 2 {
+		var err error
+		value, err = msgpack.Marshal(item)
+		if err != nil {
+			log.Println("encode failed", err)
+			return err
+		}
+	} else {
+		value = encodeNode(item)
 	}
 
 	// write to db
-	err = c.DB.Put(key, value, nil)
+	err := c.DB.Put(key, value)
 	if err != nil {
 		return err
 	}
@@ -38,25 +39,34 @@ func (c *Connection) WriteNode(item gosmparse.Node) error {
 func (c *Connection) ReadNode(id int64) (*gosmparse.Node, error) {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(id))
-
-	// prepend node identifier
-	key = append(prefix["node"], key...)
+	key := c.key("node", id)
 
 	// read from db
-	data, err := c.DB.Get(key, nil)
+	data, err := c.DB.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
 	// decode item
-	var node gosmparse.Node
-	err = msgpack.Unmarshal(data, &node)
+	return c.decodeNode(id, data)
+}
+
+// decodeNode - decode value using the schema of this db
+func (c *Connection) decodeNode(id int64, data []byte) (*gosmparse.Node, error) {
+	if c.Version < 2 {
+		var node gosmparse.Node
+		err := msgpack.Unmarshal(data, &node)
+		if err != nil {
+			log.Println("decode failed", err)
+			return nil, err
+		}
+		return &node, nil
+	}
+
+	node, err := decodeNode(id, data)
 	if err != nil {
 		log.Println("decode failed", err)
 		return nil, err
 	}
-
-	return &node, nil
+	return node, nil
 }
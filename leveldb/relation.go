@@ -1,11 +1,9 @@
 package leveldb
 
 import (
-	"encoding/binary"
 	"log"
 
 	"github.com/missinglink/gosmparse"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/vmihailenco/msgpack"
 )
 
@@ -13,21 +11,23 @@ import (
 func (c *Connection) WriteRelation(item gosmparse.Relation) error {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(item.ID))
-
-	// prepend relation identifier
-	key = append(prefix["relation"], key...)
+	key := c.key("relation", item.ID)
 
 	// encode item
-	value, err := msgpack.Marshal(item)
-	if err != nil {
-		log.Println("encode failed", err)
-		return err
+	var value []byte
+	if c.Version < 2 {
+		var err error
+		value, err = msgpack.Marshal(item)
+		if err != nil {
+			log.Println("encode failed", err)
+			return err
+		}
+	} else {
+		value = encodeRelation(item)
 	}
 
 	// write to db
-	err = c.DB.Put(key, value, nil)
+	err := c.DB.Put(key, value)
 	if err != nil {
 		return err
 	}
@@ -39,50 +39,34 @@ func (c *Connection) WriteRelation(item gosmparse.Relation) error {
 func (c *Connection) ReadRelation(id int64) (*gosmparse.Relation, error) {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(id))
-
-	// prepend relation identifier
-	key = append(prefix["relation"], key...)
+	key := c.key("relation", id)
 
 	// read from db
-	data, err := c.DB.Get(key, nil)
+	data, err := c.DB.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
 	// decode item
-	var relation gosmparse.Relation
-	err = msgpack.Unmarshal(data, &relation)
-	if err != nil {
-		log.Println("decode failed", err)
-		return nil, err
-	}
-
-	return &relation, nil
+	return c.decodeRelation(id, data)
 }
 
-// IterateRelations - read all relations from db and decode one-by-one
-func (c *Connection) IterateRelations(cb func(*gosmparse.Relation, error)) {
-
-	iter := c.DB.NewIterator(util.BytesPrefix(prefix["relation"]), nil)
-	for iter.Next() {
-
-		// get key/value data
-		// key := iter.Key()
-		data := iter.Value()
-
-		// decode item
+// decodeRelation - decode value using the schema of this db
+func (c *Connection) decodeRelation(id int64, data []byte) (*gosmparse.Relation, error) {
+	if c.Version < 2 {
 		var relation gosmparse.Relation
 		err := msgpack.Unmarshal(data, &relation)
 		if err != nil {
 			log.Println("decode failed", err)
-			cb(nil, err)
-			return
+			return nil, err
 		}
-
-		cb(&relation, nil)
+		return &relation, nil
 	}
 
-	iter.Release()
+	relation, err := decodeRelation(id, data)
+	if err != nil {
+		log.Println("decode failed", err)
+		return nil, err
+	}
+	return relation, nil
 }
@@ -0,0 +1,220 @@
+package leveldb
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+	"github.com/vmihailenco/msgpack"
+)
+
+// SchemaVersion - the key/value layout written by this version of the code
+//
+// v1: elements stored as msgpack, coords stored as two float64 values
+//
+//	under an unprefixed 8 byte key.
+//
+// v2: elements stored in a compact binary encoding with delta-encoded
+//
+//	refs, coords stored as two int32 fixed-point values under a 'C' prefix.
+const SchemaVersion = 2
+
+// key under which the schema version is stored
+var versionKey = append(append([]byte{}, prefix["meta"]...), "schema_version"...)
+
+// key under which the last key rewritten by an unfinished migration is stored
+var migrateProgressKey = append(append([]byte{}, prefix["meta"]...), "migrate_progress"...)
+
+// loadVersion - detect which schema the database was written with
+func (c *Connection) loadVersion() {
+
+	// version has been recorded
+	if data, err := c.DB.Get(versionKey); err == nil {
+		version, err := strconv.Atoi(string(data))
+		if err != nil {
+			panic("invalid schema version: " + string(data))
+		}
+		c.Version = version
+		return
+	}
+
+	// an empty database can use the current schema
+	var empty = true
+	c.DB.Iterate(nil, nil, func(key []byte, value []byte) bool {
+		empty = false
+		return false
+	})
+
+	if empty {
+		c.setVersion(SchemaVersion)
+		return
+	}
+
+	// non-empty databases without a version predate versioning
+	c.Version = 1
+	log.Println("leveldb uses the legacy v1 schema, run 'pbf leveldb-migrate' to upgrade it")
+}
+
+// setVersion - record the schema version
+func (c *Connection) setVersion(version int) {
+	err := c.DB.Put(versionKey, []byte(strconv.Itoa(version)))
+	if err != nil {
+		panic(err)
+	}
+	c.Version = version
+}
+
+// key - generate the db key for an element of type typ
+func (c *Connection) key(typ string, id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+
+	// v1 stored coords without a prefix
+	if c.Version < 2 && typ == "coord" {
+		return key
+	}
+
+	return append(append([]byte{}, prefix[typ]...), key...)
+}
+
+// encodeCoordValue - encode lat/lon using the schema of this db
+func (c *Connection) encodeCoordValue(item *gosmparse.Node) []byte {
+	if c.Version < 2 {
+		value := make([]byte, 16)
+		binary.BigEndian.PutUint64(value[:8], math.Float64bits(item.Lat))
+		binary.BigEndian.PutUint64(value[8:], math.Float64bits(item.Lon))
+		return value
+	}
+	return encodeCoord(item.Lat, item.Lon)
+}
+
+// decodeCoordValue - decode lat/lon using the schema of this db
+func (c *Connection) decodeCoordValue(data []byte) (float64, float64, error) {
+	if c.Version < 2 {
+		if len(data) < 16 {
+			return 0, 0, errCorrupt
+		}
+		lat := math.Float64frombits(binary.BigEndian.Uint64(data[:8]))
+		lon := math.Float64frombits(binary.BigEndian.Uint64(data[8:]))
+		return lat, lon, nil
+	}
+	return decodeCoord(data)
+}
+
+// Migrate - rewrite a v1 database in place using the current schema
+func (c *Connection) Migrate() error {
+
+	if c.Version >= SchemaVersion {
+		log.Printf("leveldb already uses schema v%d\n", c.Version)
+		return nil
+	}
+
+	var count int
+	var batch = new(kv.Batch)
+	var start []byte
+
+	// resume an interrupted migration after the last chunk written,
+	// the keys before it have already been rewritten using the new schema
+	if progress, err := c.DB.Get(migrateProgressKey); err == nil {
+		start = append(append([]byte{}, progress...), 0x00)
+		log.Println("resuming interrupted migration")
+	}
+
+	// backends may not be written to while iterating so the keyspace is
+	// visited in chunks, each chunk is written before resuming iteration.
+	// note: elements are rewritten under the same key and iteration resumes
+	// after the last visited key so they are never decoded twice, migrated
+	// coords may be visited again but are skipped as they are prefixed.
+	// the last visited key is written with each chunk so that an interrupted
+	// migration resumes from the same position.
+	for {
+		var failed error
+		var last []byte
+
+		err := c.DB.Iterate(start, nil, func(key []byte, data []byte) bool {
+			last = append(last[:0], key...)
+
+			switch {
+
+			// legacy unprefixed coordinate
+			case len(key) == 8:
+				if len(data) < 16 {
+					failed = errCorrupt
+					return false
+				}
+				lat := math.Float64frombits(binary.BigEndian.Uint64(data[:8]))
+				lon := math.Float64frombits(binary.BigEndian.Uint64(data[8:]))
+				batch.Delete(append([]byte{}, key...))
+				batch.Put(append(append([]byte{}, prefix["coord"]...), key...), encodeCoord(lat, lon))
+
+			case len(key) == 9 && key[0] == prefix["node"][0]:
+				var node gosmparse.Node
+				if failed = msgpack.Unmarshal(data, &node); failed != nil {
+					return false
+				}
+				batch.Put(append([]byte{}, key...), encodeNode(node))
+
+			case len(key) == 9 && key[0] == prefix["way"][0]:
+				var way gosmparse.Way
+				if failed = msgpack.Unmarshal(data, &way); failed != nil {
+					return false
+				}
+				batch.Put(append([]byte{}, key...), encodeWay(way))
+
+			case len(key) == 9 && key[0] == prefix["relation"][0]:
+				var relation gosmparse.Relation
+				if failed = msgpack.Unmarshal(data, &relation); failed != nil {
+					return false
+				}
+				batch.Put(append([]byte{}, key...), encodeRelation(relation))
+
+			default:
+				return true
+			}
+
+			count++
+			return batch.Len() < batchSize
+		})
+		if err != nil {
+			return err
+		}
+		if failed != nil {
+			return failed
+		}
+
+		// write chunk along with the progress
+		var done = batch.Len() < batchSize
+		if nil != last {
+			batch.Put(migrateProgressKey, append([]byte{}, last...))
+		}
+		if err := c.DB.Write(batch); err != nil {
+			return err
+		}
+		batch.Reset()
+
+		if done {
+			break
+		}
+		log.Printf("migrated %d records\n", count)
+
+		// resume from the key following the last one visited
+		start = append(last, 0x00)
+	}
+
+	// compact to reclaim the space used by the old values
+	if err := c.DB.Compact(); err != nil {
+		return err
+	}
+
+	c.setVersion(SchemaVersion)
+	batch.Delete(migrateProgressKey)
+	if err := c.DB.Write(batch); err != nil {
+		return err
+	}
+	log.Printf("migrated %d records to schema v%d\n", count, SchemaVersion)
+
+	return nil
+}
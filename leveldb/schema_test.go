@@ -0,0 +1,110 @@
+package leveldb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack"
+)
+
+// writeV1 - write a v1 database by hand
+func writeV1(db kv.Backend) {
+	for id := int64(1); id <= 25; id++ {
+		var key = make([]byte, 8)
+		binary.BigEndian.PutUint64(key, uint64(id))
+		var value = make([]byte, 16)
+		binary.BigEndian.PutUint64(value[:8], math.Float64bits(float64(id)/10))
+		binary.BigEndian.PutUint64(value[8:], math.Float64bits(-float64(id)/10))
+		db.Put(key, value)
+
+		data, _ := msgpack.Marshal(gosmparse.Way{ID: id, NodeIDs: []int64{id, id + 1}})
+		db.Put(append([]byte{'W'}, key...), data)
+	}
+}
+
+// failingBackend - a backend which fails writes after a number of batches
+type failingBackend struct {
+	*kv.Memory
+	writes int
+}
+
+func (f *failingBackend) Write(batch *kv.Batch) error {
+	if f.writes == 0 {
+		return errors.New("interrupted")
+	}
+	f.writes--
+	return f.Memory.Write(batch)
+}
+
+func TestMigrate(t *testing.T) {
+	var db = kv.NewMemory()
+	writeV1(db)
+
+	var conn = &Connection{}
+	conn.OpenBackend(db)
+	assert.Equal(t, 1, conn.Version)
+
+	// force several chunks
+	defer func(size int) { batchSize = size }(batchSize)
+	batchSize = 7
+
+	assert.Nil(t, conn.Migrate())
+	assert.Equal(t, SchemaVersion, conn.Version)
+
+	for id := int64(1); id <= 25; id++ {
+		node, err := conn.ReadCoord(id)
+		assert.Nil(t, err)
+		assert.Equal(t, float64(id)/10, node.Lat)
+		assert.Equal(t, -float64(id)/10, node.Lon)
+
+		way, err := conn.ReadWay(id)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{id, id + 1}, way.NodeIDs)
+	}
+
+	// re-opening detects the new version
+	var reopened = &Connection{}
+	reopened.OpenBackend(db)
+	assert.Equal(t, SchemaVersion, reopened.Version)
+}
+
+func TestMigrateResume(t *testing.T) {
+	var db = kv.NewMemory()
+	writeV1(db)
+
+	// force several chunks
+	defer func(size int) { batchSize = size }(batchSize)
+	batchSize = 7
+
+	// interrupt the migration after the coords and some of the ways
+	var conn = &Connection{}
+	conn.OpenBackend(&failingBackend{Memory: db, writes: 8})
+	assert.Equal(t, 1, conn.Version)
+	assert.NotNil(t, conn.Migrate())
+	assert.Equal(t, 1, conn.Version)
+
+	// the migration resumes after the chunks already written
+	var resumed = &Connection{}
+	resumed.OpenBackend(db)
+	assert.Equal(t, 1, resumed.Version)
+	assert.Nil(t, resumed.Migrate())
+	assert.Equal(t, SchemaVersion, resumed.Version)
+
+	for id := int64(1); id <= 25; id++ {
+		node, err := resumed.ReadCoord(id)
+		assert.Nil(t, err)
+		assert.Equal(t, float64(id)/10, node.Lat)
+
+		way, err := resumed.ReadWay(id)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{id, id + 1}, way.NodeIDs)
+	}
+
+	_, err := db.Get(migrateProgressKey)
+	assert.Equal(t, kv.ErrNotFound, err)
+}
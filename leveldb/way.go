@@ -1,7 +1,6 @@
 package leveldb
 
 import (
-	"encoding/binary"
 	"log"
 
 	"github.com/missinglink/gosmparse"
@@ -12,21 +11,23 @@ import (
 func (c *Connection) WriteWay(item gosmparse.Way) error {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(item.ID))
-
-	// prepend way identifier
-	key = append(prefix["way"], key...)
+	key := c.key("way", item.ID)
 
 	// encode item
-	value, err := msgpack.Marshal(item)
-	if err != nil {
-		log.Println("encode failed", err)
-		return err
+	var value []byte
+	if c.Version < 2 {
+		var err error
+		value, err = msgpack.Marshal(item)
+		if err != nil {
+			log.Println("encode failed", err)
+			return err
+		}
+	} else {
+		value = encodeWay(item)
 	}
 
 	// write to db
-	err = c.DB.Put(key, value, nil)
+	err := c.DB.Put(key, value)
 	if err != nil {
 		return err
 	}
@@ -38,25 +39,34 @@ func (c *Connection) WriteWay(item gosmparse.Way) error {
 func (c *Connection) ReadWay(id int64) (*gosmparse.Way, error) {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(id))
-
-	// prepend way identifier
-	key = append(prefix["way"], key...)
+	key := c.key("way", id)
 
 	// read from db
-	data, err := c.DB.Get(key, nil)
+	data, err := c.DB.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
 	// decode item
-	var way gosmparse.Way
-	err = msgpack.Unmarshal(data, &way)
+	return c.decodeWay(id, data)
+}
+
+// decodeWay - decode value using the schema of this db
+func (c *Connection) decodeWay(id int64, data []byte) (*gosmparse.Way, error) {
+	if c.Version < 2 {
+		var way gosmparse.Way
+		err := msgpack.Unmarshal(data, &way)
+		if err != nil {
+			log.Println("decode failed", err)
+			return nil, err
+		}
+		return &way, nil
+	}
+
+	way, err := decodeWay(id, data)
 	if err != nil {
 		log.Println("decode failed", err)
 		return nil, err
 	}
-
-	return &way, nil
+	return way, nil
 }
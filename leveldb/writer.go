@@ -1,13 +1,11 @@
 package leveldb
 
 import (
-	"encoding/binary"
 	"log"
-	"math"
 	"sync"
 
 	"github.com/missinglink/gosmparse"
-	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/missinglink/pbf/kv"
 )
 
 var batchSize = 20000
@@ -16,10 +14,10 @@ var batchSize = 20000
 type CoordWriter struct {
 	Conn      *Connection
 	WaitGroup *sync.WaitGroup
-	Queue     chan kv
+	Queue     chan pair
 }
 
-type kv struct {
+type pair struct {
 	Key []byte
 	Val []byte
 }
@@ -29,13 +27,13 @@ func NewCoordWriter(conn *Connection) *CoordWriter {
 	w := &CoordWriter{
 		Conn:      conn,
 		WaitGroup: &sync.WaitGroup{},
-		Queue:     make(chan kv, batchSize*10),
+		Queue:     make(chan pair, batchSize*10),
 	}
 
 	// start writer routine
 	w.WaitGroup.Add(1)
 	go func() {
-		batch := new(leveldb.Batch)
+		batch := new(kv.Batch)
 		for row := range w.Queue {
 
 			// put
@@ -45,7 +43,7 @@ func NewCoordWriter(conn *Connection) *CoordWriter {
 			if batch.Len() >= batchSize {
 
 				// write batch
-				err := w.Conn.DB.Write(batch, nil)
+				err := w.Conn.DB.Write(batch)
 				if err != nil {
 					log.Println(err)
 				}
@@ -56,7 +54,7 @@ func NewCoordWriter(conn *Connection) *CoordWriter {
 		}
 
 		// write final batch
-		err := w.Conn.DB.Write(batch, nil)
+		err := w.Conn.DB.Write(batch)
 		if err != nil {
 			log.Println(err)
 		}
@@ -71,21 +69,12 @@ func NewCoordWriter(conn *Connection) *CoordWriter {
 func (w *CoordWriter) Enqueue(item *gosmparse.Node) {
 
 	// encode id
-	key := make([]byte, 8)
-	binary.BigEndian.PutUint64(key, uint64(item.ID))
+	key := w.Conn.key("coord", item.ID)
 
-	// encode lat
-	lat := make([]byte, 8)
-	binary.BigEndian.PutUint64(lat, math.Float64bits(item.Lat))
+	// encode lat/lon
+	value := w.Conn.encodeCoordValue(item)
 
-	// encode lon
-	lon := make([]byte, 8)
-	binary.BigEndian.PutUint64(lon, math.Float64bits(item.Lon))
-
-	// value
-	value := append(lat, lon...)
-
-	w.Queue <- kv{Key: key, Val: value}
+	w.Queue <- pair{Key: key, Val: value}
 }
 
 // Close - close the channel and block until done
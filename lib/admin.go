@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// AdminPlacetypes - hierarchy keys for each admin_level, these follow the
+// address keys used by nominatim, other levels are keyed as 'admin_level_N'
+var AdminPlacetypes = map[int]string{
+	2:  "country",
+	3:  "region",
+	4:  "state",
+	5:  "state_district",
+	6:  "county",
+	7:  "municipality",
+	8:  "city",
+	9:  "city_district",
+	10: "suburb",
+	11: "neighbourhood",
+}
+
+// AdminArea - an assembled administrative boundary
+type AdminArea struct {
+	ID       int64        `json:"id"`
+	Name     string       `json:"name,omitempty"`
+	Level    int          `json:"admin_level"`
+	Geometry MultiPolygon `json:"-"`
+	Bounds   *BBox        `json:"-"`
+	Subareas []int64      `json:"-"` // ids of relations with the subarea role
+
+	area float64
+}
+
+// NewAdminArea - constructor, returns false when the tags don't contain a
+// valid admin_level
+func NewAdminArea(id int64, tags map[string]string, geometry MultiPolygon) (*AdminArea, bool) {
+	level, err := strconv.Atoi(tags["admin_level"])
+	if nil != err || level < 1 || len(geometry) == 0 {
+		return nil, false
+	}
+
+	var area float64
+	for _, polygon := range geometry {
+		area += math.Abs(polygon[0].SignedArea())
+	}
+
+	return &AdminArea{
+		ID:       id,
+		Name:     tags["name"],
+		Level:    level,
+		Geometry: geometry,
+		Bounds:   geometry.Bounds(),
+		area:     area,
+	}, true
+}
+
+// Placetype - the hierarchy key for this area
+func (a *AdminArea) Placetype() string {
+	if placetype, ok := AdminPlacetypes[a.Level]; ok {
+		return placetype
+	}
+	return "admin_level_" + strconv.Itoa(a.Level)
+}
+
+// AdminLookup - point-in-polygon lookup of admin areas, indexed by an rtree
+type AdminLookup struct {
+	tree *RTree
+}
+
+// NewAdminLookup - index areas
+func NewAdminLookup(areas []*AdminArea) *AdminLookup {
+	var items = make([]RTreeItem, 0, len(areas))
+	for _, area := range areas {
+		items = append(items, RTreeItem{Bounds: area.Bounds, Value: area})
+	}
+	return &AdminLookup{tree: NewRTree(items)}
+}
+
+// Len - the number of indexed areas
+func (l *AdminLookup) Len() int {
+	return l.tree.Len()
+}
+
+// Contains - the areas containing the point, ordered from the highest level
+// (lowest admin_level) down, overlapping areas at the same level are
+// ordered largest first
+func (l *AdminLookup) Contains(lon float64, lat float64) []*AdminArea {
+	var found []*AdminArea
+	l.tree.SearchPoint(lon, lat, func(item RTreeItem) bool {
+		var area = item.Value.(*AdminArea)
+		if area.Geometry.Contains(lon, lat) {
+			found = append(found, area)
+		}
+		return true
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Level != found[j].Level {
+			return found[i].Level < found[j].Level
+		}
+		if found[i].area != found[j].area {
+			return found[i].area > found[j].area
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found
+}
+
+// Hierarchy - the areas containing the point keyed by placetype, where areas
+// at the same level overlap the smallest is used
+func (l *AdminLookup) Hierarchy(lon float64, lat float64) map[string]*AdminArea {
+	var hierarchy = make(map[string]*AdminArea)
+	for _, area := range l.Contains(lon, lat) {
+		hierarchy[area.Placetype()] = area
+	}
+	return hierarchy
+}
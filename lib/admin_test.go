@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func square(minLon, minLat, maxLon, maxLat float64) MultiPolygon {
+	return MultiPolygon{{{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat}}}}
+}
+
+func TestNewAdminArea(t *testing.T) {
+	area, ok := NewAdminArea(1, map[string]string{"admin_level": "4", "name": "A"}, square(0, 0, 1, 1))
+	assert.True(t, ok)
+	assert.Equal(t, "state", area.Placetype())
+	assert.Equal(t, &BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1}, area.Bounds)
+
+	area, _ = NewAdminArea(1, map[string]string{"admin_level": "12"}, square(0, 0, 1, 1))
+	assert.Equal(t, "admin_level_12", area.Placetype())
+
+	_, ok = NewAdminArea(1, map[string]string{"admin_level": "x"}, square(0, 0, 1, 1))
+	assert.False(t, ok)
+	_, ok = NewAdminArea(1, map[string]string{"admin_level": "4"}, nil)
+	assert.False(t, ok)
+}
+
+func TestAdminLookup(t *testing.T) {
+	var country, _ = NewAdminArea(1, map[string]string{"admin_level": "2", "name": "Country"}, square(0, 0, 10, 10))
+	var state, _ = NewAdminArea(2, map[string]string{"admin_level": "4", "name": "State"}, square(0, 0, 5, 5))
+	var city, _ = NewAdminArea(3, map[string]string{"admin_level": "8", "name": "City"}, square(1, 1, 2, 2))
+	var overlap, _ = NewAdminArea(4, map[string]string{"admin_level": "8", "name": "Overlap"}, square(1, 1, 3, 3))
+	var lookup = NewAdminLookup([]*AdminArea{city, overlap, state, country})
+
+	assert.Equal(t, []*AdminArea{country, state, overlap, city}, lookup.Contains(1.5, 1.5))
+	assert.Equal(t, []*AdminArea{country}, lookup.Contains(7, 7))
+	assert.Empty(t, lookup.Contains(20, 20))
+
+	// the smallest area is used when areas at the same level overlap
+	assert.Equal(t, map[string]*AdminArea{"country": country, "state": state, "city": city}, lookup.Hierarchy(1.5, 1.5))
+}
@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"sort"
+)
+
+// flags describing problems found while building the admin hierarchy
+const (
+	AdminFlagOrphan   = "orphan"           // no parent found, but not a top level area
+	AdminFlagOverlap  = "overlap"          // overlaps another area with the same admin_level
+	AdminFlagHint     = "subarea_hint"     // parent taken from a subarea member, not the geometry
+	AdminFlagMismatch = "subarea_mismatch" // geometry disagrees with the subarea members
+)
+
+// AdminNode - the position of an admin area in the hierarchy
+type AdminNode struct {
+	*AdminArea
+	Parent   int64        `json:"parent,omitempty"`
+	Depth    int          `json:"depth"`
+	Flags    []string     `json:"flags,omitempty"`
+	Children []*AdminNode `json:"-"`
+}
+
+// BuildAdminTree - compute the containment hierarchy of areas, the parent of
+// an area is the smallest area with a lower admin_level containing a point
+// inside it. subarea members are used where the geometry finds no parent.
+// returns the root nodes, ordered by admin_level and name.
+func BuildAdminTree(areas []*AdminArea) []*AdminNode {
+	var lookup = NewAdminLookup(areas)
+	var nodes = make(map[int64]*AdminNode, len(areas))
+	var minLevel = 0
+	for _, area := range areas {
+		nodes[area.ID] = &AdminNode{AdminArea: area}
+		if 0 == minLevel || area.Level < minLevel {
+			minLevel = area.Level
+		}
+	}
+
+	// subarea hints, child id -> parent ids
+	var hints = make(map[int64][]int64)
+	for _, area := range areas {
+		for _, child := range area.Subareas {
+			hints[child] = append(hints[child], area.ID)
+		}
+	}
+
+	for _, area := range areas {
+		var node = nodes[area.ID]
+		var point, ok = area.Geometry.InteriorPoint()
+
+		// containing areas, ordered by level then largest first
+		var parent *AdminArea
+		if ok {
+			for _, other := range lookup.Contains(point[0], point[1]) {
+				switch {
+				case other.ID == area.ID:
+				case other.Level < area.Level:
+					parent = other
+				case other.Level == area.Level:
+					flag(node, AdminFlagOverlap)
+					flag(nodes[other.ID], AdminFlagOverlap)
+				}
+			}
+		}
+
+		// compare with, or fall back to, subarea members
+		if nil != parent {
+			if len(hints[area.ID]) > 0 && !containsID(hints[area.ID], parent.ID) {
+				flag(node, AdminFlagMismatch)
+			}
+		} else {
+			for _, id := range hints[area.ID] {
+				if hinted, ok := nodes[id]; ok && hinted.Level < area.Level {
+					parent = hinted.AdminArea
+					flag(node, AdminFlagHint)
+					break
+				}
+			}
+		}
+
+		if nil == parent {
+			if area.Level > minLevel {
+				flag(node, AdminFlagOrphan)
+			}
+			continue
+		}
+		node.Parent = parent.ID
+		nodes[parent.ID].Children = append(nodes[parent.ID].Children, node)
+	}
+
+	// sort and compute depths
+	var roots []*AdminNode
+	for _, node := range nodes {
+		if 0 == node.Parent {
+			roots = append(roots, node)
+		}
+	}
+	sortAdminNodes(roots, 0)
+	return roots
+}
+
+// WalkAdminTree - visit nodes depth first, parents before their children
+func WalkAdminTree(nodes []*AdminNode, fn func(node *AdminNode)) {
+	for _, node := range nodes {
+		fn(node)
+		WalkAdminTree(node.Children, fn)
+	}
+}
+
+// sortAdminNodes - order siblings by level, name and id, and set depths
+func sortAdminNodes(nodes []*AdminNode, depth int) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Level != nodes[j].Level {
+			return nodes[i].Level < nodes[j].Level
+		}
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	for _, node := range nodes {
+		node.Depth = depth
+		sortAdminNodes(node.Children, depth+1)
+	}
+}
+
+// flag - add a flag once
+func flag(node *AdminNode, f string) {
+	for _, existing := range node.Flags {
+		if existing == f {
+			return
+		}
+	}
+	node.Flags = append(node.Flags, f)
+}
+
+// containsID - yes/no if id is in ids
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func adminArea(id int64, level string, geometry MultiPolygon, subareas ...int64) *AdminArea {
+	var area, _ = NewAdminArea(id, map[string]string{"admin_level": level}, geometry)
+	area.Subareas = subareas
+	return area
+}
+
+func TestBuildAdminTree(t *testing.T) {
+	var country = adminArea(1, "2", square(0, 0, 10, 10), 2)
+	var state = adminArea(2, "4", square(0, 0, 5, 5))
+	var city = adminArea(3, "8", square(1, 1, 2, 2)) // no level 6 in between
+	var roots = BuildAdminTree([]*AdminArea{city, state, country})
+
+	assert.Len(t, roots, 1)
+	assert.Equal(t, int64(1), roots[0].ID)
+	assert.Equal(t, int64(2), roots[0].Children[0].ID)
+	assert.Equal(t, int64(1), roots[0].Children[0].Parent)
+	assert.Equal(t, int64(2), roots[0].Children[0].Children[0].Parent)
+	assert.Equal(t, 2, roots[0].Children[0].Children[0].Depth)
+
+	var flags = 0
+	WalkAdminTree(roots, func(node *AdminNode) { flags += len(node.Flags) })
+	assert.Equal(t, 0, flags)
+}
+
+func TestBuildAdminTreeFlags(t *testing.T) {
+	var country = adminArea(1, "2", square(0, 0, 10, 10), 5)
+	var other = adminArea(2, "2", square(20, 0, 30, 10), 3)
+	var mismatch = adminArea(3, "4", square(1, 1, 2, 2))
+	var orphan = adminArea(4, "4", square(50, 50, 51, 51))
+	var hinted = adminArea(5, "4", square(40, 40, 41, 41))
+	var overlapA = adminArea(6, "6", square(5, 5, 7, 7))
+	var overlapB = adminArea(7, "6", square(6, 6, 8, 8))
+	var roots = BuildAdminTree([]*AdminArea{country, other, mismatch, orphan, hinted, overlapA, overlapB})
+
+	var nodes = make(map[int64]*AdminNode)
+	WalkAdminTree(roots, func(node *AdminNode) { nodes[node.ID] = node })
+
+	assert.Len(t, roots, 3)
+	assert.Nil(t, nodes[1].Flags)
+	assert.Equal(t, []string{AdminFlagMismatch}, nodes[3].Flags)
+	assert.Equal(t, int64(1), nodes[3].Parent)
+	assert.Equal(t, []string{AdminFlagOrphan}, nodes[4].Flags)
+	assert.Equal(t, []string{AdminFlagHint}, nodes[5].Flags)
+	assert.Equal(t, int64(1), nodes[5].Parent)
+	assert.Equal(t, []string{AdminFlagOverlap}, nodes[6].Flags)
+	assert.Equal(t, []string{AdminFlagOverlap}, nodes[7].Flags)
+}
+
+func TestInteriorPoint(t *testing.T) {
+
+	// u-shape, the centroid lies outside the polygon
+	var u = MultiPolygon{{{{0, 0}, {3, 0}, {3, 3}, {2, 3}, {2, 1}, {1, 1}, {1, 3}, {0, 3}, {0, 0}}}}
+	point, ok := u.InteriorPoint()
+	assert.True(t, ok)
+	assert.True(t, u.Contains(point[0], point[1]))
+
+	// square with a hole in the middle
+	var holed = MultiPolygon{{square(0, 0, 10, 10)[0][0], square(2, 2, 8, 8)[0][0]}}
+	point, ok = holed.InteriorPoint()
+	assert.True(t, ok)
+	assert.True(t, holed.Contains(point[0], point[1]))
+
+	_, ok = MultiPolygon{}.InteriorPoint()
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package lib
+
+// keys which imply a closed way is an area for any value except those listed
+var areaKeys = map[string]map[string]bool{
+	"building":         {},
+	"building:part":    {},
+	"landuse":          {},
+	"amenity":          {},
+	"leisure":          {},
+	"shop":             {},
+	"tourism":          {},
+	"historic":         {},
+	"military":         {},
+	"office":           {},
+	"place":            {},
+	"boundary":         {},
+	"public_transport": {},
+	"area:highway":     {},
+	"aeroway":          {"taxiway": true},
+	"power":            {"line": true, "minor_line": true, "cable": true},
+	"man_made":         {"cutline": true, "embankment": true, "pipeline": true},
+	"natural":          {"coastline": true, "cliff": true, "ridge": true, "arete": true, "tree_row": true},
+}
+
+// keys which imply a closed way is an area only for the values listed
+var areaValues = map[string]map[string]bool{
+	"waterway": {"riverbank": true, "dock": true, "boatyard": true, "dam": true},
+	"barrier":  {"city_wall": true, "ditch": true, "hedge": true, "retaining_wall": true, "wall": true, "spikes": true},
+	"railway":  {"station": true, "turntable": true, "roundhouse": true, "platform": true},
+	"highway":  {"services": true, "rest_area": true, "escape": true, "elevator": true},
+}
+
+// IsArea - yes/no if a closed way with these tags represents an area rather
+// than a closed linestring, based on the conventions used by osm2pgsql and iD.
+func IsArea(tags map[string]string) bool {
+
+	// explicit tagging takes precedence
+	switch tags["area"] {
+	case "yes":
+		return true
+	case "no":
+		return false
+	}
+
+	for key, value := range tags {
+		if "no" == value {
+			continue
+		}
+		if exceptions, ok := areaKeys[key]; ok && !exceptions[value] {
+			return true
+		}
+		if values, ok := areaValues[key]; ok && values[value] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -36,6 +36,17 @@ func (b *Bitmask) Len() uint64 {
 	return l
 }
 
+// Clone - create an independent copy of the mask
+func (b *Bitmask) Clone() *Bitmask {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	var c = NewBitMask()
+	for k, v := range b.I {
+		c.I[k] = v
+	}
+	return c
+}
+
 // NewBitMask - constructor
 func NewBitMask() *Bitmask {
 	return &Bitmask{
@@ -27,6 +27,16 @@ func NewBitmaskMap() *BitmaskMap {
 	}
 }
 
+// Clone - create an independent copy of all masks
+func (m *BitmaskMap) Clone() *BitmaskMap {
+	return &BitmaskMap{
+		Nodes:     m.Nodes.Clone(),
+		Ways:      m.Ways.Clone(),
+		Relations: m.Relations.Clone(),
+		WayRefs:   m.WayRefs.Clone(),
+	}
+}
+
 // WriteTo - write to destination
 func (m *BitmaskMap) WriteTo(sink io.Writer) (int64, error) {
 	encoder := gob.NewEncoder(sink)
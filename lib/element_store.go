@@ -0,0 +1,23 @@
+package lib
+
+import "github.com/missinglink/gosmparse"
+
+// ElementStore - persistent storage of elements and node locations
+// note: leveldb.Connection satisfies this interface for all kv backends
+type ElementStore interface {
+	WriteNode(item gosmparse.Node) error
+	ReadNode(id int64) (*gosmparse.Node, error)
+	WriteWay(item gosmparse.Way) error
+	ReadWay(id int64) (*gosmparse.Way, error)
+	WriteRelation(item gosmparse.Relation) error
+	ReadRelation(id int64) (*gosmparse.Relation, error)
+	WriteCoord(item gosmparse.Node) error
+	ReadCoord(id int64) (*gosmparse.Node, error)
+	Close()
+}
+
+// TagIndex - maintains secondary indexes on element tags
+// note: leveldb.Connection satisfies this interface
+type TagIndex interface {
+	IndexTags(typ string, id int64, tags map[string]string) error
+}
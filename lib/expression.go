@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	tagutils "github.com/missinglink/pbf/tags"
+)
+
+// ParseExpression - parse a tag expression such as 'boundary=administrative & admin_level=4 | place'
+// in to a Group, '&' binds more tightly than '|'. conditions are either 'key' or 'key=value'.
+func ParseExpression(expr string) (Group, error) {
+	var group Group
+	for _, or := range strings.Split(expr, "|") {
+		var pattern Pattern
+		for _, and := range strings.Split(or, "&") {
+			var condition = strings.TrimSpace(and)
+			if "" == condition || strings.HasPrefix(condition, "=") {
+				return nil, fmt.Errorf("invalid condition in expression: '%s'", expr)
+			}
+
+			// normalize whitespace around '='
+			if parts := strings.SplitN(condition, "=", 2); len(parts) == 2 {
+				condition = strings.TrimSpace(parts[0]) + "=" + strings.TrimSpace(parts[1])
+			}
+
+			pattern = append(pattern, Condition(condition))
+		}
+		group = append(group, pattern)
+	}
+	return group, nil
+}
+
+// Match - yes/no if any pattern in the group matches the tags
+func (g Group) Match(tags map[string]string) bool {
+	return matchGroup(tags, g)
+}
+
+// Match - yes/no if all conditions in the pattern match the tags
+func (p Pattern) Match(tags map[string]string) bool {
+	return len(tags) > 0 && matchPattern(tagutils.Trim(tags), p)
+}
+
+// Key - the tag key of the condition
+func (c Condition) Key() string {
+	return strings.SplitN(string(c), "=", 2)[0]
+}
+
+// Value - the tag value of the condition, nil when only the key is checked
+func (c Condition) Value() *string {
+	parts := strings.SplitN(string(c), "=", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+	return &parts[1]
+}
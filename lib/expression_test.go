@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpression(t *testing.T) {
+	group, err := ParseExpression("boundary = administrative & admin_level=4 | place")
+	assert.Nil(t, err)
+	assert.Equal(t, Group{
+		Pattern{"boundary=administrative", "admin_level=4"},
+		Pattern{"place"},
+	}, group)
+
+	assert.True(t, group.Match(map[string]string{"boundary": "administrative", "admin_level": "4"}))
+	assert.True(t, group.Match(map[string]string{"place": "city"}))
+	assert.False(t, group.Match(map[string]string{"boundary": "administrative", "admin_level": "2"}))
+
+	assert.Equal(t, "boundary", group[0][0].Key())
+	assert.Equal(t, "administrative", *group[0][0].Value())
+	assert.Nil(t, group[1][0].Value())
+
+	_, err = ParseExpression("a & | b")
+	assert.NotNil(t, err)
+	_, err = ParseExpression("=b")
+	assert.NotNil(t, err)
+}
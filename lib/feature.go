@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Feature - an OSM element with a geometry
+type Feature struct {
+	Type     string // node, way or relation
+	ID       int64
+	Tags     map[string]string
+	Geometry Geometry
+}
+
+// Geometry - a shape which can be serialized to various formats
+type Geometry interface {
+	GeometryType() string
+	WKT() string
+	WKB() []byte
+	Bounds() *BBox
+}
+
+// Point - a single [lon, lat] position
+type Point []float64
+
+// LineString - a sequence of [lon, lat] positions
+type LineString [][]float64
+
+// GeometryType - WKT type name
+func (p Point) GeometryType() string { return "POINT" }
+
+// GeometryType - WKT type name
+func (l LineString) GeometryType() string { return "LINESTRING" }
+
+// GeometryType - WKT type name
+func (p Polygon) GeometryType() string { return "POLYGON" }
+
+// GeometryType - WKT type name
+func (m MultiPolygon) GeometryType() string { return "MULTIPOLYGON" }
+
+// WKT - well known text
+func (p Point) WKT() string {
+	var b strings.Builder
+	b.WriteString("POINT(")
+	writePosition(&b, p)
+	b.WriteString(")")
+	return b.String()
+}
+
+// WKT - well known text
+func (l LineString) WKT() string {
+	var b strings.Builder
+	b.WriteString("LINESTRING")
+	writePositions(&b, l)
+	return b.String()
+}
+
+// WKT - well known text
+func (p Polygon) WKT() string {
+	var b strings.Builder
+	b.WriteString("POLYGON")
+	writeRings(&b, p)
+	return b.String()
+}
+
+// WKT - well known text
+func (m MultiPolygon) WKT() string {
+	var b strings.Builder
+	b.WriteString("MULTIPOLYGON(")
+	for i, p := range m {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		writeRings(&b, p)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Bounds - bounding box
+func (p Point) Bounds() *BBox {
+	return &BBox{MinLon: p[0], MinLat: p[1], MaxLon: p[0], MaxLat: p[1]}
+}
+
+// Bounds - bounding box
+func (l LineString) Bounds() *BBox {
+	var b = NewEmptyBBox()
+	for _, pos := range l {
+		b.Extend(pos[0], pos[1])
+	}
+	return b
+}
+
+// Bounds - bounding box
+func (p Polygon) Bounds() *BBox {
+	return MultiPolygon{p}.Bounds()
+}
+
+// coordinates are written with the precision used by OSM
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e7)/1e7, 'f', -1, 64)
+}
+
+func writePosition(b *strings.Builder, pos []float64) {
+	b.WriteString(formatCoord(pos[0]))
+	b.WriteString(" ")
+	b.WriteString(formatCoord(pos[1]))
+}
+
+func writePositions(b *strings.Builder, positions [][]float64) {
+	b.WriteString("(")
+	for i, pos := range positions {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		writePosition(b, pos)
+	}
+	b.WriteString(")")
+}
+
+func writeRings(b *strings.Builder, rings []Ring) {
+	b.WriteString("(")
+	for i, r := range rings {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		writePositions(b, r)
+	}
+	b.WriteString(")")
+}
+
+// FeatureWriter - a destination for features
+// note: implementations must be safe for concurrent use
+type FeatureWriter interface {
+	WriteFeature(feature *Feature)
+}
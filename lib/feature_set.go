@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"sort"
 	"strings"
 
 	"github.com/missinglink/gosmparse"
@@ -41,12 +42,37 @@ func NewFeatureSetFromJSON(path string) (*FeatureSet, error) {
 	var config Config
 	decoder.Decode(&config)
 
+	return NewFeatureSetFromConfig(config), nil
+}
+
+// NewFeatureSetFromConfig - create a featureset from an already decoded config
+func NewFeatureSetFromConfig(config Config) *FeatureSet {
 	var fs = &FeatureSet{}
 	fs.NodePatterns = config["node"]
 	fs.WayPatterns = config["way"]
 	fs.RelationPatterns = config["relation"]
 
-	return fs, nil
+	return fs
+}
+
+// Merge - add the patterns of other to this featureset (logical OR)
+func (fs *FeatureSet) Merge(other *FeatureSet) {
+	fs.NodePatterns = append(fs.NodePatterns, other.NodePatterns...)
+	fs.WayPatterns = append(fs.WayPatterns, other.WayPatterns...)
+	fs.RelationPatterns = append(fs.RelationPatterns, other.RelationPatterns...)
+}
+
+// MatchTags - yes/no if any target feature of element type typ matches the tags
+func (fs *FeatureSet) MatchTags(typ string, tags map[string]string) bool {
+	switch typ {
+	case "node":
+		return matchGroup(tags, fs.NodePatterns)
+	case "way":
+		return matchGroup(tags, fs.WayPatterns)
+	case "relation":
+		return matchGroup(tags, fs.RelationPatterns)
+	}
+	return false
 }
 
 // MatchNode - yes/no if any target feature matches this node
@@ -122,3 +148,22 @@ func matchCondition(tags map[string]string, condition Condition) bool {
 
 	return false
 }
+
+// Keys - the unique tag keys referenced by all conditions, in sorted order
+func (fs *FeatureSet) Keys() []string {
+	var set = make(map[string]bool)
+	for _, group := range []Group{fs.NodePatterns, fs.WayPatterns, fs.RelationPatterns} {
+		for _, pattern := range group {
+			for _, condition := range pattern {
+				set[condition.Key()] = true
+			}
+		}
+	}
+
+	var keys = make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
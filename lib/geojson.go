@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sync"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// record separator which prefixes each GeoJSONSeq record, see RFC 8142
+const recordSeparator = 0x1E
+
+// ToGeoJSON - convert a feature to a geojson feature with the element tags
+// as properties, plus 'osm_type' and 'osm_id'
+func ToGeoJSON(f *Feature) *geojson.Feature {
+	var feature = geojson.NewFeature(toGeoJSONGeometry(f.Geometry))
+	feature.ID = fmt.Sprintf("%s/%d", f.Type, f.ID)
+	for k, v := range f.Tags {
+		feature.Properties[k] = v
+	}
+	feature.Properties["osm_type"] = f.Type
+	feature.Properties["osm_id"] = f.ID
+	return feature
+}
+
+// toGeoJSONGeometry - convert a geometry to its geojson equivalent
+func toGeoJSONGeometry(g Geometry) *geojson.Geometry {
+	switch g := g.(type) {
+	case Point:
+		return geojson.NewPointGeometry(roundPosition(g))
+	case LineString:
+		return geojson.NewLineStringGeometry(roundPositions(g))
+	case Polygon:
+		return geojson.NewPolygonGeometry(polygonRings(g))
+	case MultiPolygon:
+		var polygons = make([][][][]float64, len(g))
+		for i, p := range g {
+			polygons[i] = polygonRings(p)
+		}
+		return geojson.NewMultiPolygonGeometry(polygons...)
+	}
+	return nil
+}
+
+// polygonRings - convert a polygon to nested position arrays
+func polygonRings(p Polygon) [][][]float64 {
+	var rings = make([][][]float64, len(p))
+	for i, r := range p {
+		rings[i] = roundPositions(r)
+	}
+	return rings
+}
+
+// roundPositions - round each position to the precision used by WKT
+func roundPositions(positions [][]float64) [][]float64 {
+	var rounded = make([][]float64, len(positions))
+	for i, pos := range positions {
+		rounded[i] = roundPosition(pos)
+	}
+	return rounded
+}
+
+// roundPosition - round a position to the precision used by WKT
+func roundPosition(pos []float64) []float64 {
+	return []float64{math.Round(pos[0]*1e7) / 1e7, math.Round(pos[1]*1e7) / 1e7}
+}
+
+// GeoJSONWriter - write features to stdout as a single FeatureCollection
+// or, when Seq is set, as a GeoJSONSeq stream of one feature per record
+type GeoJSONWriter struct {
+	Writer *BufferedWriter
+	Seq    bool
+
+	mutex    sync.Mutex
+	previous []byte
+}
+
+// NewGeoJSONWriter - constructor, writes the FeatureCollection header
+func NewGeoJSONWriter(seq bool) *GeoJSONWriter {
+	return NewGeoJSONWriterTo(os.Stdout, seq)
+}
+
+// NewGeoJSONWriterTo - constructor, writing to out instead of stdout
+func NewGeoJSONWriterTo(out io.Writer, seq bool) *GeoJSONWriter {
+	var w = &GeoJSONWriter{Writer: NewBufferedWriterTo(out), Seq: seq}
+	if !seq {
+		w.Writer.Queue <- []byte(`{"type":"FeatureCollection","features":[`)
+	}
+	return w
+}
+
+// WriteFeature - encode and queue a single feature
+func (w *GeoJSONWriter) WriteFeature(f *Feature) {
+	bytes, err := json.Marshal(ToGeoJSON(f))
+	if nil != err {
+		log.Printf("failed to encode %s %d: %s\n", f.Type, f.ID, err)
+		return
+	}
+	w.write(bytes)
+}
+
+// WriteGeoJSON - encode and queue a feature which was already converted to
+// geojson
+func (w *GeoJSONWriter) WriteGeoJSON(feature *geojson.Feature) {
+	bytes, err := json.Marshal(feature)
+	if nil != err {
+		log.Printf("failed to encode %v: %s\n", feature.ID, err)
+		return
+	}
+	w.write(bytes)
+}
+
+// write - queue an encoded feature
+func (w *GeoJSONWriter) write(bytes []byte) {
+	if w.Seq {
+		w.Writer.Queue <- append([]byte{recordSeparator}, bytes...)
+		return
+	}
+
+	// features are delayed by one so that the comma separating them can be
+	// omitted after the final feature
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if nil != w.previous {
+		w.Writer.Queue <- append(w.previous, ',')
+	}
+	w.previous = bytes
+}
+
+// Close - write the FeatureCollection footer and flush
+func (w *GeoJSONWriter) Close() {
+	if !w.Seq {
+		w.mutex.Lock()
+		if nil != w.previous {
+			w.Writer.Queue <- w.previous
+		}
+		w.Writer.Queue <- []byte(`]}`)
+		w.mutex.Unlock()
+	}
+	w.Writer.Close()
+}
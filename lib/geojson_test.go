@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToGeoJSON(t *testing.T) {
+	var feature = ToGeoJSON(&Feature{
+		Type:     "way",
+		ID:       103,
+		Tags:     map[string]string{"building": "yes", "osm_id": "1"},
+		Geometry: Polygon{Ring{{1.000000001, 1}, {2, 1}, {2, 2}, {1, 1}}},
+	})
+
+	assert.Equal(t, "way/103", feature.ID)
+	assert.Equal(t, "way", feature.Properties["osm_type"])
+	assert.Equal(t, int64(103), feature.Properties["osm_id"])
+	assert.Equal(t, "yes", feature.Properties["building"])
+
+	bytes, err := json.Marshal(feature.Geometry)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type":"Polygon","coordinates":[[[1,1],[2,1],[2,2],[1,1]]]}`, string(bytes))
+}
+
+func TestToGeoJSONMultiPolygon(t *testing.T) {
+	var square = Ring{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+	var feature = ToGeoJSON(&Feature{
+		Type:     "relation",
+		ID:       1,
+		Geometry: MultiPolygon{Polygon{square}, Polygon{square}},
+	})
+
+	bytes, err := json.Marshal(feature.Geometry)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[0,0],[1,0],[1,1],[0,0]]]]}`, string(bytes))
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxInterpolatedHouseNumbers - interpolations spanning more house numbers
+// than this are assumed to be tagging errors and are not expanded
+const MaxInterpolatedHouseNumbers = 1000
+
+var houseNumberPattern = regexp.MustCompile(`^(\d+)\s*([a-zA-Z]?)$`)
+
+// HouseNumber - a house number split in to its number and letter suffix,
+// eg. 12b
+type HouseNumber struct {
+	Number int
+	Suffix string
+}
+
+// ParseHouseNumber - parse a simple house number such as '12' or '12b',
+// other forms such as ranges or lists are not supported
+func ParseHouseNumber(value string) (HouseNumber, bool) {
+	var match = houseNumberPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if nil == match {
+		return HouseNumber{}, false
+	}
+	number, err := strconv.Atoi(match[1])
+	if nil != err {
+		return HouseNumber{}, false
+	}
+	return HouseNumber{Number: number, Suffix: match[2]}, true
+}
+
+// String - the house number as it would be tagged
+func (h HouseNumber) String() string {
+	return strconv.Itoa(h.Number) + h.Suffix
+}
+
+// InterpolateHouseNumbers - the house numbers strictly between from and to
+// for an addr:interpolation scheme, one of odd, even, all, alphabetic or a
+// numeric step. returns nil when the scheme is not supported or the
+// endpoints cannot be interpolated.
+func InterpolateHouseNumbers(from, to HouseNumber, scheme string) []HouseNumber {
+	switch scheme {
+	case "odd", "even", "all":
+		return interpolateNumbers(from.Number, to.Number, 1, func(n int) bool {
+			return "all" == scheme || (n%2 == 1) == ("odd" == scheme)
+		})
+	case "alphabetic":
+		return interpolateLetters(from, to)
+	}
+
+	// a numeric step, counted from the first house number
+	step, err := strconv.Atoi(scheme)
+	if nil != err || step < 1 {
+		return nil
+	}
+	return interpolateNumbers(from.Number, to.Number, step, func(n int) bool { return true })
+}
+
+// interpolateNumbers - numbers strictly between from and to, in the
+// direction of to, which are a multiple of step from from and match keep
+func interpolateNumbers(from, to, step int, keep func(n int) bool) []HouseNumber {
+	var dir = 1
+	if to < from {
+		dir = -1
+	}
+	if (to-from)*dir/step > MaxInterpolatedHouseNumbers {
+		return nil
+	}
+
+	var numbers []HouseNumber
+	for n := from + step*dir; n*dir < to*dir; n += step * dir {
+		if keep(n) {
+			numbers = append(numbers, HouseNumber{Number: n})
+		}
+	}
+	return numbers
+}
+
+// interpolateLetters - the suffixes strictly between from and to for the
+// same number, eg. 12a to 12d gives 12b and 12c. a missing suffix comes
+// before 'a', the case of the endpoint suffixes is kept.
+func interpolateLetters(from, to HouseNumber) []HouseNumber {
+	if from.Number != to.Number {
+		return nil
+	}
+
+	var upper = unicode.IsUpper(firstRune(from.Suffix)) || unicode.IsUpper(firstRune(to.Suffix))
+	var letter = func(suffix string) int {
+		if "" == suffix {
+			return 'a' - 1
+		}
+		return int(unicode.ToLower(firstRune(suffix)))
+	}
+
+	var start, end = letter(from.Suffix), letter(to.Suffix)
+	var dir = 1
+	if end < start {
+		dir = -1
+	}
+
+	var numbers []HouseNumber
+	for l := start + dir; l*dir < end*dir; l += dir {
+		var suffix = string(rune(l))
+		if upper {
+			suffix = strings.ToUpper(suffix)
+		}
+		if l >= 'a' {
+			numbers = append(numbers, HouseNumber{Number: from.Number, Suffix: suffix})
+		}
+	}
+	return numbers
+}
+
+// firstRune - the first rune of a string, 0 if empty
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// housenumbers - the string form of each house number
+func housenumbers(numbers []HouseNumber) []string {
+	var strs = []string{}
+	for _, number := range numbers {
+		strs = append(strs, number.String())
+	}
+	return strs
+}
+
+func TestParseHouseNumber(t *testing.T) {
+
+	var h, ok = ParseHouseNumber(" 12b ")
+	assert.True(t, ok)
+	assert.Equal(t, HouseNumber{Number: 12, Suffix: "b"}, h)
+	assert.Equal(t, "12b", h.String())
+
+	h, ok = ParseHouseNumber("7")
+	assert.True(t, ok)
+	assert.Equal(t, HouseNumber{Number: 7}, h)
+
+	_, ok = ParseHouseNumber("12-14")
+	assert.False(t, ok)
+	_, ok = ParseHouseNumber("")
+	assert.False(t, ok)
+}
+
+func TestInterpolateHouseNumbers(t *testing.T) {
+
+	var parse = func(value string) HouseNumber {
+		h, _ := ParseHouseNumber(value)
+		return h
+	}
+
+	assert.Equal(t, []string{"3", "5", "7"}, housenumbers(InterpolateHouseNumbers(parse("1"), parse("9"), "odd")))
+	assert.Equal(t, []string{"8", "6"}, housenumbers(InterpolateHouseNumbers(parse("10"), parse("4"), "even")))
+	assert.Equal(t, []string{"4", "6"}, housenumbers(InterpolateHouseNumbers(parse("3"), parse("7"), "even")))
+	assert.Equal(t, []string{"2", "3"}, housenumbers(InterpolateHouseNumbers(parse("1"), parse("4"), "all")))
+	assert.Equal(t, []string{"4", "7"}, housenumbers(InterpolateHouseNumbers(parse("1"), parse("10"), "3")))
+	assert.Equal(t, []string{"5b", "5c"}, housenumbers(InterpolateHouseNumbers(parse("5a"), parse("5d"), "alphabetic")))
+	assert.Equal(t, []string{"5A", "5B"}, housenumbers(InterpolateHouseNumbers(parse("5"), parse("5C"), "alphabetic")))
+	assert.Equal(t, []string{"5b"}, housenumbers(InterpolateHouseNumbers(parse("5c"), parse("5a"), "alphabetic")))
+
+	assert.Nil(t, InterpolateHouseNumbers(parse("5a"), parse("6c"), "alphabetic"))
+	assert.Nil(t, InterpolateHouseNumbers(parse("1"), parse("9"), "unknown"))
+	assert.Nil(t, InterpolateHouseNumbers(parse("1"), parse("99999"), "all"))
+}
@@ -0,0 +1,225 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// reasons reported when a relation cannot be assembled in to a multipolygon
+const (
+	ReasonNoMembers   = "no member ways"
+	ReasonMissingWay  = "member way not found"
+	ReasonMissingNode = "member way node location not found"
+	ReasonUnclosed    = "member ways do not form closed rings"
+	ReasonNoOuter     = "no outer rings"
+)
+
+// AreaMember - a member way of a multipolygon or boundary relation
+type AreaMember struct {
+	ID   int64
+	Role string
+	Refs []int64 // nil when the way could not be found
+}
+
+// AssemblyError - explains why a relation is not a valid multipolygon
+type AssemblyError struct {
+	Reason string
+	IDs    []int64 // ways or nodes causing the problem
+}
+
+// Error - implements error
+func (e *AssemblyError) Error() string {
+	if len(e.IDs) == 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %v", e.Reason, e.IDs)
+}
+
+// LocateFunc - load the [lon, lat] location of a node
+type LocateFunc func(id int64) ([]float64, bool)
+
+// AssembleMultiPolygon - stitch member ways in to closed rings and build a
+// multipolygon, outer and inner rings are determined by how deeply they are
+// nested so that missing or incorrect roles and misordered members are
+// tolerated. outer rings are wound counterclockwise and inner rings
+// clockwise as per RFC 7946.
+func AssembleMultiPolygon(members []AreaMember, locate LocateFunc) (MultiPolygon, error) {
+	if len(members) == 0 {
+		return nil, &AssemblyError{Reason: ReasonNoMembers}
+	}
+
+	// group ways by role, ways without a valid role are treated as outers
+	var missing []int64
+	var outer, inner, all [][]int64
+	for _, member := range members {
+		if len(member.Refs) < 2 {
+			missing = append(missing, member.ID)
+			continue
+		}
+		if "inner" == member.Role {
+			inner = append(inner, member.Refs)
+		} else {
+			outer = append(outer, member.Refs)
+		}
+		all = append(all, member.Refs)
+	}
+	if len(missing) > 0 {
+		return nil, &AssemblyError{Reason: ReasonMissingWay, IDs: missing}
+	}
+
+	// stitch each role separately so that inner rings touching an outer ring
+	// are kept apart, fall back to ignoring roles when they are incorrect
+	outerRefs, outerUnclosed := StitchRings(outer)
+	innerRefs, innerUnclosed := StitchRings(inner)
+	var refs = append(outerRefs, innerRefs...)
+	if len(outerUnclosed) > 0 || len(innerUnclosed) > 0 {
+		var unclosed [][]int64
+		refs, unclosed = StitchRings(all)
+		if len(unclosed) > 0 {
+			var ends []int64
+			for _, way := range unclosed {
+				ends = append(ends, way[0], way[len(way)-1])
+			}
+			return nil, &AssemblyError{Reason: ReasonUnclosed, IDs: ends}
+		}
+	}
+
+	// load locations
+	var rings = make([]Ring, 0, len(refs))
+	for _, ring := range refs {
+		var coords = make(Ring, 0, len(ring))
+		for _, id := range ring {
+			pos, ok := locate(id)
+			if !ok {
+				missing = append(missing, id)
+				continue
+			}
+			coords = append(coords, pos)
+		}
+		rings = append(rings, coords)
+	}
+	if len(missing) > 0 {
+		return nil, &AssemblyError{Reason: ReasonMissingNode, IDs: missing}
+	}
+
+	var mp = NestRings(rings)
+	if len(mp) == 0 {
+		return nil, &AssemblyError{Reason: ReasonNoOuter}
+	}
+	return mp, nil
+}
+
+// NestRings - build a multipolygon from closed rings, rings nested an even
+// number of levels deep are outers and the others are holes in their parent.
+// rings without any area are discarded.
+func NestRings(rings []Ring) MultiPolygon {
+
+	// sort largest first so that parents always precede their children
+	type nested struct {
+		*ringIndex
+		area   float64
+		parent int
+		depth  int
+		index  int // position of the polygon in the multipolygon
+	}
+	var items []*nested
+	for _, ring := range rings {
+		if area := math.Abs(ring.SignedArea()); area > 0 {
+			items = append(items, &nested{ringIndex: newRingIndex(ring), area: area, parent: -1})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].area > items[j].area })
+
+	var mp MultiPolygon
+	for i, item := range items {
+
+		// the smallest ring containing this one is its parent
+		for j := i - 1; j >= 0; j-- {
+			if items[j].containsRing(item.ringIndex) {
+				item.parent = j
+				item.depth = items[j].depth + 1
+				break
+			}
+		}
+
+		if item.depth%2 == 0 {
+			item.index = len(mp)
+			mp = append(mp, Polygon{item.ring.Wind(true)})
+			continue
+		}
+		var parent = items[item.parent]
+		mp[parent.index] = append(mp[parent.index], item.ring.Wind(false))
+	}
+
+	return mp
+}
+
+// SignedArea - shoelace formula, positive for counterclockwise rings
+func (r Ring) SignedArea() float64 {
+	var sum float64
+	for i := 0; i+1 < len(r); i++ {
+		sum += r[i][0]*r[i+1][1] - r[i+1][0]*r[i][1]
+	}
+	return sum / 2
+}
+
+// Wind - copy of the ring wound counterclockwise or clockwise
+func (r Ring) Wind(counterclockwise bool) Ring {
+	if (r.SignedArea() > 0) == counterclockwise {
+		return r
+	}
+	var w = make(Ring, len(r))
+	for i, pos := range r {
+		w[len(r)-1-i] = pos
+	}
+	return w
+}
+
+// ContainsRing - yes/no if the other ring lies inside this one, tested
+// using the first vertex of other which is not shared with this ring so
+// that touching rings are handled
+func (r Ring) ContainsRing(other Ring) bool {
+	return newRingIndex(r).containsRing(newRingIndex(other))
+}
+
+// ringIndex - a ring with its bounds and the set of its vertices, built
+// once so that a ring can be tested against many others
+type ringIndex struct {
+	ring     Ring
+	bounds   *BBox
+	vertices map[[2]float64]bool
+}
+
+// newRingIndex - constructor
+func newRingIndex(ring Ring) *ringIndex {
+	var index = &ringIndex{
+		ring:     ring,
+		bounds:   NewEmptyBBox(),
+		vertices: make(map[[2]float64]bool, len(ring)),
+	}
+	for _, pos := range ring {
+		index.bounds.Extend(pos[0], pos[1])
+		index.vertices[[2]float64{pos[0], pos[1]}] = true
+	}
+	return index
+}
+
+// containsRing - see Ring.ContainsRing, rings whose bounds do not fit
+// inside this ring's bounds are rejected without a point in ring test
+func (r *ringIndex) containsRing(other *ringIndex) bool {
+	if !r.bounds.ContainsBBox(other.bounds) {
+		return false
+	}
+	for _, pos := range other.ring {
+		if !r.vertices[[2]float64{pos[0], pos[1]}] {
+			return r.ring.Contains(pos[0], pos[1])
+		}
+	}
+
+	// all vertices shared, fall back to the midpoint of the first segment
+	if len(other.ring) < 2 {
+		return false
+	}
+	return r.ring.Contains((other.ring[0][0]+other.ring[1][0])/2, (other.ring[0][1]+other.ring[1][1])/2)
+}
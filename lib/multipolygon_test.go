@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// grid of node locations, id = x*100 + y
+func gridLocate(id int64) ([]float64, bool) {
+	if id < 0 {
+		return nil, false
+	}
+	return []float64{float64(id / 100), float64(id % 100)}, true
+}
+
+func TestAssembleMultiPolygon(t *testing.T) {
+
+	// square split over two ways, one reversed, with a hole
+	var mp, err = AssembleMultiPolygon([]AreaMember{
+		{ID: 1, Role: "outer", Refs: []int64{0, 1000, 1010}},
+		{ID: 2, Role: "outer", Refs: []int64{0, 10, 1010}},
+		{ID: 3, Role: "inner", Refs: []int64{202, 208, 808, 802, 202}},
+	}, gridLocate)
+	assert.Nil(t, err)
+	assert.Equal(t, "MULTIPOLYGON(((0 0,10 0,10 10,0 10,0 0),(2 2,2 8,8 8,8 2,2 2)))", mp.WKT())
+}
+
+func TestAssembleMultiPolygonRoles(t *testing.T) {
+	var expected = "MULTIPOLYGON(((0 0,10 0,10 10,0 10,0 0),(2 2,2 8,8 8,8 2,2 2)))"
+
+	// role-less members, inner listed first
+	var mp, err = AssembleMultiPolygon([]AreaMember{
+		{ID: 3, Refs: []int64{202, 802, 808, 208, 202}},
+		{ID: 1, Refs: []int64{0, 1000, 1010, 10, 0}},
+	}, gridLocate)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, mp.WKT())
+
+	// incorrect roles
+	mp, err = AssembleMultiPolygon([]AreaMember{
+		{ID: 1, Role: "inner", Refs: []int64{0, 1000, 1010}},
+		{ID: 2, Role: "outer", Refs: []int64{1010, 10, 0}},
+		{ID: 3, Role: "outer", Refs: []int64{202, 802, 808, 208, 202}},
+	}, gridLocate)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, mp.WKT())
+}
+
+func TestAssembleMultiPolygonIsland(t *testing.T) {
+
+	// island inside a lake inside a park becomes a second polygon
+	var mp, err = AssembleMultiPolygon([]AreaMember{
+		{ID: 1, Role: "outer", Refs: []int64{0, 1000, 1010, 10, 0}},
+		{ID: 2, Role: "inner", Refs: []int64{202, 802, 808, 208, 202}},
+		{ID: 3, Role: "outer", Refs: []int64{404, 604, 606, 406, 404}},
+	}, gridLocate)
+	assert.Nil(t, err)
+	assert.Len(t, mp, 2)
+	assert.Len(t, mp[0], 2)
+	assert.Equal(t, "POLYGON((4 4,6 4,6 6,4 6,4 4))", mp[1].WKT())
+}
+
+func TestAssembleMultiPolygonTouching(t *testing.T) {
+
+	// inner ring shares a vertex with the outer ring
+	var mp, err = AssembleMultiPolygon([]AreaMember{
+		{ID: 1, Role: "outer", Refs: []int64{0, 1000, 1010, 10, 0}},
+		{ID: 2, Role: "inner", Refs: []int64{0, 505, 500, 0}},
+	}, gridLocate)
+	assert.Nil(t, err)
+	assert.Len(t, mp, 1)
+	assert.Len(t, mp[0], 2)
+}
+
+func TestAssembleMultiPolygonInvalid(t *testing.T) {
+	var assertReason = func(reason string, ids []int64, members []AreaMember) {
+		var _, err = AssembleMultiPolygon(members, gridLocate)
+		if assert.IsType(t, &AssemblyError{}, err) {
+			assert.Equal(t, reason, err.(*AssemblyError).Reason)
+			assert.Equal(t, ids, err.(*AssemblyError).IDs)
+		}
+	}
+
+	assertReason(ReasonNoMembers, nil, nil)
+	assertReason(ReasonMissingWay, []int64{2}, []AreaMember{
+		{ID: 1, Refs: []int64{0, 1000, 1010}},
+		{ID: 2},
+	})
+	assertReason(ReasonUnclosed, []int64{0, 10}, []AreaMember{
+		{ID: 1, Refs: []int64{0, 1000, 1010}},
+		{ID: 2, Refs: []int64{1010, 10}},
+	})
+	assertReason(ReasonMissingNode, []int64{-1}, []AreaMember{
+		{ID: 1, Refs: []int64{0, 1000, -1, 0}},
+	})
+	assertReason(ReasonNoOuter, nil, []AreaMember{
+		{ID: 1, Refs: []int64{0, 1000, 2000, 0}},
+	})
+}
+
+func TestRingWind(t *testing.T) {
+	var cw = Ring{{0, 0}, {0, 1}, {1, 1}, {0, 0}}
+	assert.True(t, cw.SignedArea() < 0)
+	assert.True(t, cw.Wind(true).SignedArea() > 0)
+	assert.Equal(t, cw, cw.Wind(false))
+}
+
+func TestRingContainsRing(t *testing.T) {
+	var outer = Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	assert.True(t, outer.ContainsRing(Ring{{2, 2}, {4, 2}, {4, 4}, {2, 2}}))
+	assert.True(t, outer.ContainsRing(Ring{{0, 0}, {5, 5}, {5, 0}, {0, 0}}))
+
+	// outside the bounds, overlapping the bounds but not contained
+	assert.False(t, outer.ContainsRing(Ring{{20, 20}, {21, 20}, {21, 21}, {20, 20}}))
+	assert.False(t, outer.ContainsRing(Ring{{5, 5}, {15, 5}, {15, 15}, {5, 5}}))
+	assert.False(t, Ring{{2, 2}, {4, 2}, {4, 4}, {2, 2}}.ContainsRing(outer))
+}
@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"math"
+	"sort"
+)
+
+// Ring - a closed linear ring of [lon, lat] positions
+type Ring [][]float64
+
+// Polygon - an outer ring followed by zero or more inner rings (holes)
+type Polygon []Ring
+
+// MultiPolygon - a collection of polygons
+type MultiPolygon []Polygon
+
+// Contains - point-in-ring test using the even-odd ray casting rule
+func (r Ring) Contains(lon float64, lat float64) bool {
+	var inside = false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		var xi, yi = r[i][0], r[i][1]
+		var xj, yj = r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Contains - point must be inside the outer ring and outside all holes
+func (p Polygon) Contains(lon float64, lat float64) bool {
+	if len(p) == 0 || !p[0].Contains(lon, lat) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.Contains(lon, lat) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains - point must be inside at least one polygon
+func (m MultiPolygon) Contains(lon float64, lat float64) bool {
+	for _, p := range m {
+		if p.Contains(lon, lat) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bounds - the bounding box of all positions in the multipolygon
+func (m MultiPolygon) Bounds() *BBox {
+	var b = NewEmptyBBox()
+	for _, p := range m {
+		for _, r := range p {
+			for _, pos := range r {
+				b.Extend(pos[0], pos[1])
+			}
+		}
+	}
+	return b
+}
+
+// InteriorPoint - a point guaranteed to be inside the largest polygon,
+// found by taking the midpoint of the widest span of a horizontal line
+// through the middle of its outer ring. returns false for empty geometries.
+func (m MultiPolygon) InteriorPoint() ([]float64, bool) {
+	var largest Polygon
+	var largestArea float64
+	for _, p := range m {
+		if len(p) == 0 {
+			continue
+		}
+		if area := math.Abs(p[0].SignedArea()); nil == largest || area > largestArea {
+			largest, largestArea = p, area
+		}
+	}
+	if len(largest) == 0 || len(largest[0]) < 4 {
+		return nil, false
+	}
+
+	// crossings of the horizontal line with every ring
+	var bounds = largest.Bounds()
+	var lat = (bounds.MinLat + bounds.MaxLat) / 2
+	var crossings []float64
+	for _, r := range largest {
+		for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+			var xi, yi = r[i][0], r[i][1]
+			var xj, yj = r[j][0], r[j][1]
+			if (yi > lat) != (yj > lat) {
+				crossings = append(crossings, (xj-xi)*(lat-yi)/(yj-yi)+xi)
+			}
+		}
+	}
+	sort.Float64s(crossings)
+
+	// spans between pairs of crossings are inside the polygon
+	var best = -1.0
+	var point []float64
+	for i := 0; i+1 < len(crossings); i += 2 {
+		if width := crossings[i+1] - crossings[i]; width > best {
+			best = width
+			point = []float64{(crossings[i] + crossings[i+1]) / 2, lat}
+		}
+	}
+	return point, nil != point
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// Region - an area which can answer point-in-area queries
+type Region interface {
+	Contains(lon float64, lat float64) bool
+}
+
+// BBox - an axis aligned bounding box in degrees
+type BBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// NewEmptyBBox - constructor for a box which contains nothing until extended
+func NewEmptyBBox() *BBox {
+	return &BBox{
+		MinLon: math.Inf(1),
+		MinLat: math.Inf(1),
+		MaxLon: math.Inf(-1),
+		MaxLat: math.Inf(-1),
+	}
+}
+
+// ParseBBox - parse a bbox from a 'minlon,minlat,maxlon,maxlat' string
+func ParseBBox(str string) (*BBox, error) {
+	var parts = strings.Split(str, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("bbox must be in the format: minlon,minlat,maxlon,maxlat")
+	}
+
+	var coords = make([]float64, 4)
+	for i, part := range parts {
+		val, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if nil != err {
+			return nil, fmt.Errorf("invalid bbox coordinate: %s", part)
+		}
+		coords[i] = val
+	}
+
+	var b = &BBox{MinLon: coords[0], MinLat: coords[1], MaxLon: coords[2], MaxLat: coords[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return nil, errors.New("bbox min values must be less than max values")
+	}
+
+	return b, nil
+}
+
+// Contains - yes/no if the point lies within the box (edges inclusive)
+func (b *BBox) Contains(lon float64, lat float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// Extend - grow the box to include the point
+func (b *BBox) Extend(lon float64, lat float64) {
+	b.MinLon = math.Min(b.MinLon, lon)
+	b.MinLat = math.Min(b.MinLat, lat)
+	b.MaxLon = math.Max(b.MaxLon, lon)
+	b.MaxLat = math.Max(b.MaxLat, lat)
+}
+
+// ExtendBBox - grow the box to include another box
+func (b *BBox) ExtendBBox(o *BBox) {
+	b.Extend(o.MinLon, o.MinLat)
+	b.Extend(o.MaxLon, o.MaxLat)
+}
+
+// Intersects - yes/no if the two boxes overlap (edges inclusive)
+func (b *BBox) Intersects(o *BBox) bool {
+	return b.MinLon <= o.MaxLon && b.MaxLon >= o.MinLon && b.MinLat <= o.MaxLat && b.MaxLat >= o.MinLat
+}
+
+// ContainsBBox - yes/no if the other box lies within this one (edges inclusive)
+func (b *BBox) ContainsBBox(o *BBox) bool {
+	return b.MinLon <= o.MinLon && b.MaxLon >= o.MaxLon && b.MinLat <= o.MinLat && b.MaxLat >= o.MaxLat
+}
+
+// PolygonRegion - a multipolygon with a precomputed bbox to skip the
+// more expensive ring tests for points which are clearly outside
+type PolygonRegion struct {
+	Polygons MultiPolygon
+	Bounds   *BBox
+}
+
+// Contains - yes/no if the point lies within any of the polygons
+func (r *PolygonRegion) Contains(lon float64, lat float64) bool {
+	if !r.Bounds.Contains(lon, lat) {
+		return false
+	}
+	return r.Polygons.Contains(lon, lat)
+}
+
+// NewPolygonRegionFromGeoJSON - load a region from a GeoJSON file containing
+// a Polygon or MultiPolygon as a bare geometry, a Feature or a FeatureCollection
+func NewPolygonRegionFromGeoJSON(path string) (*PolygonRegion, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	// collect geometries from whichever container was provided
+	var geometries []*geojson.Geometry
+	if fc, err := geojson.UnmarshalFeatureCollection(data); nil == err && len(fc.Features) > 0 {
+		for _, f := range fc.Features {
+			geometries = append(geometries, f.Geometry)
+		}
+	} else if f, err := geojson.UnmarshalFeature(data); nil == err && nil != f.Geometry {
+		geometries = append(geometries, f.Geometry)
+	} else if g, err := geojson.UnmarshalGeometry(data); nil == err {
+		geometries = append(geometries, g)
+	}
+
+	var polygons MultiPolygon
+	for _, g := range geometries {
+		if nil == g {
+			continue
+		}
+		switch {
+		case g.IsPolygon():
+			polygons = append(polygons, toPolygon(g.Polygon))
+		case g.IsMultiPolygon():
+			for _, p := range g.MultiPolygon {
+				polygons = append(polygons, toPolygon(p))
+			}
+		}
+	}
+
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("no polygon geometries found in %s", path)
+	}
+
+	return &PolygonRegion{
+		Polygons: polygons,
+		Bounds:   polygons.Bounds(),
+	}, nil
+}
+
+// convert a raw geojson coordinate array to a Polygon
+func toPolygon(rings [][][]float64) Polygon {
+	var p = make(Polygon, 0, len(rings))
+	for _, r := range rings {
+		p = append(p, Ring(r))
+	}
+	return p
+}
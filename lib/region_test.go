@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBBox(t *testing.T) {
+
+	var b, err = ParseBBox("108.1, 16.0,108.5,16.4")
+	assert.Nil(t, err)
+	assert.Equal(t, &BBox{MinLon: 108.1, MinLat: 16.0, MaxLon: 108.5, MaxLat: 16.4}, b)
+
+	assert.True(t, b.Contains(108.1, 16.0))
+	assert.True(t, b.Contains(108.3, 16.2))
+	assert.False(t, b.Contains(108.6, 16.2))
+
+	_, err = ParseBBox("1,2,3")
+	assert.NotNil(t, err)
+
+	_, err = ParseBBox("3,2,1,4")
+	assert.NotNil(t, err)
+}
+
+func TestPolygonContains(t *testing.T) {
+
+	var poly = Polygon{
+		Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		Ring{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+
+	assert.True(t, poly.Contains(1, 1))
+	assert.True(t, poly.Contains(9, 5))
+	assert.False(t, poly.Contains(5, 5)) // in hole
+	assert.False(t, poly.Contains(11, 5))
+
+	var multi = MultiPolygon{poly, Polygon{Ring{{20, 20}, {30, 20}, {30, 30}, {20, 20}}}}
+	assert.True(t, multi.Contains(28, 22))
+	assert.False(t, multi.Contains(5, 5))
+
+	var region = &PolygonRegion{Polygons: multi, Bounds: multi.Bounds()}
+	assert.Equal(t, &BBox{MinLon: 0, MinLat: 0, MaxLon: 30, MaxLat: 30}, region.Bounds)
+	assert.True(t, region.Contains(1, 1))
+	assert.False(t, region.Contains(-1, 1))
+}
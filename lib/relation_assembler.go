@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"log"
 	"github.com/missinglink/pbf/json"
-	"github.com/missinglink/pbf/leveldb"
+	"github.com/missinglink/pbf/location"
 	"sync"
 
 	"github.com/missinglink/gosmparse"
@@ -15,8 +15,9 @@ const MAX_MEMBER_RELATIONS = 65535
 
 // RelationAssembler - struct to handle assembling relation dependencies
 type RelationAssembler struct {
-	Relation *gosmparse.Relation
-	Conn     *leveldb.Connection
+	Relation  *gosmparse.Relation
+	Conn      ElementStore
+	Locations location.Store // optional, read way refs from here instead of Conn
 }
 
 // GenerateJSON - generate a byte buffer of elements encoded in json, one-per-line
@@ -96,7 +97,7 @@ func writeRelation(buffer *bytes.Buffer, a *RelationAssembler, relations *[]*gos
 				// refs
 				for _, nodeid := range way.NodeIDs {
 
-					if node, _ := a.Conn.ReadNode(nodeid); nil != node {
+					if node, _ := a.readRef(nodeid); nil != node {
 
 						// clear tags
 						node.Tags = make(map[string]string)
@@ -135,3 +136,61 @@ func writeRelation(buffer *bytes.Buffer, a *RelationAssembler, relations *[]*gos
 		}
 	}
 }
+
+// readRef - read a way ref from the location store when one is available
+func (a *RelationAssembler) readRef(id int64) (*gosmparse.Node, error) {
+	if nil != a.Locations {
+		return a.Locations.ReadCoord(id)
+	}
+	return a.Conn.ReadNode(id)
+}
+
+// MultiPolygon - assemble the member ways of the relation and its child
+// relations (excluding subareas) in to a multipolygon
+func (a *RelationAssembler) MultiPolygon() (MultiPolygon, error) {
+	var members []AreaMember
+	var seen = map[int64]bool{a.Relation.ID: true}
+	var relations = []*gosmparse.Relation{a.Relation}
+
+	for current := 0; current < len(relations); current++ {
+		for _, mem := range relations[current].Members {
+			switch mem.Type {
+			case gosmparse.WayType:
+				var member = AreaMember{ID: mem.ID, Role: mem.Role}
+				if way, _ := a.Conn.ReadWay(mem.ID); nil != way {
+					member.Refs = way.NodeIDs
+				}
+				members = append(members, member)
+
+			case gosmparse.RelationType:
+				if mem.Role == "subarea" || seen[mem.ID] || len(relations) >= MAX_MEMBER_RELATIONS {
+					continue
+				}
+				seen[mem.ID] = true
+				if rel, _ := a.Conn.ReadRelation(mem.ID); nil != rel {
+					relations = append(relations, rel)
+				}
+			}
+		}
+	}
+
+	return AssembleMultiPolygon(members, func(id int64) ([]float64, bool) {
+		node, err := a.readRef(id)
+		if nil != err || nil == node {
+			return nil, false
+		}
+		return []float64{node.Lon, node.Lat}, true
+	})
+}
+
+// Subareas - ids of member relations with the subarea role, these are
+// skipped when assembling but hint at the admin hierarchy
+func (a *RelationAssembler) Subareas() []int64 {
+	var ids []int64
+	for _, mem := range a.Relation.Members {
+		if gosmparse.RelationType == mem.Type && "subarea" == mem.Role {
+			ids = append(ids, mem.ID)
+		}
+	}
+	return ids
+}
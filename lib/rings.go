@@ -0,0 +1,69 @@
+package lib
+
+// StitchRings - join ways (as sequences of node ids) end-to-end in to closed
+// rings, reversing ways where required. ways which cannot be closed are
+// returned as unclosed.
+func StitchRings(ways [][]int64) (rings [][]int64, unclosed [][]int64) {
+
+	var open [][]int64
+	for _, way := range ways {
+		if len(way) < 2 {
+			continue
+		}
+		if isClosed(way) {
+			rings = append(rings, way)
+			continue
+		}
+		open = append(open, way)
+	}
+
+	for len(open) > 0 {
+
+		// start a new ring with the first remaining way
+		var current = append([]int64{}, open[0]...)
+		open = open[1:]
+
+		for !isClosed(current) {
+			var found = false
+			var last = current[len(current)-1]
+			for i, way := range open {
+				switch last {
+				case way[0]:
+					current = append(current, way[1:]...)
+				case way[len(way)-1]:
+					current = append(current, reverse(way)[1:]...)
+				default:
+					continue
+				}
+				open = append(open[:i], open[i+1:]...)
+				found = true
+				break
+			}
+			if !found {
+				break
+			}
+		}
+
+		if isClosed(current) {
+			rings = append(rings, current)
+		} else {
+			unclosed = append(unclosed, current)
+		}
+	}
+
+	return rings, unclosed
+}
+
+// isClosed - yes/no if the sequence forms a closed ring
+func isClosed(refs []int64) bool {
+	return len(refs) >= 4 && refs[0] == refs[len(refs)-1]
+}
+
+// reverse - copy of the refs in reverse order
+func reverse(refs []int64) []int64 {
+	var r = make([]int64, len(refs))
+	for i, ref := range refs {
+		r[len(refs)-1-i] = ref
+	}
+	return r
+}
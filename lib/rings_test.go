@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStitchRings(t *testing.T) {
+
+	// closed way
+	rings, unclosed := StitchRings([][]int64{{1, 2, 3, 1}})
+	assert.Equal(t, [][]int64{{1, 2, 3, 1}}, rings)
+	assert.Nil(t, unclosed)
+
+	// ways joined end-to-end, one reversed
+	rings, unclosed = StitchRings([][]int64{{1, 2, 3}, {5, 4, 3}, {5, 6, 1}})
+	assert.Equal(t, [][]int64{{1, 2, 3, 4, 5, 6, 1}}, rings)
+	assert.Nil(t, unclosed)
+
+	// ways which don't close
+	rings, unclosed = StitchRings([][]int64{{1, 2, 3}, {3, 4}})
+	assert.Nil(t, rings)
+	assert.Equal(t, [][]int64{{1, 2, 3, 4}}, unclosed)
+}
+
+func TestGeometryWKT(t *testing.T) {
+	assert.Equal(t, "POINT(108.46 16.501)", Point{108.46000000000001, 16.501}.WKT())
+	assert.Equal(t, "LINESTRING(1 2,3 4)", LineString{{1, 2}, {3, 4}}.WKT())
+	assert.Equal(t, "POLYGON((0 0,1 0,1 1,0 0))", Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}.WKT())
+}
+
+func TestIsArea(t *testing.T) {
+	assert.True(t, IsArea(map[string]string{"building": "yes"}))
+	assert.True(t, IsArea(map[string]string{"highway": "pedestrian", "area": "yes"}))
+	assert.True(t, IsArea(map[string]string{"waterway": "riverbank"}))
+	assert.False(t, IsArea(map[string]string{"highway": "residential"}))
+	assert.False(t, IsArea(map[string]string{"natural": "coastline"}))
+	assert.False(t, IsArea(map[string]string{"waterway": "stream"}))
+	assert.False(t, IsArea(map[string]string{"building": "yes", "area": "no"}))
+}
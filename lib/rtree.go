@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"math"
+	"sort"
+)
+
+// maximum number of children per rtree node
+const rtreeNodeSize = 16
+
+// RTreeItem - a value and its bounding box
+type RTreeItem struct {
+	Bounds *BBox
+	Value  interface{}
+}
+
+// RTree - a static rtree, bulk loaded using the Sort-Tile-Recursive
+// algorithm, items cannot be added after construction
+type RTree struct {
+	root *rtreeNode
+	size int
+}
+
+// rtreeNode - internal nodes have children, leaf nodes have items
+type rtreeNode struct {
+	bounds   *BBox
+	children []*rtreeNode
+	items    []RTreeItem
+}
+
+// NewRTree - bulk load items in to a new tree
+func NewRTree(items []RTreeItem) *RTree {
+	var t = &RTree{size: len(items)}
+	if len(items) == 0 {
+		return t
+	}
+
+	// leaf level
+	var sorted = append([]RTreeItem{}, items...)
+	var level []*rtreeNode
+	strTile(len(sorted), func(i, j int) bool {
+		return centre(sorted[i].Bounds, 0) < centre(sorted[j].Bounds, 0)
+	}, func(i, j int) bool {
+		return centre(sorted[i].Bounds, 1) < centre(sorted[j].Bounds, 1)
+	}, func(i, j int) { sorted[i], sorted[j] = sorted[j], sorted[i] }, func(start, end int) {
+		var node = &rtreeNode{bounds: NewEmptyBBox(), items: sorted[start:end]}
+		for _, item := range node.items {
+			node.bounds.ExtendBBox(item.Bounds)
+		}
+		level = append(level, node)
+	})
+
+	// build parent levels until a single root remains
+	for len(level) > 1 {
+		var children = level
+		level = nil
+		strTile(len(children), func(i, j int) bool {
+			return centre(children[i].bounds, 0) < centre(children[j].bounds, 0)
+		}, func(i, j int) bool {
+			return centre(children[i].bounds, 1) < centre(children[j].bounds, 1)
+		}, func(i, j int) { children[i], children[j] = children[j], children[i] }, func(start, end int) {
+			var node = &rtreeNode{bounds: NewEmptyBBox(), children: children[start:end]}
+			for _, child := range node.children {
+				node.bounds.ExtendBBox(child.bounds)
+			}
+			level = append(level, node)
+		})
+	}
+
+	t.root = level[0]
+	return t
+}
+
+// Len - the number of items in the tree
+func (t *RTree) Len() int {
+	return t.size
+}
+
+// Search - call fn for every item whose bounds intersect bbox, stop when
+// fn returns false
+func (t *RTree) Search(bbox *BBox, fn func(item RTreeItem) bool) {
+	if nil != t.root {
+		t.root.search(bbox, fn)
+	}
+}
+
+// SearchPoint - call fn for every item whose bounds contain the point
+func (t *RTree) SearchPoint(lon float64, lat float64, fn func(item RTreeItem) bool) {
+	t.Search(&BBox{MinLon: lon, MinLat: lat, MaxLon: lon, MaxLat: lat}, fn)
+}
+
+// search - returns false when the search was stopped
+func (n *rtreeNode) search(bbox *BBox, fn func(item RTreeItem) bool) bool {
+	if !n.bounds.Intersects(bbox) {
+		return true
+	}
+	for _, child := range n.children {
+		if !child.search(bbox, fn) {
+			return false
+		}
+	}
+	for _, item := range n.items {
+		if item.Bounds.Intersects(bbox) && !fn(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// strTile - sort the entries in to vertical slices by x, then each slice by
+// y, and emit runs of up to rtreeNodeSize entries as nodes
+func strTile(n int, lessX func(i, j int) bool, lessY func(i, j int) bool, swap func(i, j int), emit func(start, end int)) {
+	var nodes = int(math.Ceil(float64(n) / rtreeNodeSize))
+	var slices = int(math.Ceil(math.Sqrt(float64(nodes))))
+	var sliceSize = slices * rtreeNodeSize
+
+	sort.Sort(&sorter{n: n, less: lessX, swap: swap})
+	for start := 0; start < n; start += sliceSize {
+		var end = start + sliceSize
+		if end > n {
+			end = n
+		}
+		sort.Sort(&sorter{offset: start, n: end - start, less: lessY, swap: swap})
+		for i := start; i < end; i += rtreeNodeSize {
+			var j = i + rtreeNodeSize
+			if j > end {
+				j = end
+			}
+			emit(i, j)
+		}
+	}
+}
+
+// sorter - sort.Interface over a sub-range of entries
+type sorter struct {
+	offset, n int
+	less      func(i, j int) bool
+	swap      func(i, j int)
+}
+
+func (s *sorter) Len() int           { return s.n }
+func (s *sorter) Less(i, j int) bool { return s.less(s.offset+i, s.offset+j) }
+func (s *sorter) Swap(i, j int)      { s.swap(s.offset+i, s.offset+j) }
+
+// centre - centre of the bbox on axis 0 (lon) or 1 (lat)
+func centre(b *BBox, axis int) float64 {
+	if axis == 0 {
+		return (b.MinLon + b.MaxLon) / 2
+	}
+	return (b.MinLat + b.MaxLat) / 2
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTreeEmpty(t *testing.T) {
+	var tree = NewRTree(nil)
+	assert.Equal(t, 0, tree.Len())
+	tree.SearchPoint(0, 0, func(item RTreeItem) bool {
+		t.Fail()
+		return true
+	})
+}
+
+func TestRTreeSearch(t *testing.T) {
+	var r = rand.New(rand.NewSource(1))
+	var items []RTreeItem
+	for i := 0; i < 5000; i++ {
+		var lon, lat = r.Float64()*360 - 180, r.Float64()*180 - 90
+		var bbox = &BBox{MinLon: lon, MinLat: lat, MaxLon: lon + r.Float64()*5, MaxLat: lat + r.Float64()*5}
+		items = append(items, RTreeItem{Bounds: bbox, Value: i})
+	}
+	var tree = NewRTree(items)
+	assert.Equal(t, 5000, tree.Len())
+
+	// compare with a brute force search
+	for i := 0; i < 100; i++ {
+		var lon, lat = r.Float64()*360 - 180, r.Float64()*180 - 90
+		var query = &BBox{MinLon: lon, MinLat: lat, MaxLon: lon + 10, MaxLat: lat + 10}
+
+		var expected, actual []int
+		for _, item := range items {
+			if item.Bounds.Intersects(query) {
+				expected = append(expected, item.Value.(int))
+			}
+		}
+		tree.Search(query, func(item RTreeItem) bool {
+			actual = append(actual, item.Value.(int))
+			return true
+		})
+		sort.Ints(actual)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestRTreeSearchStop(t *testing.T) {
+	var items []RTreeItem
+	for i := 0; i < 100; i++ {
+		items = append(items, RTreeItem{Bounds: &BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1}, Value: i})
+	}
+	var count int
+	NewRTree(items).SearchPoint(0.5, 0.5, func(item RTreeItem) bool {
+		count++
+		return count < 3
+	})
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// WKB geometry type codes
+const (
+	wkbPoint        uint32 = 1
+	wkbLineString   uint32 = 2
+	wkbPolygon      uint32 = 3
+	wkbMultiPolygon uint32 = 6
+)
+
+// WKB - well known binary (little endian)
+func (p Point) WKB() []byte {
+	var buf bytes.Buffer
+	writeWKBHeader(&buf, wkbPoint)
+	writeWKBPosition(&buf, p)
+	return buf.Bytes()
+}
+
+// WKB - well known binary (little endian)
+func (l LineString) WKB() []byte {
+	var buf bytes.Buffer
+	writeWKBHeader(&buf, wkbLineString)
+	writeWKBPositions(&buf, l)
+	return buf.Bytes()
+}
+
+// WKB - well known binary (little endian)
+func (p Polygon) WKB() []byte {
+	var buf bytes.Buffer
+	writeWKBPolygon(&buf, p)
+	return buf.Bytes()
+}
+
+// WKB - well known binary (little endian)
+func (m MultiPolygon) WKB() []byte {
+	var buf bytes.Buffer
+	writeWKBHeader(&buf, wkbMultiPolygon)
+	writeUint32(&buf, uint32(len(m)))
+	for _, p := range m {
+		writeWKBPolygon(&buf, p)
+	}
+	return buf.Bytes()
+}
+
+func writeWKBHeader(buf *bytes.Buffer, typ uint32) {
+	buf.WriteByte(1) // little endian
+	writeUint32(buf, typ)
+}
+
+func writeWKBPolygon(buf *bytes.Buffer, p Polygon) {
+	writeWKBHeader(buf, wkbPolygon)
+	writeUint32(buf, uint32(len(p)))
+	for _, r := range p {
+		writeWKBPositions(buf, r)
+	}
+}
+
+func writeWKBPositions(buf *bytes.Buffer, positions [][]float64) {
+	writeUint32(buf, uint32(len(positions)))
+	for _, pos := range positions {
+		writeWKBPosition(buf, pos)
+	}
+}
+
+func writeWKBPosition(buf *bytes.Buffer, pos []float64) {
+	writeFloat64(buf, pos[0])
+	writeFloat64(buf, pos[1])
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeFloat64(buf *bytes.Buffer, v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	buf.Write(b[:])
+}
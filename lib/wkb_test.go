@@ -0,0 +1,22 @@
+package lib
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWKB(t *testing.T) {
+	assert.Equal(t, "0101000000000000000000f03f0000000000000040", hex.EncodeToString(Point{1, 2}.WKB()))
+	assert.Equal(t, "010200000002000000000000000000f03f000000000000004000000000000008400000000000001040", hex.EncodeToString(LineString{{1, 2}, {3, 4}}.WKB()))
+
+	var polygon = Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}
+	var encoded = hex.EncodeToString(polygon.WKB())
+	assert.Equal(t, "01030000000100000004000000", encoded[:26])
+	assert.Equal(t, 9+4+4*16, len(polygon.WKB()))
+
+	// multipolygon contains full polygon encodings
+	var multi = MultiPolygon{polygon, polygon}.WKB()
+	assert.Equal(t, "010600000002000000"+encoded+encoded, hex.EncodeToString(multi))
+}
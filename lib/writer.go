@@ -2,6 +2,7 @@ package lib
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"sync"
 )
@@ -15,8 +16,13 @@ type BufferedWriter struct {
 
 // NewBufferedWriter - constructor
 func NewBufferedWriter() *BufferedWriter {
+	return NewBufferedWriterTo(os.Stdout)
+}
+
+// NewBufferedWriterTo - constructor, writing to out instead of stdout
+func NewBufferedWriterTo(out io.Writer) *BufferedWriter {
 	w := &BufferedWriter{
-		writer:    bufio.NewWriter(os.Stdout),
+		writer:    bufio.NewWriter(out),
 		waitGroup: &sync.WaitGroup{},
 		Queue:     make(chan []byte, 10000),
 	}
@@ -0,0 +1,28 @@
+package location
+
+import "math"
+
+// coordinates are stored as unsigned fixed-point values in units of
+// 100 nanodegrees, offset so that they are always positive.
+// a value of 0 is reserved to mean 'no location stored'.
+const precision = 1e7
+
+// offsets applied to lat/lon so that the fixed-point values are positive
+const (
+	latOffset = 90 * precision
+	lonOffset = 180 * precision
+)
+
+// encode - pack lat/lon in to a single non-zero uint64
+func encode(lat float64, lon float64) uint64 {
+	var y = uint64(math.Round(lat*precision)+latOffset) + 1
+	var x = uint64(math.Round(lon*precision)+lonOffset) + 1
+	return y<<32 | x
+}
+
+// decode - inverse of encode
+func decode(value uint64) (float64, float64) {
+	var y = int64(value>>32) - 1 - latOffset
+	var x = int64(value&0xffffffff) - 1 - lonOffset
+	return float64(y) / precision, float64(x) / precision
+}
@@ -0,0 +1,156 @@
+package location
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// size of each entry in bytes
+const entrySize = 8
+
+// minimum amount the file is grown by, to avoid remapping too frequently
+const growSize = 64 * 1024 * 1024
+
+// Flat - a memory-mapped file indexed by node id, each id occupies a fixed
+// 8 byte slot at offset id*8. the file is sparse so ranges of ids which were
+// never written don't consume disk space on most filesystems.
+type Flat struct {
+	mutex sync.RWMutex
+	file  *os.File
+	data  []byte
+}
+
+// OpenFlat - open (or create) a flat location file
+func OpenFlat(path string) (*Flat, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var f = &Flat{file: file}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// map existing data
+	if info.Size() > 0 {
+		if err := f.remap(info.Size()); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// WriteCoord - write lat/lon pair to the slot for this id
+func (f *Flat) WriteCoord(item gosmparse.Node) error {
+	if item.ID < 0 {
+		return errors.New("flat location store does not support negative ids")
+	}
+	var offset = item.ID * entrySize
+
+	// grow file if required
+	f.mutex.RLock()
+	if offset+entrySize > int64(len(f.data)) {
+		f.mutex.RUnlock()
+		if err := f.grow(offset + entrySize); err != nil {
+			return err
+		}
+		f.mutex.RLock()
+	}
+
+	// note: concurrent writers always target different slots
+	binary.LittleEndian.PutUint64(f.data[offset:], encode(item.Lat, item.Lon))
+	f.mutex.RUnlock()
+
+	return nil
+}
+
+// ReadCoord - read lat/lon pair from the slot for this id
+func (f *Flat) ReadCoord(id int64) (*gosmparse.Node, error) {
+	var offset = id * entrySize
+
+	f.mutex.RLock()
+	if id < 0 || offset+entrySize > int64(len(f.data)) {
+		f.mutex.RUnlock()
+		return nil, ErrNotFound
+	}
+	var value = binary.LittleEndian.Uint64(f.data[offset:])
+	f.mutex.RUnlock()
+
+	// slot was never written
+	if value == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, lon := decode(value)
+	return &gosmparse.Node{ID: id, Lat: lat, Lon: lon}, nil
+}
+
+// Close - unmap and close the file
+func (f *Flat) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if nil != f.data {
+		if err := syscall.Munmap(f.data); err != nil {
+			log.Println("flat location store:", err)
+		}
+		f.data = nil
+	}
+	if err := f.file.Close(); err != nil {
+		log.Println("flat location store:", err)
+	}
+}
+
+// grow - extend the file so it is at least min bytes long
+func (f *Flat) grow(min int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// another routine already grew the file
+	if min <= int64(len(f.data)) {
+		return nil
+	}
+
+	// round up to the next chunk, at least doubling the size so that
+	// sequentially increasing ids don't cause a remap for every chunk
+	var size = ((min / growSize) + 1) * growSize
+	if double := int64(len(f.data)) * 2; double > size {
+		size = double
+	}
+	if err := f.file.Truncate(size); err != nil {
+		return err
+	}
+
+	return f.remap(size)
+}
+
+// remap - replace the current mapping with one covering size bytes
+// note: caller must hold the write lock (or have exclusive access)
+func (f *Flat) remap(size int64) error {
+	if nil != f.data {
+		if err := syscall.Munmap(f.data); err != nil {
+			return err
+		}
+		f.data = nil
+	}
+
+	data, err := syscall.Mmap(int(f.file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+
+	f.data = data
+	return nil
+}
@@ -0,0 +1,69 @@
+package location
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	for _, c := range [][]float64{{0, 0}, {-90, -180}, {90, 180}, {16.49, 108.52}, {-33.8688197, 151.2092955}} {
+		lat, lon := decode(encode(c[0], c[1]))
+		assert.Equal(t, c[0], lat)
+		assert.Equal(t, c[1], lon)
+	}
+
+	// zero is reserved for missing values
+	assert.NotEqual(t, uint64(0), encode(-90, -180))
+}
+
+func TestFlat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flat")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	var path = filepath.Join(dir, "locations.flat")
+
+	store, err := OpenFlat(path)
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.WriteCoord(gosmparse.Node{ID: 1, Lat: 1.5, Lon: -2.5}))
+	assert.Nil(t, store.WriteCoord(gosmparse.Node{ID: 10000000, Lat: -1.5, Lon: 2.5}))
+	assert.NotNil(t, store.WriteCoord(gosmparse.Node{ID: -1}))
+
+	_, err = store.ReadCoord(2)
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.ReadCoord(20000000)
+	assert.Equal(t, ErrNotFound, err)
+	store.Close()
+
+	// re-open existing file
+	store, err = OpenFlat(path)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	node, err := store.ReadCoord(1)
+	assert.Nil(t, err)
+	assert.Equal(t, &gosmparse.Node{ID: 1, Lat: 1.5, Lon: -2.5}, node)
+
+	node, err = store.ReadCoord(10000000)
+	assert.Nil(t, err)
+	assert.Equal(t, &gosmparse.Node{ID: 10000000, Lat: -1.5, Lon: 2.5}, node)
+}
+
+func TestMemory(t *testing.T) {
+	var store = NewMemory()
+	defer store.Close()
+
+	assert.Nil(t, store.WriteCoord(gosmparse.Node{ID: 5, Lat: 1.5, Lon: -2.5}))
+
+	node, err := store.ReadCoord(5)
+	assert.Nil(t, err)
+	assert.Equal(t, &gosmparse.Node{ID: 5, Lat: 1.5, Lon: -2.5}, node)
+
+	_, err = store.ReadCoord(6)
+	assert.Equal(t, ErrNotFound, err)
+}
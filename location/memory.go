@@ -0,0 +1,47 @@
+package location
+
+import (
+	"sync"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// Memory - stores locations in a map, only suitable for extracts which
+// fit comfortably in RAM and for commands which don't persist the store
+type Memory struct {
+	mutex  sync.RWMutex
+	coords map[int64]uint64
+}
+
+// NewMemory - constructor
+func NewMemory() *Memory {
+	return &Memory{coords: make(map[int64]uint64)}
+}
+
+// WriteCoord - store lat/lon pair
+func (m *Memory) WriteCoord(item gosmparse.Node) error {
+	var value = encode(item.Lat, item.Lon)
+	m.mutex.Lock()
+	m.coords[item.ID] = value
+	m.mutex.Unlock()
+	return nil
+}
+
+// ReadCoord - retrieve lat/lon pair
+func (m *Memory) ReadCoord(id int64) (*gosmparse.Node, error) {
+	m.mutex.RLock()
+	value, ok := m.coords[id]
+	m.mutex.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	lat, lon := decode(value)
+	return &gosmparse.Node{ID: id, Lat: lat, Lon: lon}, nil
+}
+
+// Close - release memory
+func (m *Memory) Close() {
+	m.mutex.Lock()
+	m.coords = make(map[int64]uint64)
+	m.mutex.Unlock()
+}
@@ -0,0 +1,44 @@
+package location
+
+import (
+	"errors"
+
+	"github.com/missinglink/gosmparse"
+)
+
+// ErrNotFound is returned when no location is stored for a node id
+var ErrNotFound = errors.New("location not found")
+
+// Store - persist and retrieve node locations by id
+// note: leveldb.Connection satisfies this interface
+type Store interface {
+	WriteCoord(item gosmparse.Node) error
+	ReadCoord(id int64) (*gosmparse.Node, error)
+	Close()
+}
+
+// Writer - accept node locations while parsing
+// note: leveldb.CoordWriter satisfies this interface
+type Writer interface {
+	Enqueue(item *gosmparse.Node)
+	Close()
+}
+
+// SyncWriter - writes each location directly to a store which is
+// fast enough (and safe) to be written concurrently by parser routines
+type SyncWriter struct {
+	Store Store
+}
+
+// NewSyncWriter - constructor
+func NewSyncWriter(store Store) *SyncWriter {
+	return &SyncWriter{Store: store}
+}
+
+// Enqueue - write the location immediately
+func (w *SyncWriter) Enqueue(item *gosmparse.Node) {
+	w.Store.WriteCoord(*item)
+}
+
+// Close - nothing is buffered so there is nothing to flush
+func (w *SyncWriter) Close() {}
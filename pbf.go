@@ -5,6 +5,7 @@ import (
 
 	"github.com/urfave/cli"
 	"github.com/missinglink/pbf/command"
+	"github.com/missinglink/pbf/streetname"
 )
 
 func main() {
@@ -30,6 +31,9 @@ func main() {
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "bitmask, m", Usage: "only output element ids in bitmask"},
 				cli.StringFlag{Name: "leveldb, l", Usage: "location of leveldb tmp dir"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of leveldb/flat/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store (default {leveldb}/locations.flat)"},
 				cli.BoolTFlag{Name: "centroid, c", Usage: "compute centroid for non-point geometries"},
 				cli.BoolFlag{Name: "geohash, g", Usage: "compute geohash property for each record"},
 				cli.BoolFlag{Name: "vertices, v", Usage: "also output an array of way vertices"},
@@ -63,22 +67,92 @@ func main() {
 			Action:      command.Cypher,
 		},
 		{
-			Name:   "sqlite3",
-			Usage:  "import elements in to sqlite3 database, optionally using bitmask to filter elements",
-			Flags:  []cli.Flag{cli.StringFlag{Name: "bitmask, m", Usage: "only import element ids in bitmask"}},
+			Name:  "sqlite3",
+			Usage: "import elements in to sqlite3 database, optionally using bitmask to filter elements",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "bitmask, m", Usage: "only import element ids in bitmask"},
+				cli.BoolFlag{Name: "append, a", Usage: "add elements to an existing database instead of requiring a new file"},
+				cli.StringFlag{Name: "style, s", Usage: "write feature tables defined by a json style file instead of the element tables"},
+				cli.StringFlag{Name: "locations", Usage: "node location store used with --style, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
 			Action: command.Sqlite3,
 		},
 		{
-			Name:   "leveldb",
-			Usage:  "import elements in to leveldb database, optionally using bitmask to filter elements",
-			Flags:  []cli.Flag{cli.StringFlag{Name: "bitmask, m", Usage: "only import element ids in bitmask"}},
+			Name:  "geojson",
+			Usage: "convert to geojson point, linestring, polygon and multipolygon features",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of collection/seq (default collection)"},
+				cli.StringFlag{Name: "config, c", Usage: "only output features matching config"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
+			Action: command.GeoJSON,
+		},
+		{
+			Name:  "spatialite",
+			Usage: "import point, linestring and polygon geometries in to a spatialite database",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "config, c", Usage: "only import features matching config"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
+			Action: command.Spatialite,
+		},
+		{
+			Name:  "gpkg",
+			Usage: "write point, linestring and polygon layers to a geopackage file, with tag columns selected by a feature config",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "config, c", Usage: "only write features matching config, config keys are written as columns"},
+				cli.StringFlag{Name: "columns", Usage: "comma separated list of additional tag keys to write as columns"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
+			Action: command.GeoPackage,
+		},
+		{
+			Name:  "leveldb",
+			Usage: "import elements in to leveldb database, optionally using bitmask to filter elements",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "bitmask, m", Usage: "only import element ids in bitmask"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "index, i", Usage: "comma separated list of tag keys to index for leveldb-query"},
+			},
 			Action: command.LevelDB,
 		},
+		{
+			Name:  "leveldb-export",
+			Usage: "stream the contents of a leveldb database back out as json, xml or opl",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of json/xml/opl (default json)"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt (default leveldb)"},
+			},
+			Action: command.LevelDBExport,
+		},
+		{
+			Name:        "leveldb-query",
+			Usage:       "output elements matching a tag expression as json, using tag indexes where available",
+			Description: "expressions are of the form 'boundary=administrative & admin_level=4 | place', keys without an index are scanned",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "type, t", Usage: "comma separated list of element types to query (default node,way,relation)"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt (default leveldb)"},
+			},
+			Action: command.LevelDBQuery,
+		},
+		{
+			Name:   "leveldb-migrate",
+			Usage:  "upgrade a leveldb database written by an older version to the current storage schema",
+			Action: command.LevelDBMigrate,
+		},
 		{
 			Name:  "genmask",
-			Usage: "generate a bitmask file by specifying feature tags to match",
+			Usage: "generate a bitmask file by specifying feature tags to match, an area to select or an existing mask to expand",
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "config, c", Usage: "read features from config"},
+				cli.StringFlag{Name: "bbox, b", Usage: "only select elements within bbox: minlon,minlat,maxlon,maxlat"},
+				cli.StringFlag{Name: "polygon, p", Usage: "only select elements within the (multi)polygon in this geojson file"},
+				cli.StringFlag{Name: "expand, e", Usage: "start from an existing bitmask file instead of selecting features"},
+				cli.IntFlag{Name: "hops", Usage: "add connected ways and parent relations, repeated n times (default 1 with --expand)"},
 				cli.BoolFlag{Name: "indexing, i", Usage: "also write PBF index file"},
 			},
 			Action: command.BitmaskCustom,
@@ -104,29 +178,100 @@ func main() {
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "bitmask, m", Usage: "only store refs in bitmask"},
 				cli.StringFlag{Name: "leveldb, l", Usage: "location of leveldb tmp dir"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of leveldb/flat/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store (default {leveldb}/locations.flat)"},
 			},
 			Action: command.StoreNodeRefs,
 		},
 		{
-			Name:   "boundaries",
-			Usage:  "write geojson osm boundary files using a leveldb database as source",
+			Name:  "boundaries",
+			Usage: "write osm boundary geometries using a leveldb database as source",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of dir/geojsonseq/sqlite/gpkg (default dir), failures are recorded by every format"},
+				cli.StringFlag{Name: "report, r", Usage: "write a json report of successes and failures to this file"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of leveldb/flat/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store (default {leveldb}/locations.flat)"},
+			},
 			Action: command.BoundaryExporter,
 		},
 		{
-			Name:   "xroads",
-			Usage:  "compute street intersections",
+			Name:        "admin-lookup",
+			Usage:       "annotate json lines with the admin boundaries containing them, using a leveldb database as source",
+			Description: "input lines are read from a file or stdin and require either lat/lon or centroid properties, such as those written by json-flat",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of leveldb/flat/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store (default {leveldb}/locations.flat)"},
+			},
+			Action: command.AdminLookup,
+		},
+		{
+			Name:        "admin-tree",
+			Usage:       "output the parent/child hierarchy of admin boundaries, using a leveldb database as source",
+			Description: "parents are found by admin_level and geometry, using subarea members as hints, problems are flagged as orphan/overlap/subarea_hint/subarea_mismatch",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of json/text (default json)"},
+				cli.StringFlag{Name: "backend", Usage: "storage backend, one of leveldb/bolt/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of leveldb/flat/memory (default leveldb)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store (default {leveldb}/locations.flat)"},
+			},
+			Action: command.AdminTree,
+		},
+		{
+			Name:  "xroads",
+			Usage: "compute street intersections",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of csv/json/geojson"},
+				cli.StringFlag{Name: "lang, l", Usage: "output names in the first available language, comma separated, falls back to the name tag"},
+				cli.StringFlag{Name: "highway, w", Usage: "highway classes to intersect, comma separated (default motorway,trunk,primary,secondary,tertiary,residential,service,road,unclassified,living_street and the *_link classes)"},
+				cli.StringFlag{Name: "config, c", Usage: "intersect the ways matching a feature config instead of highway classes"},
+				cli.BoolFlag{Name: "extended, e", Usage: "output additional csv columns containing the node, ways, highways, legs and bearings"},
+				cli.StringFlag{Name: "source", Value: "openstreetmap", Usage: "value of the source field"},
+				cli.StringFlag{Name: "layer", Value: "intersection", Usage: "value of the layer field"},
+				cli.Float64Flag{Name: "cluster", Usage: "cluster the intersections of the same streets, or of the same roundabout, within this many metres (default disabled)"},
+			},
 			Action: command.Crossroads,
 		},
 		{
 			Name:  "streets",
 			Usage: "export street segments as merged linestrings, encoded in various formats",
 			Flags: []cli.Flag{
-				cli.StringFlag{Name: "format, f", Usage: "select output format, one of polyline/geojson/wkt"},
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of polyline/geojson/wkt/json"},
 				cli.StringFlag{Name: "delim, d", Usage: "change the column delimiter (default \x00)"},
+				cli.StringFlag{Name: "lang, l", Usage: "output the name in the first available language, comma separated, falls back to the name tag"},
 				cli.BoolFlag{Name: "extended, e", Usage: "output additional columns containing centroid and distance values"},
+				cli.BoolFlag{Name: "provenance, p", Usage: "output the ids, original names, highway and oneway tags of the merged ways"},
+				cli.StringFlag{Name: "normalizer, n", Usage: "street name normalizer, one of vietnamese/http (default vietnamese)"},
+				cli.StringFlag{Name: "normalizer-url", Usage: "address parser used by the http normalizer (default " + streetname.DefaultParserURL + ")"},
+				cli.IntFlag{Name: "normalizer-rate", Value: 10, Usage: "maximum http normalizer requests per second"},
+				cli.StringFlag{Name: "config, c", Usage: "merge rules config file, distances in metres"},
 			},
 			Action: command.StreetMerge,
 		},
+		{
+			Name:  "interpolate",
+			Usage: "expand addr:interpolation ways in to address points",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of json/csv (default json)"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
+			Action: command.Interpolate,
+		},
+		{
+			Name:  "addresses",
+			Usage: "export addresses from nodes, ways and relations as csv or json lines",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format, f", Usage: "select output format, one of json/csv (default json)"},
+				cli.Float64Flag{Name: "street-distance", Value: 100, Usage: "use the nearest named highway within this many metres when addr:street is missing, 0 to disable"},
+				cli.StringFlag{Name: "admin", Usage: "add the admin areas containing each address, using a leveldb database as source"},
+				cli.StringFlag{Name: "locations", Usage: "node location store, one of memory/flat (default memory)"},
+				cli.StringFlag{Name: "locations-file", Usage: "location of flat location store"},
+			},
+			Action: command.Addresses,
+		},
 		{
 			Name:   "noderefs",
 			Usage:  "count the number of times a nodeid is referenced in file",
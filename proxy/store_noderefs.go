@@ -2,14 +2,14 @@ package proxy
 
 import (
 	"github.com/missinglink/gosmparse"
-	"github.com/missinglink/pbf/leveldb"
 	"github.com/missinglink/pbf/lib"
+	"github.com/missinglink/pbf/location"
 )
 
 // StoreRefs - filter only elements that appear in masks
 type StoreRefs struct {
 	Handler gosmparse.OSMReader
-	Writer  *leveldb.CoordWriter
+	Writer  location.Writer
 	Masks   *lib.BitmaskMap
 }
 
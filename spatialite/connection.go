@@ -19,11 +19,29 @@ func (c *Connection) Open(path string) {
 	}
 	c.DB = db
 
-	// // init spatial metadata
-	// _, err = db.Exec("SELECT InitSpatialMetadata(1)")
-	// if err != nil {
-	// 	panic(err)
-	// }
+	// https://github.com/mattn/go-sqlite3/issues/274
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		PRAGMA main.synchronous=NORMAL;
+		PRAGMA main.journal_mode=WAL;
+		PRAGMA main.temp_store=MEMORY;`)
+	if err != nil {
+		panic(err)
+	}
+
+	// init spatial metadata (only once per database)
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'spatial_ref_sys'").Scan(&exists)
+	if err != nil {
+		panic(err)
+	}
+	if 0 == exists {
+		_, err = db.Exec("SELECT InitSpatialMetadata(1)")
+		if err != nil {
+			panic(err)
+		}
+	}
 }
 
 // Close - close connection and clean up
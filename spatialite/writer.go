@@ -0,0 +1,149 @@
+package spatialite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/missinglink/pbf/lib"
+)
+
+// number of features written per transaction
+var batchSize = 50000
+
+// tables written by the Writer and their geometry types
+var tables = []struct {
+	name     string
+	geometry string
+}{
+	{"points", "POINT"},
+	{"lines", "LINESTRING"},
+	{"polygons", "MULTIPOLYGON"},
+}
+
+// Writer - write features to geometry tables
+type Writer struct {
+	Conn *Connection
+
+	mutex   sync.Mutex
+	stmts   map[string]*sql.Stmt
+	pending int
+}
+
+// NewWriter - create tables and prepare statements
+func NewWriter(conn *Connection) *Writer {
+	var w = &Writer{Conn: conn, stmts: make(map[string]*sql.Stmt)}
+
+	for _, t := range tables {
+		_, err := conn.DB.Exec(`
+			CREATE TABLE IF NOT EXISTS ` + t.name + ` (
+			    id INTEGER NOT NULL PRIMARY KEY,
+			    osm_type TEXT NOT NULL,
+			    osm_id INTEGER NOT NULL,
+			    tags TEXT
+			)`)
+		if err != nil {
+			panic(err)
+		}
+
+		// geometry columns must be added using the spatialite function
+		var exists int
+		err = conn.DB.QueryRow("SELECT COUNT(*) FROM geometry_columns WHERE f_table_name = ?", t.name).Scan(&exists)
+		if err != nil {
+			panic(err)
+		}
+		if 0 == exists {
+			_, err = conn.DB.Exec("SELECT AddGeometryColumn(?, 'geom', 4326, ?, 'XY')", t.name, t.geometry)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		// polygons are always written as multipolygons
+		var geom = "GeomFromText(?, 4326)"
+		if "MULTIPOLYGON" == t.geometry {
+			geom = "CastToMultiPolygon(" + geom + ")"
+		}
+		stmt, err := conn.DB.Prepare("INSERT INTO " + t.name + " (osm_type, osm_id, tags, geom) VALUES (?, ?, ?, " + geom + ")")
+		if err != nil {
+			panic(err)
+		}
+		w.stmts[t.geometry] = stmt
+	}
+
+	// start transaction
+	_, err := conn.DB.Exec("BEGIN TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	return w
+}
+
+// WriteFeature - insert feature in to the table matching its geometry type
+func (w *Writer) WriteFeature(feature *lib.Feature) {
+	var typ = feature.Geometry.GeometryType()
+	if "POLYGON" == typ {
+		typ = "MULTIPOLYGON"
+	}
+
+	tags, err := json.Marshal(feature.Tags)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, err = w.stmts[typ].Exec(feature.Type, feature.ID, string(tags), feature.Geometry.WKT())
+	if err != nil {
+		log.Printf("failed to write %s %d: %s\n", feature.Type, feature.ID, err)
+	}
+
+	// commit periodically
+	w.pending++
+	if w.pending >= batchSize {
+		w.pending = 0
+		if _, err := w.Conn.DB.Exec("END TRANSACTION; BEGIN TRANSACTION"); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Close - commit, build spatial indexes and release statements
+func (w *Writer) Close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, stmt := range w.stmts {
+		stmt.Close()
+	}
+
+	_, err := w.Conn.DB.Exec("END TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	// spatial indexes are much faster to build after a bulk import
+	for _, t := range tables {
+		_, err := w.Conn.DB.Exec(`
+			SELECT CreateSpatialIndex(?, 'geom')
+			WHERE NOT EXISTS (
+				SELECT 1 FROM geometry_columns
+				WHERE f_table_name = ? AND spatial_index_enabled = 1
+			)`, t.name, t.name)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	_, err = w.Conn.DB.Exec(`
+		CREATE INDEX IF NOT EXISTS points_osm_idx ON points (osm_type, osm_id);
+		CREATE INDEX IF NOT EXISTS lines_osm_idx ON lines (osm_type, osm_id);
+		CREATE INDEX IF NOT EXISTS polygons_osm_idx ON polygons (osm_type, osm_id);`)
+	if err != nil {
+		log.Println(err)
+	}
+}
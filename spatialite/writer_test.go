@@ -0,0 +1,63 @@
+package spatialite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestConnection - a connection to a temporary database, the test is
+// skipped when the spatialite extension is not installed
+func openTestConnection(t *testing.T) (*Connection, func()) {
+	dir, err := ioutil.TempDir("", "spatialite")
+	assert.Nil(t, err)
+	var path = filepath.Join(dir, "test.db")
+
+	db, err := sql.Open("spatialite", path)
+	assert.Nil(t, err)
+	var version string
+	err = db.QueryRow("SELECT spatialite_version()").Scan(&version)
+	db.Close()
+	if nil != err {
+		os.RemoveAll(dir)
+		t.Skip("spatialite extension not available:", err)
+	}
+
+	var conn = &Connection{}
+	conn.Open(path)
+	return conn, func() {
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	conn, cleanup := openTestConnection(t)
+	defer cleanup()
+
+	var w = NewWriter(conn)
+	w.WriteFeature(&lib.Feature{Type: "node", ID: 1, Tags: map[string]string{"amenity": "cafe"}, Geometry: lib.Point{1, 2}})
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 2, Tags: map[string]string{"highway": "residential"}, Geometry: lib.LineString{{0, 0}, {1, 1}}})
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 3, Geometry: lib.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}})
+	w.WriteFeature(&lib.Feature{Type: "relation", ID: 4, Geometry: lib.MultiPolygon{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}})
+	w.Close()
+
+	var count = func(query string) int {
+		var n int
+		assert.Nil(t, conn.DB.QueryRow(query).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM points WHERE osm_type = 'node' AND osm_id = 1 AND X(geom) = 1 AND Y(geom) = 2"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM lines WHERE osm_type = 'way' AND osm_id = 2 AND tags = '{\"highway\":\"residential\"}'"))
+
+	// polygons are cast to multipolygons
+	assert.Equal(t, 2, count("SELECT COUNT(*) FROM polygons WHERE GeometryType(geom) = 'MULTIPOLYGON'"))
+
+	// spatial indexes are built on close
+	assert.Equal(t, 3, count("SELECT COUNT(*) FROM geometry_columns WHERE spatial_index_enabled = 1"))
+}
@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/missinglink/pbf/lib"
+)
+
+// BoundaryWriter - write assembled boundary relations to a 'boundaries'
+// table keyed by relation id, relations which could not be assembled are
+// written to 'boundary_errors'
+type BoundaryWriter struct {
+	DB *sql.DB
+
+	mutex    sync.Mutex
+	boundary *sql.Stmt
+	failure  *sql.Stmt
+}
+
+// NewBoundaryWriter - open database, create tables and prepare statements
+func NewBoundaryWriter(path string) *BoundaryWriter {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		panic(err)
+	}
+
+	// https://github.com/mattn/go-sqlite3/issues/274
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		PRAGMA main.synchronous=NORMAL;
+		PRAGMA main.journal_mode=WAL;
+
+		CREATE TABLE schema_info (
+		    key TEXT NOT NULL PRIMARY KEY,
+		    value TEXT
+		);
+		INSERT INTO schema_info (key, value) VALUES ('version', '` + strconv.Itoa(SchemaVersion) + `');
+		INSERT INTO schema_info (key, value) VALUES ('generator', 'missinglink/pbf');
+		INSERT INTO schema_info (key, value) VALUES ('layout', 'boundaries');
+		CREATE TABLE boundaries (
+		    id INTEGER NOT NULL PRIMARY KEY,
+		    admin_level INTEGER,
+		    name TEXT,
+		    minlon REAL NOT NULL,
+		    minlat REAL NOT NULL,
+		    maxlon REAL NOT NULL,
+		    maxlat REAL NOT NULL,
+		    tags TEXT NOT NULL,
+		    geom TEXT NOT NULL
+		);
+		CREATE TABLE boundary_errors (
+		    id INTEGER NOT NULL PRIMARY KEY,
+		    admin_level INTEGER,
+		    name TEXT,
+		    reason TEXT NOT NULL,
+		    ids TEXT
+		);`)
+	if err != nil {
+		panic(err)
+	}
+
+	var w = &BoundaryWriter{DB: db}
+
+	w.boundary, err = db.Prepare("INSERT OR REPLACE INTO boundaries VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		panic(err)
+	}
+
+	w.failure, err = db.Prepare("INSERT OR REPLACE INTO boundary_errors VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		panic(err)
+	}
+
+	// start transaction
+	_, err = db.Exec("BEGIN TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	return w
+}
+
+// WriteBoundary - insert an assembled boundary
+func (w *BoundaryWriter) WriteBoundary(id int64, tags map[string]string, geometry lib.Geometry) {
+	var bounds = geometry.Bounds()
+	encoded, _ := json.Marshal(tags)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, err := w.boundary.Exec(id, AdminLevel(tags), Nullable(tags["name"]),
+		bounds.MinLon, bounds.MinLat, bounds.MaxLon, bounds.MaxLat, string(encoded), geometry.WKT())
+	if err != nil {
+		log.Printf("failed to write relation %d: %s\n", id, err)
+	}
+}
+
+// WriteError - record why a boundary could not be assembled
+func (w *BoundaryWriter) WriteError(id int64, tags map[string]string, reason string, ids []int64) {
+	var encoded interface{}
+	if len(ids) > 0 {
+		bytes, _ := json.Marshal(ids)
+		encoded = string(bytes)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	_, err := w.failure.Exec(id, AdminLevel(tags), Nullable(tags["name"]), reason, encoded)
+	if err != nil {
+		log.Printf("failed to write relation %d: %s\n", id, err)
+	}
+}
+
+// Close - commit, index and close the database
+func (w *BoundaryWriter) Close() {
+	defer w.DB.Close()
+	w.boundary.Close()
+	w.failure.Close()
+
+	// commit transaction
+	_, err := w.DB.Exec("END TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = w.DB.Exec(`
+		CREATE INDEX boundaries_admin_level_idx ON boundaries (admin_level);
+		CREATE INDEX boundaries_bbox_idx ON boundaries (minlon, maxlon, minlat, maxlat);`)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// AdminLevel - numeric admin_level tag or NULL
+func AdminLevel(tags map[string]string) interface{} {
+	if level, err := strconv.Atoi(tags["admin_level"]); nil == err {
+		return level
+	}
+	return nil
+}
+
+// Nullable - NULL for empty strings
+func Nullable(value string) interface{} {
+	if "" == value {
+		return nil
+	}
+	return value
+}
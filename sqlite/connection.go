@@ -2,16 +2,36 @@ package sqlite
 
 import (
 	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3" // required database driver
 )
 
 // schema copied from https://github.com/mfn/osmlib-sqlite
 
+// SchemaVersion - the table layout written by this version of the code
+//
+// v1: no metadata, no indexes, members.type stored as an integer.
+// v2: schema_info table, indexes and r-tree created after import,
+//
+//	members.type stored as 'node', 'way' or 'relation'.
+const SchemaVersion = 2
+
+// number of elements written per transaction
+var batchSize = 50000
+
 // Connection - Connection
 type Connection struct {
 	db   *sql.DB
 	Stmt *Statements
+
+	// transaction batching
+	mutex   sync.Mutex
+	pending int
 }
 
 // GetDB - expose the underlying db object
@@ -27,12 +47,12 @@ func (c *Connection) Open(path string) {
 	}
 	c.db = db
 
-	c.tables()
-	c.prepare()
-
 	// https://github.com/mattn/go-sqlite3/issues/274
 	db.SetMaxOpenConns(1)
 
+	c.tables()
+	c.prepare()
+
 	// start transaction
 	_, err = c.db.Exec("BEGIN TRANSACTION")
 	if err != nil {
@@ -43,8 +63,8 @@ func (c *Connection) Open(path string) {
 // Close - close connection and clean up
 func (c *Connection) Close() {
 
-	defer c.Stmt.Close()
 	defer c.db.Close()
+	defer c.Stmt.Close()
 
 	// commit transaction
 	_, err := c.db.Exec("END TRANSACTION")
@@ -53,18 +73,147 @@ func (c *Connection) Close() {
 	}
 }
 
+// Checkpoint - called once per element, commits the current transaction
+// and starts a new one every batchSize elements
+func (c *Connection) Checkpoint() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pending++
+	if c.pending < batchSize {
+		return
+	}
+	c.pending = 0
+
+	_, err := c.db.Exec("END TRANSACTION; BEGIN TRANSACTION")
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// ReadVersion - read the schema version of an existing database file,
+// returns 0 for databases without any tables and 1 for databases which
+// predate the schema_info table
+func ReadVersion(path string) (int, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('nodes', 'schema_info')").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	var value string
+	err = db.QueryRow("SELECT value FROM schema_info WHERE key = 'version'").Scan(&value)
+	if err != nil {
+		return 1, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// RecordSource - write metadata about the imported file, each import
+// (including appends) adds a row to the sources table
+func (c *Connection) RecordSource(source string) error {
+	return recordSource(c.db, source)
+}
+
+// recordSource - add a row to the sources table and update the schema_info
+// timestamps, shared by every sqlite writer
+func recordSource(db *sql.DB, source string) error {
+	if "" == source {
+		return errors.New("source required")
+	}
+	var now = time.Now().UTC().Format(time.RFC3339)
+	var statements = []struct {
+		query  string
+		values []interface{}
+	}{
+		{`CREATE TABLE IF NOT EXISTS sources (
+		    id INTEGER NOT NULL PRIMARY KEY,
+		    source TEXT NOT NULL,
+		    imported TEXT NOT NULL
+		)`, nil},
+		{"INSERT INTO sources (source, imported) VALUES (?, ?)", []interface{}{source, now}},
+		{"INSERT OR IGNORE INTO schema_info (key, value) VALUES ('created', ?)", []interface{}{now}},
+		{"INSERT OR REPLACE INTO schema_info (key, value) VALUES ('updated', ?)", []interface{}{now}},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.query, stmt.values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexes - the indexes created after a bulk import
+var indexes = []struct {
+	name       string
+	definition string
+}{
+	{"way_nodes_node_idx", "way_nodes (node)"},
+	{"node_tags_key_value_idx", "node_tags (key, value)"},
+	{"way_tags_key_value_idx", "way_tags (key, value)"},
+	{"relation_tags_key_value_idx", "relation_tags (key, value)"},
+	{"members_ref_idx", "members (type, ref)"},
+}
+
+// DropIndexes - drop the indexes of an existing database before appending
+// to it, they are recreated by CreateIndexes once the import is complete
+func (c *Connection) DropIndexes() error {
+	for _, index := range indexes {
+		if _, err := c.db.Exec("DROP INDEX IF EXISTS " + index.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateIndexes - create indexes and populate the r-tree tables, this is
+// much faster after a bulk import than maintaining them while importing
+func (c *Connection) CreateIndexes() error {
+	for _, index := range indexes {
+		if _, err := c.db.Exec("CREATE INDEX IF NOT EXISTS " + index.name + " ON " + index.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err := c.db.Exec(`
+		INSERT OR REPLACE INTO node_rtree (id, minlon, maxlon, minlat, maxlat)
+		SELECT id, lon, lon, lat, lat FROM nodes;
+
+		INSERT OR REPLACE INTO way_rtree (id, minlon, maxlon, minlat, maxlat)
+		SELECT way_nodes.way, MIN(nodes.lon), MAX(nodes.lon), MIN(nodes.lat), MAX(nodes.lat)
+		FROM way_nodes
+		JOIN nodes ON way_nodes.node = nodes.id
+		GROUP BY way_nodes.way;`)
+	return err
+}
+
 // created tables
 func (c *Connection) tables() {
 	_, err := c.db.Exec(`
 
 		PRAGMA main.foreign_keys=OFF;
-    PRAGMA main.page_size=4096;
-    PRAGMA main.cache_size=-2000;
-    PRAGMA main.synchronous=OFF;
-    PRAGMA main.journal_mode=OFF;
-    PRAGMA main.temp_store=MEMORY;
+		PRAGMA main.page_size=4096;
+		PRAGMA main.cache_size=-64000;
+		PRAGMA main.synchronous=NORMAL;
+		PRAGMA main.journal_mode=WAL;
+		PRAGMA main.temp_store=MEMORY;
 
 		BEGIN TRANSACTION;
+		CREATE TABLE IF NOT EXISTS schema_info (
+		    key TEXT NOT NULL PRIMARY KEY,
+		    value TEXT
+		);
+		INSERT OR IGNORE INTO schema_info (key, value) VALUES ('version', '` + strconv.Itoa(SchemaVersion) + `');
+		INSERT OR IGNORE INTO schema_info (key, value) VALUES ('generator', 'missinglink/pbf');
 		CREATE TABLE IF NOT EXISTS nodes (
 		    id INTEGER NOT NULL PRIMARY KEY,
 		    lon REAL NOT NULL,
@@ -72,39 +221,47 @@ func (c *Connection) tables() {
 		);
 		CREATE TABLE IF NOT EXISTS node_tags (
 		    ref INTEGER NOT NULL,
-		    key TEXT,
-		    value TEXT,
-				UNIQUE( ref, key ) ON CONFLICT REPLACE
+		    key TEXT NOT NULL,
+		    value TEXT NOT NULL,
+		    UNIQUE( ref, key ) ON CONFLICT REPLACE
 		);
 		CREATE TABLE IF NOT EXISTS ways (
 		    id INTEGER NOT NULL PRIMARY KEY
 		);
 		CREATE TABLE IF NOT EXISTS way_tags (
 		    ref INTEGER NOT NULL,
-		    key TEXT,
-		    value TEXT,
-				UNIQUE( ref, key ) ON CONFLICT REPLACE
+		    key TEXT NOT NULL,
+		    value TEXT NOT NULL,
+		    UNIQUE( ref, key ) ON CONFLICT REPLACE
 		);
 		CREATE TABLE IF NOT EXISTS way_nodes (
 		    way INTEGER NOT NULL,
 		    num INTEGER NOT NULL,
 		    node INTEGER NOT NULL,
-				UNIQUE( way, num ) ON CONFLICT REPLACE
+		    UNIQUE( way, num ) ON CONFLICT REPLACE
 		);
 		CREATE TABLE IF NOT EXISTS relations (
 		    id INTEGER NOT NULL PRIMARY KEY
 		);
 		CREATE TABLE IF NOT EXISTS relation_tags (
 		    ref INTEGER NOT NULL,
-		    key TEXT,
-		    value TEXT,
-				UNIQUE( ref, key ) ON CONFLICT REPLACE
+		    key TEXT NOT NULL,
+		    value TEXT NOT NULL,
+		    UNIQUE( ref, key ) ON CONFLICT REPLACE
 		);
 		CREATE TABLE IF NOT EXISTS members (
 		    relation INTEGER NOT NULL,
-		    type TEXT,
+		    num INTEGER NOT NULL,
+		    type TEXT NOT NULL CHECK( type IN ('node', 'way', 'relation') ),
 		    ref INTEGER NOT NULL,
-		    role TEXT
+		    role TEXT,
+		    UNIQUE( relation, num ) ON CONFLICT REPLACE
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS node_rtree USING rtree (
+		    id, minlon, maxlon, minlat, maxlat
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS way_rtree USING rtree (
+		    id, minlon, maxlon, minlat, maxlat
 		);
 		COMMIT TRANSACTION;`)
 	if err != nil {
@@ -150,7 +307,7 @@ func (c *Connection) prepare() {
 		panic(err)
 	}
 
-	member, err := c.db.Prepare("INSERT OR REPLACE INTO members (relation, type, ref, role) VALUES (:relation, :type, :ref, :role)")
+	member, err := c.db.Prepare("INSERT OR REPLACE INTO members (relation, num, type, ref, role) VALUES (:relation, :num, :type, :ref, :role)")
 	if err != nil {
 		panic(err)
 	}
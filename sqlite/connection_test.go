@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tempDB - the path of a database file in a new temporary directory
+func tempDB(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "sqlite")
+	assert.Nil(t, err)
+	return filepath.Join(dir, "test.db")
+}
+
+// count - the result of a COUNT(*) query
+func count(t *testing.T, db *sql.DB, query string) int {
+	var n int
+	assert.Nil(t, db.QueryRow(query).Scan(&n))
+	return n
+}
+
+func TestReadVersion(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	// no tables
+	version, err := ReadVersion(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, version)
+
+	// legacy databases have no schema_info table
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE nodes (id INTEGER NOT NULL PRIMARY KEY, lon REAL NOT NULL, lat REAL NOT NULL)")
+	assert.Nil(t, err)
+	db.Close()
+
+	version, err = ReadVersion(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, version)
+
+	// current databases record the version
+	var current = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(current))
+	var conn = &Connection{}
+	conn.Open(current)
+	conn.Close()
+
+	version, err = ReadVersion(current)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaVersion, version)
+}
+
+func TestCheckpoint(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	defer func(size int) { batchSize = size }(batchSize)
+	batchSize = 2
+
+	var conn = &Connection{}
+	conn.Open(path)
+	defer conn.Close()
+
+	// a second connection only sees committed rows
+	reader, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	_, err = conn.Stmt.Node.Exec(1, 108.5, 16.5)
+	assert.Nil(t, err)
+	conn.Checkpoint()
+	assert.Equal(t, 0, count(t, reader, "SELECT COUNT(*) FROM nodes"))
+
+	_, err = conn.Stmt.Node.Exec(2, 108.6, 16.6)
+	assert.Nil(t, err)
+	conn.Checkpoint()
+	assert.Equal(t, 2, count(t, reader, "SELECT COUNT(*) FROM nodes"))
+}
+
+func TestRecordSource(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	var conn = &Connection{}
+	conn.Open(path)
+	defer conn.Close()
+
+	assert.NotNil(t, conn.RecordSource(""))
+	assert.Nil(t, conn.RecordSource("a.pbf"))
+	assert.Nil(t, conn.RecordSource("b.pbf"))
+
+	// one row per source
+	rows, err := conn.GetDB().Query("SELECT source FROM sources ORDER BY id")
+	assert.Nil(t, err)
+	var sources []string
+	for rows.Next() {
+		var source string
+		assert.Nil(t, rows.Scan(&source))
+		sources = append(sources, source)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"a.pbf", "b.pbf"}, sources)
+
+	assert.Equal(t, 1, count(t, conn.GetDB(), "SELECT COUNT(*) FROM schema_info WHERE key = 'created'"))
+	assert.Equal(t, 1, count(t, conn.GetDB(), "SELECT COUNT(*) FROM schema_info WHERE key = 'updated'"))
+}
+
+func TestCreateIndexes(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	var conn = &Connection{}
+	conn.Open(path)
+	defer conn.Close()
+
+	var db = conn.GetDB()
+	var query = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name LIKE '%_idx'"
+
+	_, err := conn.Stmt.Node.Exec(1, 108.5, 16.5)
+	assert.Nil(t, err)
+	_, err = conn.Stmt.Node.Exec(2, 108.6, 16.6)
+	assert.Nil(t, err)
+	_, err = conn.Stmt.WayNodes.Exec(10, 0, 1)
+	assert.Nil(t, err)
+	_, err = conn.Stmt.WayNodes.Exec(10, 1, 2)
+	assert.Nil(t, err)
+
+	assert.Nil(t, conn.CreateIndexes())
+	assert.Equal(t, len(indexes), count(t, db, query))
+	assert.Equal(t, 2, count(t, db, "SELECT COUNT(*) FROM node_rtree"))
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM way_rtree WHERE id = 10 AND minlon = 108.5 AND maxlat >= 16.6"))
+
+	// indexes are dropped before appending
+	assert.Nil(t, conn.DropIndexes())
+	assert.Equal(t, 0, count(t, db, query))
+
+	// creating indexes is repeatable
+	assert.Nil(t, conn.CreateIndexes())
+	assert.Nil(t, conn.CreateIndexes())
+	assert.Equal(t, len(indexes), count(t, db, query))
+}
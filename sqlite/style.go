@@ -0,0 +1,295 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/missinglink/pbf/lib"
+
+	geo "github.com/paulmach/go.geo"
+)
+
+// Style - maps features to tables, loaded from a JSON file of the form:
+//
+//	{
+//	  "tables": [{
+//	    "name": "roads",
+//	    "match": { "way": [["highway"]] },
+//	    "geometry": "linestring",
+//	    "columns": [
+//	      { "name": "name", "tag": "name" },
+//	      { "name": "lanes", "tag": "lanes", "type": "integer" },
+//	      { "name": "oneway", "tag": "oneway", "type": "boolean" }
+//	    ],
+//	    "tags": "unmapped"
+//	  }]
+//	}
+//
+// match uses the same format as the feature config files, a feature is
+// written to every table it matches.
+type Style struct {
+	Tables []*Table `json:"tables"`
+}
+
+// Table - a single output table
+type Table struct {
+	Name     string     `json:"name"`
+	Match    lib.Config `json:"match"`
+	Geometry string     `json:"geometry"`
+	Columns  []Column   `json:"columns"`
+	Tags     string     `json:"tags"`
+
+	features *lib.FeatureSet
+	mapped   map[string]bool
+}
+
+// Column - a typed column populated from a tag value
+type Column struct {
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+	Type string `json:"type"`
+}
+
+// geometry choices, 'point' converts lines and polygons to their centroid
+// and 'polygon' accepts closed linestrings as polygons
+var styleGeometries = map[string]bool{"point": true, "linestring": true, "polygon": true, "any": true, "none": true}
+
+// column types and their sqlite declarations
+var styleTypes = map[string]string{"text": "TEXT", "integer": "INTEGER", "real": "REAL", "boolean": "INTEGER"}
+
+// tag retention choices for the 'tags' json column
+var styleRetention = map[string]bool{"none": true, "all": true, "unmapped": true}
+
+// column names used by every table which cannot be used for tags
+var styleReserved = map[string]bool{"fid": true, "osm_type": true, "osm_id": true, "geom": true, "tags": true}
+
+// tables created alongside the style tables
+var styleTables = map[string]bool{"schema_info": true, "sources": true}
+
+// table and column names are quoted when interpolated in to sql
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// leading number used when converting tag values, eg. '50 mph' or '3.5 m'
+var leadingNumber = regexp.MustCompile(`^[-+]?[0-9]*\.?[0-9]+`)
+
+// NewStyleFromJSON - load and validate a style file
+func NewStyleFromJSON(path string) (*Style, error) {
+
+	file, e := ioutil.ReadFile(path)
+	if nil != e {
+		return nil, e
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(file))
+	decoder.DisallowUnknownFields()
+	var style Style
+	if err := decoder.Decode(&style); nil != err {
+		return nil, err
+	}
+
+	return &style, style.validate()
+}
+
+// validate - check the style and fill in defaults
+func (s *Style) validate() error {
+	if len(s.Tables) == 0 {
+		return fmt.Errorf("style contains no tables")
+	}
+
+	// sqlite names are case insensitive and each table also creates an
+	// r-tree and an index, none of which may clash
+	var names = make(map[string]bool)
+	for _, t := range s.Tables {
+		var name = strings.ToLower(t.Name)
+		names[name+"_rtree"] = true
+		names[name+"_osm_idx"] = true
+	}
+	var tables = make(map[string]bool)
+	for _, t := range s.Tables {
+		if !identifier.MatchString(t.Name) {
+			return fmt.Errorf("invalid table name '%s'", t.Name)
+		}
+		var name = strings.ToLower(t.Name)
+		if tables[name] || names[name] || styleTables[name] {
+			return fmt.Errorf("duplicate table name '%s'", t.Name)
+		}
+		tables[name] = true
+
+		if "" == t.Geometry {
+			t.Geometry = "any"
+		}
+		if !styleGeometries[t.Geometry] {
+			return fmt.Errorf("table '%s': invalid geometry '%s'", t.Name, t.Geometry)
+		}
+		if "" == t.Tags {
+			t.Tags = "none"
+		}
+		if !styleRetention[t.Tags] {
+			return fmt.Errorf("table '%s': invalid tags '%s'", t.Name, t.Tags)
+		}
+
+		t.features = lib.NewFeatureSetFromConfig(t.Match)
+		t.mapped = make(map[string]bool)
+		var columns = make(map[string]bool)
+		for i := range t.Columns {
+			var col = &t.Columns[i]
+			if "" == col.Tag {
+				col.Tag = col.Name
+			}
+			if "" == col.Type {
+				col.Type = "text"
+			}
+			var name = strings.ToLower(col.Name)
+			if !identifier.MatchString(col.Name) || styleReserved[name] || columns[name] {
+				return fmt.Errorf("table '%s': invalid column name '%s'", t.Name, col.Name)
+			}
+			if _, ok := styleTypes[col.Type]; !ok {
+				return fmt.Errorf("table '%s': invalid type '%s' for column '%s'", t.Name, col.Type, col.Name)
+			}
+			columns[name] = true
+			t.mapped[col.Tag] = true
+		}
+	}
+
+	return nil
+}
+
+// Features - a featureset matching any table, used to skip elements which
+// won't be written anywhere
+func (s *Style) Features() *lib.FeatureSet {
+	var fs = &lib.FeatureSet{}
+	for _, t := range s.Tables {
+		fs.Merge(t.features)
+	}
+	return fs
+}
+
+// Matches - yes/no if the feature belongs in this table
+func (t *Table) Matches(feature *lib.Feature) bool {
+	return t.features.MatchTags(feature.Type, feature.Tags)
+}
+
+// Geom - convert the feature geometry to the table geometry type,
+// returns nil if the feature cannot be represented
+func (t *Table) Geom(geometry lib.Geometry) lib.Geometry {
+	switch t.Geometry {
+	case "any":
+		return geometry
+	case "point":
+		if _, ok := geometry.(lib.Point); ok {
+			return geometry
+		}
+		return centroid(geometry)
+	case "linestring":
+		if _, ok := geometry.(lib.LineString); ok {
+			return geometry
+		}
+	case "polygon":
+		switch g := geometry.(type) {
+		case lib.Polygon, lib.MultiPolygon:
+			return geometry
+		case lib.LineString:
+			if len(g) >= 4 && g[0][0] == g[len(g)-1][0] && g[0][1] == g[len(g)-1][1] {
+				return lib.Polygon{lib.Ring(g)}
+			}
+		}
+	}
+	return nil
+}
+
+// centroid - the centroid of a line or of the outer ring of the largest
+// polygon, computed the same way as way centroids elsewhere
+func centroid(geometry lib.Geometry) lib.Geometry {
+	var positions [][]float64
+	switch g := geometry.(type) {
+	case lib.LineString:
+		positions = g
+	case lib.Polygon:
+		if len(g) > 0 {
+			positions = g[0]
+		}
+	case lib.MultiPolygon:
+		var largest float64
+		for _, p := range g {
+			if len(p) == 0 {
+				continue
+			}
+			if area := math.Abs(p[0].SignedArea()); nil == positions || area > largest {
+				positions, largest = p[0], area
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	var points = geo.NewPointSet()
+	for _, pos := range positions {
+		points.Push(geo.NewPoint(pos[0], pos[1]))
+	}
+	var point *geo.Point
+	if _, ok := geometry.(lib.LineString); ok {
+		point = lib.GetLineCentroid(points)
+	} else {
+		point = lib.GetPolygonCentroid(points)
+	}
+	return lib.Point{point.Lng(), point.Lat()}
+}
+
+// Value - convert a tag value to the column type, returns nil (NULL) for
+// missing tags and values which cannot be converted
+func (col *Column) Value(tags map[string]string) interface{} {
+	value, ok := tags[col.Tag]
+	if !ok {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+
+	switch col.Type {
+	case "integer":
+		var num = leadingNumber.FindString(value)
+		if f, err := strconv.ParseFloat(num, 64); nil == err {
+			return int64(f)
+		}
+		return nil
+	case "real":
+		var num = leadingNumber.FindString(value)
+		if f, err := strconv.ParseFloat(num, 64); nil == err {
+			return f
+		}
+		return nil
+	case "boolean":
+		switch strings.ToLower(value) {
+		case "yes", "true", "1":
+			return 1
+		case "no", "false", "0":
+			return 0
+		}
+		return nil
+	}
+	return value
+}
+
+// RetainedTags - the tags to store in the 'tags' column, returns nil when
+// the table doesn't retain tags
+func (t *Table) RetainedTags(tags map[string]string) map[string]string {
+	switch t.Tags {
+	case "all":
+		return tags
+	case "unmapped":
+		var retained = make(map[string]string)
+		for k, v := range tags {
+			if !t.mapped[k] {
+				retained[k] = v
+			}
+		}
+		return retained
+	}
+	return nil
+}
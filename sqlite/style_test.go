@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/missinglink/gosmparse"
+	"github.com/missinglink/pbf/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeStyle(t *testing.T, body string) string {
+	dir, err := ioutil.TempDir("", "style")
+	assert.Nil(t, err)
+	var path = filepath.Join(dir, "style.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(body), 0644))
+	return path
+}
+
+func TestNewStyleFromJSON(t *testing.T) {
+	var path = writeStyle(t, `{"tables": [{
+		"name": "roads",
+		"match": {"way": [["highway"]]},
+		"columns": [{"name": "name"}, {"name": "lanes", "type": "integer"}],
+		"tags": "unmapped"
+	}]}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	style, err := NewStyleFromJSON(path)
+	assert.Nil(t, err)
+	assert.Len(t, style.Tables, 1)
+
+	// defaults
+	var table = style.Tables[0]
+	assert.Equal(t, "any", table.Geometry)
+	assert.Equal(t, Column{Name: "name", Tag: "name", Type: "text"}, table.Columns[0])
+
+	// matching
+	var tags = map[string]string{"highway": "primary", "name": "Lê Lợi", "ref": "1A"}
+	assert.True(t, table.Matches(&lib.Feature{Type: "way", Tags: tags}))
+	assert.False(t, table.Matches(&lib.Feature{Type: "node", Tags: tags}))
+	assert.True(t, style.Features().MatchTags("way", tags))
+
+	// retention
+	assert.Equal(t, map[string]string{"highway": "primary", "ref": "1A"}, table.RetainedTags(tags))
+}
+
+func TestNewStyleFromJSONInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"tables": []}`,
+		`{"tables": [{"name": "a b"}]}`,
+		`{"tables": [{"name": "a"}, {"name": "a"}]}`,
+		`{"tables": [{"name": "a", "geometry": "circle"}]}`,
+		`{"tables": [{"name": "a", "tags": "some"}]}`,
+		`{"tables": [{"name": "a", "columns": [{"name": "osm_id"}]}]}`,
+		`{"tables": [{"name": "a", "columns": [{"name": "b", "type": "date"}]}]}`,
+		`{"tables": [{"name": "a", "unknown": true}]}`,
+		`{"tables": [{"name": "a"}, {"name": "A"}]}`,
+		`{"tables": [{"name": "sources"}]}`,
+		`{"tables": [{"name": "a"}, {"name": "a_rtree"}]}`,
+		`{"tables": [{"name": "a_osm_idx"}, {"name": "a"}]}`,
+		`{"tables": [{"name": "a", "columns": [{"name": "b"}, {"name": "B"}]}]}`,
+	} {
+		var path = writeStyle(t, body)
+		_, err := NewStyleFromJSON(path)
+		assert.NotNil(t, err, body)
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	var tags = map[string]string{"lanes": "2", "maxspeed": "50 mph", "width": "3.5", "oneway": "yes", "bridge": "no", "layer": "x"}
+
+	assert.Equal(t, int64(2), (&Column{Tag: "lanes", Type: "integer"}).Value(tags))
+	assert.Equal(t, int64(50), (&Column{Tag: "maxspeed", Type: "integer"}).Value(tags))
+	assert.Equal(t, 3.5, (&Column{Tag: "width", Type: "real"}).Value(tags))
+	assert.Equal(t, 1, (&Column{Tag: "oneway", Type: "boolean"}).Value(tags))
+	assert.Equal(t, 0, (&Column{Tag: "bridge", Type: "boolean"}).Value(tags))
+	assert.Equal(t, "50 mph", (&Column{Tag: "maxspeed", Type: "text"}).Value(tags))
+	assert.Nil(t, (&Column{Tag: "layer", Type: "integer"}).Value(tags))
+	assert.Nil(t, (&Column{Tag: "missing", Type: "text"}).Value(tags))
+}
+
+func TestTableGeom(t *testing.T) {
+	var closed = lib.LineString{{0, 0}, {1, 0}, {1, 1}, {0, 0}}
+	var open = lib.LineString{{0, 0}, {2, 2}}
+
+	assert.Equal(t, lib.Point{1.0, 1.0}, (&Table{Geometry: "point"}).Geom(open))
+
+	// the centroid along the line rather than the centre of its bounds
+	var bent = lib.LineString{{0, 0}, {4, 0}, {4, 1}}
+	assert.Equal(t, lib.Point{2.5, 0.0}, (&Table{Geometry: "point"}).Geom(bent))
+
+	// polygons agree with the centroid of the equivalent way
+	var nodes []*gosmparse.Node
+	for _, pos := range closed {
+		nodes = append(nodes, &gosmparse.Node{Lon: pos[0], Lat: pos[1]})
+	}
+	var lon, lat = lib.WayCentroid(nodes)
+	var point = (&Table{Geometry: "point"}).Geom(lib.Polygon{lib.Ring(closed)}).(lib.Point)
+	assert.InDelta(t, lon, point[0], 1e-9)
+	assert.InDelta(t, lat, point[1], 1e-9)
+	assert.Equal(t, open, (&Table{Geometry: "linestring"}).Geom(open))
+	assert.Nil(t, (&Table{Geometry: "linestring"}).Geom(lib.Point{0, 0}))
+	assert.Equal(t, lib.Polygon{lib.Ring(closed)}, (&Table{Geometry: "polygon"}).Geom(closed))
+	assert.Nil(t, (&Table{Geometry: "polygon"}).Geom(open))
+	assert.Equal(t, open, (&Table{Geometry: "any"}).Geom(open))
+}
+
+func TestStyleWriterFid(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	var style = &Style{Tables: []*Table{{Name: "roads", Match: lib.Config{"way": lib.Group{{"highway"}}}}}}
+	assert.Nil(t, style.validate())
+
+	var w = NewStyleWriter(path, style)
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 7, Tags: map[string]string{"highway": "primary"}, Geometry: lib.LineString{{0, 0}, {1, 1}}})
+	w.WriteFeature(&lib.Feature{Type: "way", ID: 8, Tags: map[string]string{"highway": "primary"}, Geometry: lib.LineString{{2, 2}, {3, 3}}})
+	assert.Nil(t, w.RecordSource("a.pbf"))
+	w.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// the r-tree ids are the fid of each row
+	assert.Equal(t, 2, count(t, db, "SELECT COUNT(*) FROM roads JOIN roads_rtree ON roads_rtree.id = roads.fid"))
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM roads JOIN roads_rtree ON roads_rtree.id = roads.fid WHERE osm_id = 8 AND minlon = 2"))
+
+	// the source is recorded alongside the style tables
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM sources WHERE source = 'a.pbf'"))
+}
+
+func TestStyleWriterKeywords(t *testing.T) {
+	var path = tempDB(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	var style = &Style{Tables: []*Table{{
+		Name:    "group",
+		Match:   lib.Config{"node": lib.Group{{"shop"}}},
+		Columns: []Column{{Name: "order", Tag: "name"}, {Name: "index", Tag: "level", Type: "integer"}},
+	}}}
+	assert.Nil(t, style.validate())
+
+	var w = NewStyleWriter(path, style)
+	w.WriteFeature(&lib.Feature{Type: "node", ID: 1, Tags: map[string]string{"shop": "bakery", "name": "Bäckerei", "level": "2"}, Geometry: lib.Point{1, 2}})
+	w.Close()
+
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	var name string
+	var level int
+	assert.Nil(t, db.QueryRow(`SELECT "order", "index" FROM "group" WHERE osm_id = 1`).Scan(&name, &level))
+	assert.Equal(t, "Bäckerei", name)
+	assert.Equal(t, 2, level)
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM "group_rtree" WHERE minlon = 1 AND minlat = 2`))
+}
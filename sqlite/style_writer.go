@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/missinglink/pbf/lib"
+)
+
+// StyleWriter - write features to the tables defined by a style, each
+// table has an fid primary key, osm_type, osm_id, the style columns, an
+// optional tags json column and a WKT geom column indexed by an r-tree
+// named {table}_rtree whose ids are the fid of the row
+type StyleWriter struct {
+	DB    *sql.DB
+	Style *Style
+
+	mutex   sync.Mutex
+	stmts   map[string]*sql.Stmt
+	rtrees  map[string]*sql.Stmt
+	pending int
+}
+
+// NewStyleWriter - open database, create style tables and prepare statements
+func NewStyleWriter(path string, style *Style) *StyleWriter {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		panic(err)
+	}
+
+	// https://github.com/mattn/go-sqlite3/issues/274
+	db.SetMaxOpenConns(1)
+
+	var w = &StyleWriter{
+		DB:     db,
+		Style:  style,
+		stmts:  make(map[string]*sql.Stmt),
+		rtrees: make(map[string]*sql.Stmt),
+	}
+
+	_, err = db.Exec(`
+		PRAGMA main.page_size=4096;
+		PRAGMA main.cache_size=-64000;
+		PRAGMA main.synchronous=NORMAL;
+		PRAGMA main.journal_mode=WAL;
+		PRAGMA main.temp_store=MEMORY;
+
+		CREATE TABLE IF NOT EXISTS schema_info (
+		    key TEXT NOT NULL PRIMARY KEY,
+		    value TEXT
+		);
+		INSERT OR IGNORE INTO schema_info (key, value) VALUES ('version', '` + strconv.Itoa(SchemaVersion) + `');
+		INSERT OR IGNORE INTO schema_info (key, value) VALUES ('generator', 'missinglink/pbf');
+		INSERT OR IGNORE INTO schema_info (key, value) VALUES ('layout', 'style');`)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, t := range style.Tables {
+		w.table(t)
+	}
+
+	// start transaction
+	_, err = db.Exec("BEGIN TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	return w
+}
+
+// table - create a style table and prepare its statements
+func (w *StyleWriter) table(t *Table) {
+	var defs = []string{"fid INTEGER PRIMARY KEY", "osm_type TEXT NOT NULL", "osm_id INTEGER NOT NULL"}
+	var names = []string{"osm_type", "osm_id"}
+	for _, col := range t.Columns {
+		defs = append(defs, quote(col.Name)+" "+styleTypes[col.Type])
+		names = append(names, quote(col.Name))
+	}
+	if "none" != t.Tags {
+		defs = append(defs, "tags TEXT")
+		names = append(names, "tags")
+	}
+	if "none" != t.Geometry {
+		defs = append(defs, "geom TEXT")
+		names = append(names, "geom")
+	}
+
+	_, err := w.DB.Exec(fmt.Sprintf("CREATE TABLE %s (\n    %s\n)", quote(t.Name), strings.Join(defs, ",\n    ")))
+	if err != nil {
+		panic(err)
+	}
+
+	var params = strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	stmt, err := w.DB.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(t.Name), strings.Join(names, ", "), params))
+	if err != nil {
+		panic(err)
+	}
+	w.stmts[t.Name] = stmt
+
+	if "none" == t.Geometry {
+		return
+	}
+
+	_, err = w.DB.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING rtree (id, minlon, maxlon, minlat, maxlat)", quote(t.Name+"_rtree")))
+	if err != nil {
+		panic(err)
+	}
+
+	stmt, err = w.DB.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (?, ?, ?, ?, ?)", quote(t.Name+"_rtree")))
+	if err != nil {
+		panic(err)
+	}
+	w.rtrees[t.Name] = stmt
+}
+
+// WriteFeature - insert feature in to every table it matches
+func (w *StyleWriter) WriteFeature(feature *lib.Feature) {
+	for _, t := range w.Style.Tables {
+		if !t.Matches(feature) {
+			continue
+		}
+
+		var geometry lib.Geometry
+		if "none" != t.Geometry {
+			if geometry = t.Geom(feature.Geometry); nil == geometry {
+				continue
+			}
+		}
+
+		var args = []interface{}{feature.Type, feature.ID}
+		for i := range t.Columns {
+			args = append(args, t.Columns[i].Value(feature.Tags))
+		}
+		if retained := t.RetainedTags(feature.Tags); nil != retained {
+			encoded, _ := json.Marshal(retained)
+			args = append(args, string(encoded))
+		}
+		if nil != geometry {
+			args = append(args, geometry.WKT())
+		}
+
+		w.write(t, args, geometry)
+	}
+}
+
+// write - insert a single row and its r-tree entry
+func (w *StyleWriter) write(t *Table, args []interface{}, geometry lib.Geometry) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	res, err := w.stmts[t.Name].Exec(args...)
+	if err != nil {
+		log.Printf("failed to write %s %d to %s: %s\n", args[0], args[1], t.Name, err)
+		return
+	}
+
+	// spatial index
+	if nil != geometry {
+		var bounds = geometry.Bounds()
+		fid, err := res.LastInsertId()
+		if err == nil {
+			_, err = w.rtrees[t.Name].Exec(fid, bounds.MinLon, bounds.MaxLon, bounds.MinLat, bounds.MaxLat)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	// commit periodically
+	w.pending++
+	if w.pending >= batchSize {
+		w.pending = 0
+		if _, err := w.DB.Exec("END TRANSACTION; BEGIN TRANSACTION"); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// RecordSource - write metadata about the imported file to schema_info
+func (w *StyleWriter) RecordSource(source string) error {
+	return recordSource(w.DB, source)
+}
+
+// Close - commit, index and close the database
+func (w *StyleWriter) Close() {
+	defer w.DB.Close()
+
+	for _, stmt := range w.stmts {
+		stmt.Close()
+	}
+	for _, stmt := range w.rtrees {
+		stmt.Close()
+	}
+
+	// commit transaction
+	_, err := w.DB.Exec("END TRANSACTION")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, t := range w.Style.Tables {
+		_, err := w.DB.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (osm_type, osm_id)", quote(t.Name+"_osm_idx"), quote(t.Name)))
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// quote - quote a table or column name, style names may be sql keywords
+// such as 'order' or 'group'
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
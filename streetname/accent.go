@@ -0,0 +1,42 @@
+package streetname
+
+import (
+	"strings"
+	"unicode"
+)
+
+// vietnamese characters with diacritics and their unaccented equivalents
+const (
+	accented   = `ÀÁÂÃÈÉÊÌÍÒÓÔÕÙÚÝàáâãèéêìíòóôõùúýĂăĐđĨĩŨũƠơƯưẠạẢảẤấẦầẨẩẪẫẬậẮắẰằẲẳẴẵẶặẸẹẺẻẼẽẾếỀềỂểỄễỆệỈỉỊịỌọỎỏỐốỒồỔổỖỗỘộỚớỜờỞởỠỡỢợỤụỦủỨứỪừỬửỮữỰựỹỳỷỵỸỲỶỴðį`
+	unaccented = `AAAAEEEIIOOOOUUYaaaaeeeiioooouuyAaDdIiUuOoUuAaAaAaAaAaAaAaAaAaAaAaAaEeEeEeEeEeEeEeEeIiIiOoOoOoOoOoOoOoOoOoOoOoOoUuUuUuUuUuUuUuyyyyYYYYdi`
+)
+
+// accents - lookup table built from the strings above
+var accents = func() map[rune]rune {
+	var from, to = []rune(accented), []rune(unaccented)
+	if len(from) != len(to) {
+		panic("accent tables differ in length")
+	}
+	var m = make(map[rune]rune, len(from))
+	for i, r := range from {
+		m[r] = to[i]
+	}
+	return m
+}()
+
+// RemoveAccent - replace vietnamese accented characters with their ascii
+// equivalents, combining marks (as found in decomposed text) are dropped
+func RemoveAccent(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if replacement, ok := accents[r]; ok {
+			r = replacement
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
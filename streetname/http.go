@@ -0,0 +1,177 @@
+package streetname
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultParserURL - the address parser previously called by 'streets'
+const DefaultParserURL = "http://parser.map4d.vn/parser/parse"
+
+// Response - address parser response
+type Response struct {
+	Solutions []Solution `json:"solutions"`
+}
+
+// Solution - a single parse of the input, ordered best first
+type Solution struct {
+	Score           int              `json:"score"`
+	Classifications []Classification `json:"classifications"`
+}
+
+// Classification - a labelled part of the input
+type Classification struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// HTTP - normalizer backed by a remote address parser, responses are
+// cached, concurrent lookups of the same text share a single request and
+// requests are rate limited. names are first checked against the local
+// rules and the local result is used when a request fails.
+type HTTP struct {
+	URL      string
+	Client   *http.Client
+	Fallback *Vietnamese
+	Skip     []string // local names which are skipped rather than parsed
+
+	mutex    sync.Mutex
+	cache    map[string]string
+	inflight map[string]*httpCall
+	limiter  *time.Ticker
+	failures int
+}
+
+// httpCall - a request in progress, waited on by concurrent lookups
+type httpCall struct {
+	done   chan struct{}
+	street string
+	err    error
+}
+
+// NewHTTP - constructor, rate is the maximum number of requests per second
+func NewHTTP(parserURL string, rate int) *HTTP {
+	if "" == parserURL {
+		parserURL = DefaultParserURL
+	}
+	var h = &HTTP{
+		URL:      parserURL,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Fallback: NewVietnamese(),
+		cache:    make(map[string]string),
+		inflight: make(map[string]*httpCall),
+	}
+	if rate > 0 {
+		h.limiter = time.NewTicker(time.Second / time.Duration(rate))
+	}
+	return h
+}
+
+// Close - stop the rate limiter
+func (h *HTTP) Close() {
+	if nil != h.limiter {
+		h.limiter.Stop()
+	}
+}
+
+// Normalize - implements Normalizer
+func (h *HTTP) Normalize(name string) string {
+	var local = h.Fallback.Normalize(name)
+	if "" == local {
+		return ""
+	}
+	for _, skip := range h.Skip {
+		if skip == local {
+			return ""
+		}
+	}
+
+	// the parser expects unaccented text starting with 'duong'
+	var text = strings.Join(h.Fallback.words(name), " ")
+	if !hasWordPrefix(text, "duong") {
+		text = "duong " + text
+	}
+
+	parsed, err := h.lookup(text)
+	if nil != err {
+		h.mutex.Lock()
+		h.failures++
+		if h.failures == 1 || h.failures%1000 == 0 {
+			log.Printf("street name parser failed %d times, using local rules: %s\n", h.failures, err)
+		}
+		h.mutex.Unlock()
+		return local
+	}
+	return parsed
+}
+
+// lookup - the cached parse of text, concurrent lookups of text which is
+// not cached wait for the first request rather than sending their own.
+// failures are not cached so the text is requested again next time.
+func (h *HTTP) lookup(text string) (string, error) {
+	h.mutex.Lock()
+	if cached, ok := h.cache[text]; ok {
+		h.mutex.Unlock()
+		return cached, nil
+	}
+	if call, ok := h.inflight[text]; ok {
+		h.mutex.Unlock()
+		<-call.done
+		return call.street, call.err
+	}
+	var call = &httpCall{done: make(chan struct{})}
+	h.inflight[text] = call
+	h.mutex.Unlock()
+
+	call.street, call.err = h.parse(text)
+
+	h.mutex.Lock()
+	if nil == call.err {
+		h.cache[text] = call.street
+	}
+	delete(h.inflight, text)
+	h.mutex.Unlock()
+	close(call.done)
+
+	return call.street, call.err
+}
+
+// parse - query the parser, returns "" when the text contains a house number
+func (h *HTTP) parse(text string) (string, error) {
+	if nil != h.limiter {
+		<-h.limiter.C
+	}
+
+	res, err := h.Client.Get(h.URL + "?text=" + url.QueryEscape(text))
+	if nil != err {
+		return "", err
+	}
+	defer res.Body.Close()
+	if http.StatusOK != res.StatusCode {
+		return "", fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	var response Response
+	if err := json.NewDecoder(res.Body).Decode(&response); nil != err {
+		return "", err
+	}
+
+	var street string
+	if len(response.Solutions) > 0 {
+		for _, classification := range response.Solutions[0].Classifications {
+			switch classification.Label {
+			case "housenumber":
+				return "", nil
+			case "street":
+				street = classification.Value
+			}
+		}
+	}
+	return street, nil
+}
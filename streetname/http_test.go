@@ -0,0 +1,73 @@
+package streetname
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPNormalize(t *testing.T) {
+	var requests int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Query().Get("text") {
+		case "duong nguyen trai":
+			fmt.Fprint(w, `{"solutions":[{"score":1,"classifications":[{"label":"street","value":"nguyen trai"}]}]}`)
+		case "duong le loi 12":
+			fmt.Fprint(w, `{"solutions":[{"score":1,"classifications":[{"label":"housenumber","value":"12"},{"label":"street","value":"le loi"}]}]}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	var h = NewHTTP(server.URL, 0)
+	h.Skip = []string{"ngo chu huy man"}
+	assert.Equal(t, "nguyen trai", h.Normalize("Nguyễn Trãi"))
+	assert.Equal(t, "nguyen trai", h.Normalize("Đường Nguyễn Trãi"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "responses are cached")
+
+	// house number
+	assert.Equal(t, "", h.Normalize("Lê Lợi 12"))
+
+	// local rules are applied before querying
+	assert.Equal(t, "", h.Normalize("Kiệt 5"))
+	assert.Equal(t, "", h.Normalize("Ngõ Chu Huy Mân"))
+
+	// failures fall back to the local rules
+	assert.Equal(t, "hang bac", h.Normalize("Phố Hàng Bạc"))
+}
+
+func TestHTTPInflight(t *testing.T) {
+	var requests int32
+	var release = make(chan struct{})
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprint(w, `{"solutions":[{"score":1,"classifications":[{"label":"street","value":"nguyen trai"}]}]}`)
+	}))
+	defer server.Close()
+
+	var h = NewHTTP(server.URL, 1000)
+	defer h.Close()
+
+	// concurrent lookups of the same name share one request
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, "nguyen trai", h.Normalize("Nguyễn Trãi"))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
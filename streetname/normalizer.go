@@ -0,0 +1,9 @@
+package streetname
+
+// Normalizer - reduce a street name to the key used to group the ways which
+// belong to the same street, returns "" for names which are not streets
+// (alleys, bridges, addresses etc.) and should be skipped.
+// implementations must be safe for concurrent use.
+type Normalizer interface {
+	Normalize(name string) string
+}
@@ -0,0 +1,84 @@
+package streetname
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Vietnamese - rule based normalizer for vietnamese street names, names are
+// lowercased and unaccented, bracketed alternative names and generic
+// prefixes are removed so that 'Đường Nguyễn Trãi (ĐT 605)' and
+// 'nguyen trai' produce the same key
+type Vietnamese struct {
+	Prefixes []string // generic prefixes removed from the name
+	Ignore   []string // names starting with these words are not streets
+}
+
+// NewVietnamese - constructor with the default rules
+func NewVietnamese() *Vietnamese {
+	return &Vietnamese{
+		Prefixes: []string{"duong", "pho"},
+		Ignore:   []string{"kiet", "hem", "cau", "vong xuyen"},
+	}
+}
+
+var (
+	// text in brackets, usually an alternative name or route number
+	brackets = regexp.MustCompile(`\(.*?\)|\[.*?\]`)
+
+	// characters which don't contribute to the name
+	punctuation = regexp.MustCompile(`[^\p{L}\p{N}/]+`)
+
+	// a house number such as '12', '12a' or '12/3b'
+	houseNumber = regexp.MustCompile(`^\d+[a-z]?([/-]\d+[a-z]?)*$`)
+)
+
+// Normalize - implements Normalizer
+func (v *Vietnamese) Normalize(name string) string {
+	var words = v.words(name)
+	if len(words) == 0 {
+		return ""
+	}
+
+	// alleys, bridges and roundabouts are not streets
+	var joined = strings.Join(words, " ")
+	for _, prefix := range v.Ignore {
+		if hasWordPrefix(joined, prefix) {
+			return ""
+		}
+	}
+
+	// addresses such as '12 nguyen trai', numbered streets such as
+	// 'duong so 5' and dates such as '3 thang 2' are kept
+	var number = words
+	if "so" == number[0] {
+		number = number[1:]
+	}
+	if len(number) > 1 && houseNumber.MatchString(number[0]) && "thang" != number[1] {
+		return ""
+	}
+
+	// generic prefixes
+	for _, prefix := range v.Prefixes {
+		if hasWordPrefix(joined, prefix) && len(joined) > len(prefix)+1 {
+			joined = joined[len(prefix)+1:]
+			break
+		}
+	}
+
+	return joined
+}
+
+// words - lowercase, unaccented words of the name without bracketed text
+// or punctuation
+func (v *Vietnamese) words(name string) []string {
+	name = brackets.ReplaceAllString(name, " ")
+	name = RemoveAccent(strings.ToLower(name))
+	name = punctuation.ReplaceAllString(name, " ")
+	return strings.Fields(name)
+}
+
+// hasWordPrefix - yes/no if s starts with the whole words in prefix
+func hasWordPrefix(s string, prefix string) bool {
+	return s == prefix || strings.HasPrefix(s, prefix+" ")
+}
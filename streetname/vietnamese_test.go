@@ -0,0 +1,49 @@
+package streetname
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveAccent(t *testing.T) {
+	assert.Equal(t, "Duong Nguyen Trai", RemoveAccent("Đường Nguyễn Trãi"))
+	assert.Equal(t, "quoc lo 1a", RemoveAccent("quốc lộ 1a"))
+	assert.Equal(t, "hoang quoc viet", RemoveAccent("hoàng quốc việt"))
+
+	// decomposed text uses combining marks
+	assert.Equal(t, "Hoang Quoc Viet", RemoveAccent("Hoàng Quốc Việt"))
+}
+
+func TestVietnameseNormalize(t *testing.T) {
+	var v = NewVietnamese()
+
+	// generic prefixes
+	assert.Equal(t, "nguyen trai", v.Normalize("Đường Nguyễn Trãi"))
+	assert.Equal(t, "nguyen trai", v.Normalize("nguyễn  trãi"))
+	assert.Equal(t, "hang bac", v.Normalize("Phố Hàng Bạc"))
+	assert.Equal(t, "duong", v.Normalize("Đường"))
+
+	// bracketed alternative names
+	assert.Equal(t, "le loi", v.Normalize("Lê Lợi (ĐT 605)"))
+	assert.Equal(t, "le loi", v.Normalize("Lê Lợi [old name]"))
+
+	// alleys, bridges and roundabouts
+	assert.Equal(t, "", v.Normalize("Kiệt 12 Nguyễn Trãi"))
+	assert.Equal(t, "", v.Normalize("Hẻm 45"))
+	assert.Equal(t, "", v.Normalize("Cầu Rồng"))
+	assert.Equal(t, "", v.Normalize("Vòng xuyến Phú Lộc"))
+	assert.Equal(t, "caula", v.Normalize("Caula"))
+
+	// house numbers
+	assert.Equal(t, "", v.Normalize("12 Nguyễn Trãi"))
+	assert.Equal(t, "", v.Normalize("12/3b Nguyễn Trãi"))
+	assert.Equal(t, "", v.Normalize("Số 5 Lê Lợi"))
+	assert.Equal(t, "so 5", v.Normalize("Đường số 5"))
+	assert.Equal(t, "3 thang 2", v.Normalize("Đường 3 tháng 2"))
+	assert.Equal(t, "3 thang 2", v.Normalize("3 Tháng 2"))
+
+	// punctuation
+	assert.Equal(t, "nguyen trai", v.Normalize("*Nguyễn Trãi*"))
+	assert.Equal(t, "", v.Normalize(" () "))
+}
@@ -0,0 +1,64 @@
+package tags
+
+import "strings"
+
+// name keys other than 'name' and 'name:*'
+var nameKeys = map[string]bool{
+	"alt_name":      true,
+	"old_name":      true,
+	"official_name": true,
+	"short_name":    true,
+}
+
+// IsName - yes/no if the key is a name variant, one of name, name:*,
+// alt_name, old_name, official_name or short_name
+func IsName(key string) bool {
+	return "name" == key || strings.HasPrefix(key, "name:") || nameKeys[key]
+}
+
+// Names - the name variants of an element, values are trimmed and empty
+// values are removed
+func Names(tags map[string]string) map[string]string {
+	var names = make(map[string]string)
+	for k, v := range tags {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if IsName(k) && "" != v {
+			names[k] = v
+		}
+	}
+	return names
+}
+
+// ParseLanguages - split a comma separated list of language codes
+func ParseLanguages(list string) []string {
+	var langs []string
+	for _, lang := range strings.Split(list, ",") {
+		if lang = strings.TrimSpace(lang); "" != lang {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// Localized - the name in the first language which is set, a regional
+// language such as 'pt-BR' falls back to its base language 'pt' before the
+// next language is tried. when no language is set the first of the
+// defaults keys which is set is used, falling back to 'name'
+func Localized(names map[string]string, langs []string, defaults ...string) string {
+	for _, lang := range langs {
+		if val, ok := names["name:"+lang]; ok {
+			return val
+		}
+		if i := strings.IndexAny(lang, "-_"); i > 0 {
+			if val, ok := names["name:"+lang[:i]]; ok {
+				return val
+			}
+		}
+	}
+	for _, key := range defaults {
+		if val, ok := names[key]; ok {
+			return val
+		}
+	}
+	return names["name"]
+}
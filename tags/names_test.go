@@ -0,0 +1,48 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNames(t *testing.T) {
+
+	var tagMap = make(map[string]string)
+	tagMap["name"] = " Đường Nguyễn Trãi "
+	tagMap["name:en"] = "Nguyen Trai Street"
+	tagMap["old_name"] = "Đường Cũ"
+	tagMap["short_name"] = ""
+	tagMap["highway"] = "primary"
+	tagMap["name_1"] = "other"
+
+	var names = Names(tagMap)
+	assert.Equal(t, 3, len(names))
+	assert.Equal(t, "Đường Nguyễn Trãi", names["name"])
+	assert.Equal(t, "Nguyen Trai Street", names["name:en"])
+	assert.Equal(t, "Đường Cũ", names["old_name"])
+}
+
+func TestLocalized(t *testing.T) {
+
+	var names = map[string]string{
+		"name":    "Đường Nguyễn Trãi",
+		"name:en": "Nguyen Trai Street",
+	}
+
+	assert.Equal(t, []string{"vi", "en"}, ParseLanguages(" vi, en,"))
+	assert.Equal(t, "Nguyen Trai Street", Localized(names, []string{"vi", "en"}))
+	assert.Equal(t, "Đường Nguyễn Trãi", Localized(names, []string{"fr"}))
+	assert.Equal(t, "Đường Nguyễn Trãi", Localized(names, nil))
+	assert.Equal(t, "", Localized(map[string]string{}, []string{"en"}))
+
+	// regional languages fall back to the base language
+	assert.Equal(t, "Nguyen Trai Street", Localized(names, []string{"en-GB"}))
+	assert.Equal(t, "Nguyen Trai Street", Localized(names, []string{"en_US", "vi"}))
+
+	// defaults are preferred to 'name'
+	names["addr:street"] = "Nguyễn Trãi"
+	assert.Equal(t, "Nguyễn Trãi", Localized(names, []string{"fr"}, "addr:street"))
+	assert.Equal(t, "Nguyen Trai Street", Localized(names, []string{"en"}, "addr:street"))
+	assert.Equal(t, "Đường Nguyễn Trãi", Localized(names, nil))
+}